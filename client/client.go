@@ -0,0 +1,241 @@
+// Package client calls the management API of mimicro from Go programs and tests
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pokidovea/mimicro/management"
+)
+
+// DefaultPollInterval is a period of checking statistics while waiting for requests
+const DefaultPollInterval = 50 * time.Millisecond
+
+// DefaultWaitTimeout limits waiting in assertions of test helpers
+const DefaultWaitTimeout = 2 * time.Second
+
+// Options configure the client
+type Options struct {
+	// HTTPClient sends requests. http.DefaultClient is used by default
+	HTTPClient *http.Client
+	// Auth is passed to the management server, when it's protected by a token or basic credentials
+	Auth management.Auth
+	// PollInterval is a period of checking statistics while waiting. DefaultPollInterval is used by default
+	PollInterval time.Duration
+	// WaitTimeout limits waiting in assertions. DefaultWaitTimeout is used by default
+	WaitTimeout time.Duration
+}
+
+// Client calls the management API of a running mimicro
+type Client struct {
+	baseURL string
+	options Options
+}
+
+// APIError is an error answered by the management server
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (err *APIError) Error() string {
+	return fmt.Sprintf("management server answered %d: %s", err.StatusCode, err.Message)
+}
+
+// New creates a client of the management server with the base URL like http://localhost:4444
+func New(baseURL string, options Options) *Client {
+	if options.HTTPClient == nil {
+		options.HTTPClient = http.DefaultClient
+	}
+	if options.PollInterval == 0 {
+		options.PollInterval = DefaultPollInterval
+	}
+	if options.WaitTimeout == 0 {
+		options.WaitTimeout = DefaultWaitTimeout
+	}
+
+	return &Client{baseURL: strings.TrimSuffix(baseURL, "/") + "/api/v1", options: options}
+}
+
+// do sends the request to the route of the API and decodes the JSON response into the result, if it's not nil
+func (client *Client) do(ctx context.Context, method string, route string, query url.Values, body interface{}, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = strings.NewReader(string(payload))
+	}
+
+	requestURL := client.baseURL + route
+	if len(query) > 0 {
+		requestURL += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, requestURL, reader)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if client.options.Auth.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+client.options.Auth.Token)
+	} else if client.options.Auth.Username != "" {
+		req.SetBasicAuth(client.options.Auth.Username, client.options.Auth.Password)
+	}
+
+	resp, err := client.options.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return readAPIError(resp)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+func readAPIError(resp *http.Response) error {
+	payload, _ := ioutil.ReadAll(resp.Body)
+
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(payload, &body); err != nil || body.Error == "" {
+		body.Error = strings.TrimSpace(string(payload))
+	}
+	return &APIError{StatusCode: resp.StatusCode, Message: body.Error}
+}
+
+// ReportOptions select a page of the statistics report
+type ReportOptions struct {
+	// GroupBy is "url" (default) or "endpoint"
+	GroupBy string
+	Offset  int
+	// Limit 0 means all records
+	Limit int
+}
+
+// Report returns statistics records of requests, which match the filter
+func (client *Client) Report(ctx context.Context, filter management.RequestFilter, options ReportOptions) (management.StatisticsReport, error) {
+	query := filter.Query()
+	if options.GroupBy != "" {
+		query.Set("group_by", options.GroupBy)
+	}
+	if options.Offset > 0 {
+		query.Set("offset", strconv.Itoa(options.Offset))
+	}
+	if options.Limit > 0 {
+		query.Set("limit", strconv.Itoa(options.Limit))
+	}
+
+	var report management.StatisticsReport
+	err := client.do(ctx, "GET", "/statistics", query, nil, &report)
+	return report, err
+}
+
+// Statistics returns numbers of requests, which match the filter
+func (client *Client) Statistics(ctx context.Context, filter management.RequestFilter) (map[management.ReceivedRequest]int, error) {
+	report, err := client.Report(ctx, filter, ReportOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return report.Requests(), nil
+}
+
+// RequestsCount returns the total number of requests, which match the filter
+func (client *Client) RequestsCount(ctx context.Context, filter management.RequestFilter) (int, error) {
+	report, err := client.Report(ctx, filter, ReportOptions{GroupBy: "endpoint"})
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, record := range report.Records {
+		total += record.Count
+	}
+	return total, nil
+}
+
+// ResetStatistics deletes statistics of requests, which match the filter
+func (client *Client) ResetStatistics(ctx context.Context, filter management.RequestFilter) error {
+	return client.do(ctx, "DELETE", "/statistics", filter.Query(), nil, nil)
+}
+
+// WaitForCount polls statistics until the number of requests, which match the filter, reaches the count.
+// It returns an error with the last number, when the context is done
+func (client *Client) WaitForCount(ctx context.Context, filter management.RequestFilter, count int) error {
+	ticker := time.NewTicker(client.options.PollInterval)
+	defer ticker.Stop()
+
+	current := 0
+	for {
+		received, err := client.RequestsCount(ctx, filter)
+		if _, ok := err.(*APIError); ok {
+			return err
+		}
+		if err == nil {
+			current = received
+			if current >= count {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for %d requests: got %d before %s", count, current, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// State exports statistics, overrides and the level of access logs
+func (client *Client) State(ctx context.Context) (management.State, error) {
+	var state management.State
+	err := client.do(ctx, "GET", "/state", nil, nil, &state)
+	return state, err
+}
+
+// RestoreState replaces statistics, overrides and the level of access logs with the exported ones
+func (client *Client) RestoreState(ctx context.Context, state management.State) error {
+	return client.do(ctx, "PUT", "/state", nil, state, nil)
+}
+
+// ResetState returns mimicro to the state loaded from the config
+func (client *Client) ResetState(ctx context.Context) error {
+	return client.do(ctx, "DELETE", "/state", nil, nil, nil)
+}
+
+// Overrides returns overrides of responses
+func (client *Client) Overrides(ctx context.Context) ([]management.ResponseOverride, error) {
+	var overrides []management.ResponseOverride
+	err := client.do(ctx, "GET", "/overrides", nil, nil, &overrides)
+	return overrides, err
+}
+
+// SetOverride overrides responses of the endpoint or replaces the override of the same endpoint, method and session
+func (client *Client) SetOverride(ctx context.Context, override management.ResponseOverride) (management.ResponseOverride, error) {
+	var result management.ResponseOverride
+	err := client.do(ctx, "PUT", "/overrides", nil, override, &result)
+	return result, err
+}
+
+// DeleteOverrides deletes overrides, which match the server, the endpoint, the method and the session of the filter
+func (client *Client) DeleteOverrides(ctx context.Context, filter management.RequestFilter) error {
+	return client.do(ctx, "DELETE", "/overrides", filter.Query(), nil, nil)
+}
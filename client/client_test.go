@@ -0,0 +1,231 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pokidovea/mimicro/management"
+	"github.com/pokidovea/mimicro/mimicro"
+	"github.com/stretchr/testify/assert"
+)
+
+const config = `
+servers:
+  - name: server_1
+    port: 0
+    endpoints:
+      - url: /users/{id}
+        GET:
+          template: "user {{.id}}"
+        POST:
+          template: "created"
+          status_code: 201
+`
+
+type fakeT struct {
+	errors []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func start(t *testing.T, instanceConfig mimicro.Config) (*mimicro.Instance, string) {
+	instanceConfig.YAML = []byte(config)
+	instance, err := mimicro.Start(context.Background(), instanceConfig)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+
+	url, _ := instance.URL("server_1")
+	return instance, url
+}
+
+func request(t *testing.T, method string, url string) {
+	req, _ := http.NewRequest(method, url, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if assert.Nil(t, err) {
+		resp.Body.Close()
+	}
+}
+
+func TestStatisticsAndReset(t *testing.T) {
+	instance, url := start(t, mimicro.Config{})
+	defer instance.Close()
+	client := New(instance.ManagementURL(), Options{})
+	ctx := context.Background()
+
+	request(t, "GET", url+"/users/1")
+	request(t, "GET", url+"/users/1")
+	request(t, "POST", url+"/users/2")
+	request(t, "GET", url+"/unknown")
+
+	assert.Nil(t, client.WaitForCount(ctx, management.RequestFilter{}, 4))
+
+	statistics, err := client.Statistics(ctx, management.RequestFilter{ServerName: "server_1", Method: "GET"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[management.ReceivedRequest]int{
+		{ServerName: "server_1", Endpoint: "/users/{id}", URL: "/users/1", Method: "GET", StatusCode: 200}: 2,
+		{ServerName: "server_1", URL: "/unknown", Method: "GET", StatusCode: 404, Unmatched: true}:         1,
+	}, statistics)
+
+	count, err := client.RequestsCount(ctx, management.RequestFilter{URL: "/users/2"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+
+	report, err := client.Report(ctx, management.RequestFilter{}, ReportOptions{Limit: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, report.Total)
+	assert.Len(t, report.Records, 1)
+
+	assert.Nil(t, client.ResetStatistics(ctx, management.RequestFilter{Method: "GET"}))
+	count, err = client.RequestsCount(ctx, management.RequestFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestWaitForCountTimeout(t *testing.T) {
+	instance, url := start(t, mimicro.Config{})
+	defer instance.Close()
+	client := New(instance.ManagementURL(), Options{PollInterval: 10 * time.Millisecond})
+
+	request(t, "GET", url+"/users/1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	err := client.WaitForCount(ctx, management.RequestFilter{URL: "/users/1"}, 2)
+	assert.EqualError(t, err, "waiting for 2 requests: got 1 before context deadline exceeded")
+}
+
+func TestAPIErrors(t *testing.T) {
+	instance, _ := start(t, mimicro.Config{DisableStatistics: true})
+	defer instance.Close()
+	client := New(instance.ManagementURL(), Options{})
+
+	_, err := client.Statistics(context.Background(), management.RequestFilter{})
+	assert.Equal(t, &APIError{StatusCode: http.StatusNotFound, Message: "statistics are not collected"}, err)
+
+	err = client.WaitForCount(context.Background(), management.RequestFilter{}, 1)
+	assert.Equal(t, &APIError{StatusCode: http.StatusNotFound, Message: "statistics are not collected"}, err)
+}
+
+func TestAuth(t *testing.T) {
+	instance, _ := start(t, mimicro.Config{ManagementAuth: management.Auth{Username: "admin", Password: "secret"}})
+	defer instance.Close()
+
+	_, err := New(instance.ManagementURL(), Options{}).RequestsCount(context.Background(), management.RequestFilter{})
+	assert.Equal(t, &APIError{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"}, err)
+
+	client := New(instance.ManagementURL(), Options{Auth: management.Auth{Username: "admin", Password: "secret"}})
+	count, err := client.RequestsCount(context.Background(), management.RequestFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestAssertStatistics(t *testing.T) {
+	instance, url := start(t, mimicro.Config{})
+	defer instance.Close()
+	client := New(instance.ManagementURL(), Options{PollInterval: 10 * time.Millisecond, WaitTimeout: 200 * time.Millisecond})
+
+	request(t, "GET", url+"/users/1")
+	request(t, "POST", url+"/users/1")
+
+	user1 := management.ReceivedRequest{ServerName: "server_1", Endpoint: "/users/{id}", URL: "/users/1", Method: "GET", StatusCode: 200}
+	created := management.ReceivedRequest{ServerName: "server_1", Endpoint: "/users/{id}", URL: "/users/1", Method: "POST", StatusCode: 201}
+	user2 := management.ReceivedRequest{ServerName: "server_1", Endpoint: "/users/{id}", URL: "/users/2", Method: "GET", StatusCode: 200}
+
+	assert.True(t, client.AssertStatistics(t, management.RequestFilter{}, map[management.ReceivedRequest]int{user1: 1, created: 1}))
+	assert.True(t, client.AssertCount(t, management.RequestFilter{Method: "POST"}, 1))
+
+	fake := &fakeT{}
+	assert.False(t, client.AssertStatistics(fake, management.RequestFilter{}, map[management.ReceivedRequest]int{user1: 2, user2: 1}))
+	assert.Equal(t, []string{
+		"statistics of requests don't match (-expected +actual):\n" +
+			"- server: server_1; endpoint: /users/{id}; url: /users/1; method: GET; response status: 200; count: 2\n" +
+			"+ server: server_1; endpoint: /users/{id}; url: /users/1; method: GET; response status: 200; count: 1\n" +
+			"+ server: server_1; endpoint: /users/{id}; url: /users/1; method: POST; response status: 201; count: 1\n" +
+			"- server: server_1; endpoint: /users/{id}; url: /users/2; method: GET; response status: 200; count: 1",
+	}, fake.errors)
+
+	fake = &fakeT{}
+	assert.False(t, client.AssertCount(fake, management.RequestFilter{Method: "GET"}, 2))
+	assert.Equal(t, []string{
+		"expected 2 requests, got 1. Received requests (+actual):\n" +
+			"+ server: server_1; endpoint: /users/{id}; url: /users/1; method: GET; response status: 200; count: 1",
+	}, fake.errors)
+}
+
+func TestSnapshotAndRestoreState(t *testing.T) {
+	instance, url := start(t, mimicro.Config{})
+	defer instance.Close()
+	client := New(instance.ManagementURL(), Options{})
+	ctx := context.Background()
+
+	request(t, "GET", url+"/users/1")
+	assert.Nil(t, client.WaitForCount(ctx, management.RequestFilter{}, 1))
+
+	snapshot, err := client.State(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, management.State{
+		Version: management.StateVersion,
+		Statistics: []management.StatisticsEntry{{
+			Request: management.ReceivedRequest{ServerName: "server_1", Endpoint: "/users/{id}", URL: "/users/1", Method: "GET", StatusCode: 200},
+			Count:   1,
+		}},
+		Overrides: []management.ResponseOverride{},
+		LogLevel:  "info",
+	}, snapshot)
+
+	request(t, "GET", url+"/users/2")
+	assert.Nil(t, client.WaitForCount(ctx, management.RequestFilter{}, 2))
+
+	assert.Nil(t, client.RestoreState(ctx, snapshot))
+	assert.Equal(t, snapshot, instance.State())
+
+	assert.Nil(t, client.ResetState(ctx))
+	count, err := client.RequestsCount(ctx, management.RequestFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count)
+
+	snapshot.Version = 2
+	err = client.RestoreState(ctx, snapshot)
+	assert.Equal(t, &APIError{StatusCode: http.StatusBadRequest, Message: "state version 2 is not supported, expected 1"}, err)
+}
+
+func TestSessions(t *testing.T) {
+	instance, url := start(t, mimicro.Config{})
+	defer instance.Close()
+	client := New(instance.ManagementURL(), Options{})
+	ctx := context.Background()
+
+	_, err := client.SetOverride(ctx, management.ResponseOverride{
+		ServerName: "server_1", Endpoint: "/users/{id}", StatusCode: 503, Session: "test-1",
+	})
+	assert.Nil(t, err)
+
+	for _, session := range []string{"test-1", "test-2"} {
+		req, _ := http.NewRequest("GET", url+"/users/1", nil)
+		req.Header.Set(management.DefaultSessionHeader, session)
+		resp, err := http.DefaultClient.Do(req)
+		if assert.Nil(t, err) {
+			resp.Body.Close()
+		}
+	}
+
+	client.AssertStatistics(t, management.RequestFilter{Session: "test-1"}, map[management.ReceivedRequest]int{
+		{ServerName: "server_1", Endpoint: "/users/{id}", URL: "/users/1", Method: "GET", StatusCode: 503, Session: "test-1"}: 1,
+	})
+	client.AssertStatistics(t, management.RequestFilter{Session: "test-2"}, map[management.ReceivedRequest]int{
+		{ServerName: "server_1", Endpoint: "/users/{id}", URL: "/users/1", Method: "GET", StatusCode: 200, Session: "test-2"}: 1,
+	})
+
+	assert.Nil(t, client.DeleteOverrides(ctx, management.RequestFilter{Session: "test-1"}))
+	overrides, err := client.Overrides(ctx)
+	assert.Nil(t, err)
+	assert.Empty(t, overrides)
+}
@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pokidovea/mimicro/management"
+)
+
+// TestingT is a part of *testing.T, which is used by assertions
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// AssertStatistics checks that numbers of requests, which match the filter, are equal to the expected ones.
+// Requests are logged after responses are sent, so statistics are polled until they match or WaitTimeout expires.
+// On mismatch the test fails with a diff of requests
+func (client *Client) AssertStatistics(t TestingT, filter management.RequestFilter, expected map[management.ReceivedRequest]int) bool {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), client.options.WaitTimeout)
+	defer cancel()
+
+	var actual map[management.ReceivedRequest]int
+	var lastErr error
+	for {
+		statistics, err := client.Statistics(ctx, filter)
+		if err == nil {
+			actual, lastErr = statistics, nil
+			if reflect.DeepEqual(expected, actual) {
+				return true
+			}
+		} else if actual == nil {
+			lastErr = err
+		}
+
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				t.Errorf("cannot get statistics: %s", lastErr)
+			} else {
+				t.Errorf("statistics of requests don't match (-expected +actual):\n%s", diffStatistics(expected, actual))
+			}
+			return false
+		case <-time.After(client.options.PollInterval):
+		}
+	}
+}
+
+// AssertCount checks that the number of requests, which match the filter, is equal to the expected one
+func (client *Client) AssertCount(t TestingT, filter management.RequestFilter, expected int) bool {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), client.options.WaitTimeout)
+	defer cancel()
+
+	var actual map[management.ReceivedRequest]int
+	var lastErr error
+	for {
+		statistics, err := client.Statistics(ctx, filter)
+		if err == nil {
+			actual, lastErr = statistics, nil
+			if countRequests(actual) == expected {
+				return true
+			}
+		} else if actual == nil {
+			lastErr = err
+		}
+
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				t.Errorf("cannot get statistics: %s", lastErr)
+			} else {
+				t.Errorf(
+					"expected %d requests, got %d. Received requests (+actual):\n%s",
+					expected, countRequests(actual), diffStatistics(nil, actual),
+				)
+			}
+			return false
+		case <-time.After(client.options.PollInterval):
+		}
+	}
+}
+
+func countRequests(statistics map[management.ReceivedRequest]int) int {
+	total := 0
+	for _, count := range statistics {
+		total += count
+	}
+	return total
+}
+
+// diffStatistics lists requests with different numbers. Expected ones are prefixed with "-" and actual ones with "+"
+func diffStatistics(expected map[management.ReceivedRequest]int, actual map[management.ReceivedRequest]int) string {
+	requests := make(map[management.ReceivedRequest]bool)
+	for request := range expected {
+		requests[request] = true
+	}
+	for request := range actual {
+		requests[request] = true
+	}
+
+	sorted := make([]management.ReceivedRequest, 0, len(requests))
+	for request := range requests {
+		sorted = append(sorted, request)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].String() < sorted[j].String()
+	})
+
+	lines := []string{}
+	for _, request := range sorted {
+		expectedCount, actualCount := expected[request], actual[request]
+		switch {
+		case expectedCount == actualCount:
+			lines = append(lines, fmt.Sprintf("  %s; count: %d", request, expectedCount))
+		default:
+			if expectedCount > 0 {
+				lines = append(lines, fmt.Sprintf("- %s; count: %d", request, expectedCount))
+			}
+			if actualCount > 0 {
+				lines = append(lines, fmt.Sprintf("+ %s; count: %d", request, actualCount))
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return "  no requests"
+	}
+	return strings.Join(lines, "\n")
+}
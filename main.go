@@ -1,14 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
-	"sync"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/equinox-io/equinox"
 	"github.com/pokidovea/mimicro/management"
+	"github.com/pokidovea/mimicro/mimicro"
 	"github.com/pokidovea/mimicro/mockServer"
 )
 
@@ -50,8 +55,35 @@ func equinoxUpdate() error {
 	return nil
 }
 
-func checkConfig(configPath string) error {
-	err := mockServer.CheckConfig(configPath)
+// paramFlags collects repeated -set key=value flags
+type paramFlags map[string]string
+
+func (params paramFlags) String() string {
+	var pairs []string
+	for key, value := range params {
+		pairs = append(pairs, key+"="+value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (params paramFlags) Set(pair string) error {
+	parts := strings.SplitN(pair, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("expected key=value, got %s", pair)
+	}
+	params[parts[0]] = parts[1]
+	return nil
+}
+
+// checkConfig validates the config. Responses are rendered and checked against their contracts only with -check,
+// so a drift of a response from the spec doesn't stop mock servers
+func checkConfig(configPath string, params map[string]string, checkResponses bool) error {
+	var err error
+	if checkResponses {
+		err = mockServer.CheckConfigWithParams(configPath, params)
+	} else {
+		_, err = mockServer.NewLoader(mockServer.LoaderOptions{Params: params, Strict: true}).Load(configPath)
+	}
 
 	if err == nil {
 		fmt.Println("Config is valid")
@@ -62,16 +94,81 @@ func checkConfig(configPath string) error {
 	return err
 }
 
+func openAccessLogger(sink, format, levelName string, maxSize int64, backups int) (*management.AccessLogger, func(), error) {
+	level, err := management.ParseLogLevel(levelName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out, err := management.OpenAccessLogSink(sink, maxSize, backups)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logger, err := management.NewAccessLogger(format, level, out)
+	if err != nil {
+		out.Close()
+		return nil, nil, err
+	}
+
+	return logger, func() { out.Close() }, nil
+}
+
+func managementAuth(token, basicAuth string) (management.Auth, error) {
+	auth := management.Auth{Token: token}
+	if basicAuth == "" {
+		return auth, nil
+	}
+
+	parts := strings.SplitN(basicAuth, ":", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return auth, errors.New("-management-basic-auth must be user:password")
+	}
+	auth.Username, auth.Password = parts[0], parts[1]
+	return auth, nil
+}
+
 func main() {
 
-	configPath := flag.String("config", "", "a path to configuration file")
+	configPath := flag.String("config", "", "a path to configuration file or directory")
 	checkConf := flag.Bool("check", false, "validates passed config")
 	managementPort := flag.Int("management-port", 4444, "port for the management server")
+	managementBind := flag.String(
+		"management-bind", "", "an address of the interface for the management server, all interfaces by default",
+	)
+	managementToken := flag.String(
+		"management-token", os.Getenv("MIMICRO_MANAGEMENT_TOKEN"),
+		"require this bearer token for the management server, $MIMICRO_MANAGEMENT_TOKEN by default",
+	)
+	managementBasicAuth := flag.String(
+		"management-basic-auth", os.Getenv("MIMICRO_MANAGEMENT_BASIC_AUTH"),
+		"require these user:password credentials for the management server, $MIMICRO_MANAGEMENT_BASIC_AUTH by default",
+	)
 	collectStatistics := flag.Bool(
 		"collect-statistics", false, "pass this flag if you want to collect statistics of requests",
 	)
+	statisticsStorage := flag.String(
+		"statistics-storage", "memory", "where to keep statistics: memory, jsonl or kv",
+	)
+	statisticsPath := flag.String(
+		"statistics-path", "mimicro-statistics", "a file of statistics for the jsonl and kv storages",
+	)
+	accessLog := flag.String("access-log", "stderr", "where to write logs of requests: stdout, stderr, syslog or a file path")
+	accessLogFormat := flag.String("access-log-format", "text", "a format of logs of requests: text, json, logfmt or combined")
+	accessLogMaxSize := flag.Int64("access-log-max-size", 100, "rotate the file of logs of requests after this number of megabytes")
+	accessLogBackups := flag.Int("access-log-backups", 5, "how many rotated files of logs of requests to keep")
+	logLevel := flag.String("log-level", "info", "a minimal level of logged requests: debug, info, warn or error")
+	enableMetrics := flag.Bool("metrics", false, "serve Prometheus metrics on /metrics of the management server")
+	sessionHeader := flag.String(
+		"session-header", management.DefaultSessionHeader, "a header, which separates statistics and overrides of sessions",
+	)
 	update := flag.Bool("update", false, "check for a new version and update")
 	version := flag.Bool("version", false, "current version")
+	drainTimeout := flag.Duration(
+		"drain-timeout", mimicro.DefaultDrainTimeout, "how long to wait for active requests on shutdown",
+	)
+	params := make(paramFlags)
+	flag.Var(params, "set", "set a param of the config as key=value, can be repeated")
 
 	flag.Parse()
 
@@ -89,7 +186,7 @@ func main() {
 		os.Exit(0)
 	}
 
-	err := checkConfig(*configPath)
+	err := checkConfig(*configPath, params, *checkConf)
 
 	if err != nil {
 		os.Exit(1)
@@ -99,24 +196,80 @@ func main() {
 		os.Exit(0)
 	}
 
-	serverCollection, err := mockServer.Load(*configPath)
+	serverCollection, err := mockServer.LoadWithParams(*configPath, params)
+
+	if err != nil {
+		log.Printf(err.Error())
+		os.Exit(1)
+	}
+
+	var persistence management.StatisticsPersistence
+	switch *statisticsStorage {
+	case "memory":
+	case "jsonl":
+		persistence, err = management.NewJSONLinesPersistence(*statisticsPath)
+	case "kv":
+		persistence, err = management.NewKeyValuePersistence(*statisticsPath)
+	default:
+		err = fmt.Errorf("unknown statistics storage %s", *statisticsStorage)
+	}
+	if err == nil && persistence != nil && !*collectStatistics {
+		err = errors.New("-statistics-storage requires -collect-statistics")
+	}
+
+	if err != nil {
+		log.Printf(err.Error())
+		os.Exit(1)
+	}
+
+	auth, err := managementAuth(*managementToken, *managementBasicAuth)
+	if err != nil {
+		log.Printf(err.Error())
+		os.Exit(1)
+	}
 
+	accessLogger, closeAccessLog, err := openAccessLogger(
+		*accessLog, *accessLogFormat, *logLevel, *accessLogMaxSize<<20, *accessLogBackups,
+	)
 	if err != nil {
 		log.Printf(err.Error())
 		os.Exit(1)
 	}
+	defer closeAccessLog()
 
-	var wg sync.WaitGroup
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	managementServer := management.NewServer(*managementPort, *collectStatistics)
-	wg.Add(1)
-	go managementServer.Serve(&wg)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Printf("Received %s, stopping...", sig)
+		cancel()
+	}()
 
-	for _, server := range serverCollection.Servers {
-		wg.Add(1)
-		go server.Serve(managementServer.WriteRequestLog, &wg)
+	instance, err := mimicro.Start(ctx, mimicro.Config{
+		Collection:            serverCollection,
+		ManagementPort:        *managementPort,
+		ManagementBind:        *managementBind,
+		ManagementAuth:        auth,
+		DisableStatistics:     !*collectStatistics,
+		EnableMetrics:         *enableMetrics,
+		DrainTimeout:          *drainTimeout,
+		StatisticsPersistence: persistence,
+		AccessLogger:          accessLogger,
+		SessionHeader:         *sessionHeader,
+	})
+	if err != nil {
+		log.Printf("Cannot start: %s", err)
+		os.Exit(1)
 	}
 
-	wg.Wait()
+	<-ctx.Done()
+
+	if err = instance.Close(); err != nil {
+		log.Printf("Shutdown error: %s", err)
+		os.Exit(1)
+	}
 	log.Printf("Mimicro successfully down")
 }
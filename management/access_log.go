@@ -0,0 +1,323 @@
+package management
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pokidovea/mimicro/mockServer"
+)
+
+// formats of access logs
+const (
+	AccessLogText     = "text"
+	AccessLogJSON     = "json"
+	AccessLogLogfmt   = "logfmt"
+	AccessLogCombined = "combined"
+)
+
+// LogLevel is a minimal level of logged requests
+type LogLevel int32
+
+// levels of access logs. Requests are logged with the info level,
+// unmatched and invalid ones with the warn level and ones answered with 5xx statuses with the error level
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (level LogLevel) String() string {
+	if level < LevelDebug || level > LevelError {
+		return strconv.Itoa(int(level))
+	}
+	return levelNames[level]
+}
+
+// ParseLogLevel returns the level with the name
+func ParseLogLevel(name string) (LogLevel, error) {
+	for level, levelName := range levelNames {
+		if strings.ToLower(name) == levelName {
+			return LogLevel(level), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %s, expected one of %s", name, strings.Join(levelNames, ", "))
+}
+
+// leveledWriter writes entries with their levels like syslog does
+type leveledWriter interface {
+	WriteLevel(level LogLevel, entry []byte) error
+}
+
+// AccessLogger writes logs of requests handled by mock servers
+type AccessLogger struct {
+	format string
+	level  int32
+	mutex  sync.Mutex
+	out    io.Writer
+	// printf writes entries in the text format
+	printf func(format string, v ...interface{})
+	// initialLevel is the level passed on creation. ResetLevel returns to it
+	initialLevel LogLevel
+}
+
+// NewAccessLogger creates a logger, which writes entries in the format to the output
+func NewAccessLogger(format string, level LogLevel, out io.Writer) (*AccessLogger, error) {
+	switch format {
+	case AccessLogText, AccessLogJSON, AccessLogLogfmt, AccessLogCombined:
+	default:
+		return nil, fmt.Errorf("unknown access log format %s", format)
+	}
+
+	logger := &AccessLogger{format: format, level: int32(level), initialLevel: level, out: out}
+	logger.printf = log.New(out, "", log.LstdFlags).Printf
+	return logger, nil
+}
+
+// defaultAccessLogger writes entries in the text format by the standard logger
+func defaultAccessLogger() *AccessLogger {
+	return &AccessLogger{format: AccessLogText, level: int32(LevelInfo), initialLevel: LevelInfo, printf: log.Printf}
+}
+
+// Level returns the current level of the logger
+func (logger *AccessLogger) Level() LogLevel {
+	return LogLevel(atomic.LoadInt32(&logger.level))
+}
+
+// SetLevel changes the level of the logger. It's safe to call it while requests are logged
+func (logger *AccessLogger) SetLevel(level LogLevel) {
+	atomic.StoreInt32(&logger.level, int32(level))
+}
+
+// ResetLevel changes the level of the logger back to the one passed on creation
+func (logger *AccessLogger) ResetLevel() {
+	logger.SetLevel(logger.initialLevel)
+}
+
+func requestLevel(requestLog mockServer.RequestLog) LogLevel {
+	switch {
+	case requestLog.StatusCode >= 500:
+		return LevelError
+	case requestLog.Unmatched || len(requestLog.ValidationErrors) > 0:
+		return LevelWarn
+	default:
+		return LevelInfo
+	}
+}
+
+// Write logs the request, if its level isn't lower than the level of the logger
+func (logger *AccessLogger) Write(requestLog mockServer.RequestLog) {
+	level := requestLevel(requestLog)
+	if level < logger.Level() {
+		return
+	}
+
+	if logger.format == AccessLogText {
+		logger.writeText(requestLog, level)
+		return
+	}
+
+	var entry []byte
+	switch logger.format {
+	case AccessLogJSON:
+		entry = logger.formatJSON(requestLog, level)
+	case AccessLogLogfmt:
+		entry = formatLogfmt(requestLog, level)
+	case AccessLogCombined:
+		entry = formatCombined(requestLog)
+	}
+
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+	if out, ok := logger.out.(leveledWriter); ok {
+		out.WriteLevel(level, entry)
+		return
+	}
+	logger.out.Write(entry)
+}
+
+func (logger *AccessLogger) writeText(requestLog mockServer.RequestLog, level LogLevel) {
+	printf := logger.printf
+	if out, ok := logger.out.(leveledWriter); ok {
+		printf = func(format string, v ...interface{}) {
+			logger.mutex.Lock()
+			defer logger.mutex.Unlock()
+			out.WriteLevel(level, []byte(fmt.Sprintf(format, v...)))
+		}
+	}
+
+	printf("Requested %s \n", newReceivedRequest(requestLog, ""))
+	for _, validationError := range requestLog.ValidationErrors {
+		printf("Validation error: %s \n", validationError)
+	}
+}
+
+// accessLogEntry contains fields of structured access logs
+type accessLogEntry struct {
+	Time             string            `json:"time"`
+	Level            string            `json:"level"`
+	Server           string            `json:"server"`
+	Method           string            `json:"method"`
+	URL              string            `json:"url"`
+	Route            string            `json:"route"`
+	Status           int               `json:"status"`
+	LatencyMs        float64           `json:"latency_ms"`
+	Bytes            int64             `json:"bytes"`
+	RemoteAddr       string            `json:"remote_addr"`
+	UserAgent        string            `json:"user_agent,omitempty"`
+	Operation        string            `json:"operation,omitempty"`
+	Unmatched        bool              `json:"unmatched,omitempty"`
+	ValidationErrors []string          `json:"validation_errors,omitempty"`
+	Headers          map[string]string `json:"headers,omitempty"`
+}
+
+func newAccessLogEntry(requestLog mockServer.RequestLog, level LogLevel) accessLogEntry {
+	return accessLogEntry{
+		Time:             requestLog.Time.Format("2006-01-02T15:04:05.000Z07:00"),
+		Level:            level.String(),
+		Server:           requestLog.ServerName,
+		Method:           requestLog.Method,
+		URL:              requestLog.URL,
+		Route:            requestLog.Endpoint,
+		Status:           requestLog.StatusCode,
+		LatencyMs:        float64(requestLog.Duration/time.Microsecond) / 1000,
+		Bytes:            requestLog.Bytes,
+		RemoteAddr:       requestLog.RemoteAddr,
+		UserAgent:        requestLog.UserAgent,
+		Operation:        requestLog.Operation,
+		Unmatched:        requestLog.Unmatched,
+		ValidationErrors: requestLog.ValidationErrors,
+	}
+}
+
+// formatJSON adds request headers to entries, when the logger has the debug level. Credentials are redacted
+func (logger *AccessLogger) formatJSON(requestLog mockServer.RequestLog, level LogLevel) []byte {
+	entry := newAccessLogEntry(requestLog, level)
+	if logger.Level() == LevelDebug && len(requestLog.Header) > 0 {
+		entry.Headers = make(map[string]string)
+		for name, values := range redactHeaders(requestLog.Header) {
+			if len(values) > 0 {
+				entry.Headers[name] = values[0]
+			}
+		}
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return nil
+	}
+	return append(data, '\n')
+}
+
+func logfmtValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " =\"\t\n") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+func formatLogfmt(requestLog mockServer.RequestLog, level LogLevel) []byte {
+	entry := newAccessLogEntry(requestLog, level)
+	buffer := new(bytes.Buffer)
+
+	fmt.Fprintf(
+		buffer, "time=%s level=%s server=%s method=%s url=%s route=%s status=%d latency_ms=%s bytes=%d remote_addr=%s",
+		entry.Time, entry.Level, logfmtValue(entry.Server), logfmtValue(entry.Method), logfmtValue(entry.URL),
+		logfmtValue(entry.Route), entry.Status, strconv.FormatFloat(entry.LatencyMs, 'f', -1, 64), entry.Bytes,
+		logfmtValue(entry.RemoteAddr),
+	)
+	if entry.UserAgent != "" {
+		fmt.Fprintf(buffer, " user_agent=%s", logfmtValue(entry.UserAgent))
+	}
+	if entry.Operation != "" {
+		fmt.Fprintf(buffer, " operation=%s", logfmtValue(entry.Operation))
+	}
+	if entry.Unmatched {
+		buffer.WriteString(" unmatched=true")
+	}
+	if len(entry.ValidationErrors) > 0 {
+		fmt.Fprintf(buffer, " validation_errors=%s", logfmtValue(strings.Join(entry.ValidationErrors, "; ")))
+	}
+	buffer.WriteString("\n")
+
+	return buffer.Bytes()
+}
+
+// formatCombined formats the request like the combined log format of Apache
+func formatCombined(requestLog mockServer.RequestLog) []byte {
+	host, _, err := net.SplitHostPort(requestLog.RemoteAddr)
+	if err != nil {
+		host = requestLog.RemoteAddr
+	}
+	if host == "" {
+		host = "-"
+	}
+
+	size := "-"
+	if requestLog.Bytes > 0 {
+		size = strconv.FormatInt(requestLog.Bytes, 10)
+	}
+
+	referer := requestLog.Referer
+	if referer == "" {
+		referer = "-"
+	}
+	userAgent := requestLog.UserAgent
+	if userAgent == "" {
+		userAgent = "-"
+	}
+
+	return []byte(fmt.Sprintf(
+		"%s - - [%s] \"%s %s %s\" %d %s %s %s\n",
+		host, requestLog.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		requestLog.Method, requestLog.URL, requestLog.Proto, requestLog.StatusCode, size,
+		strconv.Quote(referer), strconv.Quote(userAgent),
+	))
+}
+
+type logLevelPayload struct {
+	Level string `json:"level"`
+}
+
+func writeLogLevel(w http.ResponseWriter, level LogLevel) {
+	payload, _ := json.Marshal(logLevelPayload{Level: level.String()})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(payload)
+}
+
+// GetLogLevelHandler returns the level of access logs
+func (logger *AccessLogger) GetLogLevelHandler(w http.ResponseWriter, req *http.Request) {
+	writeLogLevel(w, logger.Level())
+}
+
+// SetLogLevelHandler changes the level of access logs, which is passed like {"level": "warn"}
+func (logger *AccessLogger) SetLogLevelHandler(w http.ResponseWriter, req *http.Request) {
+	var payload logLevelPayload
+	err := json.NewDecoder(req.Body).Decode(&payload)
+
+	var level LogLevel
+	if err == nil {
+		level, err = ParseLogLevel(payload.Level)
+	}
+	if err != nil {
+		writeError(w, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	logger.SetLevel(level)
+	log.Printf("[Management] Log level is set to %s", level)
+	writeLogLevel(w, level)
+}
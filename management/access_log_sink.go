@@ -0,0 +1,114 @@
+package management
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// standardSink writes into stdout or stderr, which must not be closed
+type standardSink struct {
+	io.Writer
+}
+
+func (standardSink) Close() error {
+	return nil
+}
+
+// OpenAccessLogSink opens the output of access logs: stdout, stderr, syslog or a path of a file.
+// The file is rotated, when it exceeds maxSize bytes, and keeps the number of backups like file.1, file.2
+func OpenAccessLogSink(name string, maxSize int64, backups int) (io.WriteCloser, error) {
+	switch name {
+	case "stdout":
+		return standardSink{os.Stdout}, nil
+	case "stderr":
+		return standardSink{os.Stderr}, nil
+	case "syslog":
+		return openSyslogSink()
+	default:
+		return openRotatingFile(name, maxSize, backups)
+	}
+}
+
+// rotatingFile renames the file to a backup, when it grows too large, and begins a new one
+type rotatingFile struct {
+	mutex   sync.Mutex
+	path    string
+	maxSize int64
+	backups int
+	file    *os.File
+	size    int64
+}
+
+func openRotatingFile(path string, maxSize int64, backups int) (*rotatingFile, error) {
+	rotating := &rotatingFile{path: path, maxSize: maxSize, backups: backups}
+	if err := rotating.open(); err != nil {
+		return nil, err
+	}
+	return rotating, nil
+}
+
+func (rotating *rotatingFile) open() error {
+	file, err := os.OpenFile(rotating.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	rotating.file = file
+	rotating.size = info.Size()
+	return nil
+}
+
+func (rotating *rotatingFile) backup(number int) string {
+	return fmt.Sprintf("%s.%d", rotating.path, number)
+}
+
+func (rotating *rotatingFile) rotate() error {
+	if err := rotating.file.Close(); err != nil {
+		return err
+	}
+
+	if rotating.backups == 0 {
+		os.Remove(rotating.path)
+	} else {
+		os.Remove(rotating.backup(rotating.backups))
+		for number := rotating.backups - 1; number >= 1; number-- {
+			os.Rename(rotating.backup(number), rotating.backup(number+1))
+		}
+		if err := os.Rename(rotating.path, rotating.backup(1)); err != nil {
+			return err
+		}
+	}
+
+	return rotating.open()
+}
+
+// Write writes the entry into the current file. Entries are never split between files
+func (rotating *rotatingFile) Write(entry []byte) (int, error) {
+	rotating.mutex.Lock()
+	defer rotating.mutex.Unlock()
+
+	if rotating.maxSize > 0 && rotating.size > 0 && rotating.size+int64(len(entry)) > rotating.maxSize {
+		if err := rotating.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rotating.file.Write(entry)
+	rotating.size += int64(n)
+	return n, err
+}
+
+func (rotating *rotatingFile) Close() error {
+	rotating.mutex.Lock()
+	defer rotating.mutex.Unlock()
+
+	return rotating.file.Close()
+}
@@ -0,0 +1,36 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package management
+
+import (
+	"io"
+	"log/syslog"
+)
+
+// syslogSink writes entries with severities matching their levels, so they can be filtered by syslog
+type syslogSink struct {
+	*syslog.Writer
+}
+
+// openSyslogSink connects to the local syslog daemon
+func openSyslogSink() (io.WriteCloser, error) {
+	writer, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, "mimicro")
+	if err != nil {
+		return nil, err
+	}
+	return syslogSink{writer}, nil
+}
+
+func (sink syslogSink) WriteLevel(level LogLevel, entry []byte) error {
+	switch level {
+	case LevelDebug:
+		return sink.Debug(string(entry))
+	case LevelWarn:
+		return sink.Warning(string(entry))
+	case LevelError:
+		return sink.Err(string(entry))
+	default:
+		return sink.Info(string(entry))
+	}
+}
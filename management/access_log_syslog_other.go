@@ -0,0 +1,13 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package management
+
+import (
+	"errors"
+	"io"
+)
+
+func openSyslogSink() (io.WriteCloser, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
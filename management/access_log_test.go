@@ -0,0 +1,196 @@
+package management
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pokidovea/mimicro/mockServer"
+	"github.com/stretchr/testify/assert"
+)
+
+func accessLogRequest() mockServer.RequestLog {
+	return mockServer.RequestLog{
+		ServerName: "server_1",
+		URL:        "/users/1?full=true",
+		Method:     "GET",
+		StatusCode: http.StatusOK,
+		Endpoint:   "/users/{id}",
+		Duration:   1500 * time.Microsecond,
+		Time:       time.Date(2018, 3, 1, 12, 30, 15, 0, time.UTC),
+		RemoteAddr: "10.0.0.1:5123",
+		Proto:      "HTTP/1.1",
+		UserAgent:  "curl/7.58",
+		Referer:    "http://example.com/",
+		Header:     http.Header{"Accept": {"*/*"}},
+		Bytes:      42,
+	}
+}
+
+func writeAccessLog(t *testing.T, format string, level LogLevel, requestLog mockServer.RequestLog) string {
+	buffer := new(bytes.Buffer)
+	logger, err := NewAccessLogger(format, level, buffer)
+	assert.Nil(t, err)
+
+	logger.Write(requestLog)
+	return buffer.String()
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	assert.Equal(
+		t,
+		`{"time":"2018-03-01T12:30:15.000Z","level":"info","server":"server_1","method":"GET","url":"/users/1?full=true",`+
+			`"route":"/users/{id}","status":200,"latency_ms":1.5,"bytes":42,"remote_addr":"10.0.0.1:5123","user_agent":"curl/7.58"}`+"\n",
+		writeAccessLog(t, AccessLogJSON, LevelInfo, accessLogRequest()),
+	)
+
+	entry := writeAccessLog(t, AccessLogJSON, LevelDebug, accessLogRequest())
+	assert.Contains(t, entry, `"headers":{"Accept":"*/*"}`)
+
+	requestLog := accessLogRequest()
+	requestLog.Header = http.Header{"Accept": {"*/*"}, "Authorization": {"Bearer secret"}, "Cookie": {"session=secret"}}
+	entry = writeAccessLog(t, AccessLogJSON, LevelDebug, requestLog)
+	assert.Contains(t, entry, `"headers":{"Accept":"*/*","Authorization":"[REDACTED]","Cookie":"[REDACTED]"}`)
+	assert.NotContains(t, entry, "secret")
+}
+
+// leveledSink records levels of entries like syslog
+type leveledSink struct {
+	levels []LogLevel
+}
+
+func (sink *leveledSink) Write(entry []byte) (int, error) {
+	return 0, nil
+}
+
+func (sink *leveledSink) WriteLevel(level LogLevel, entry []byte) error {
+	sink.levels = append(sink.levels, level)
+	return nil
+}
+
+func TestAccessLogPassesLevelsToSink(t *testing.T) {
+	for _, format := range []string{AccessLogText, AccessLogJSON} {
+		sink := new(leveledSink)
+		logger, err := NewAccessLogger(format, LevelDebug, sink)
+		assert.Nil(t, err)
+
+		requestLog := accessLogRequest()
+		logger.Write(requestLog)
+		requestLog.Unmatched = true
+		logger.Write(requestLog)
+		requestLog.StatusCode = http.StatusInternalServerError
+		logger.Write(requestLog)
+
+		assert.Equal(t, []LogLevel{LevelInfo, LevelWarn, LevelError}, sink.levels, format)
+	}
+}
+
+func TestAccessLogLogfmt(t *testing.T) {
+	requestLog := accessLogRequest()
+	requestLog.UserAgent = "Mozilla/5.0 (X11)"
+	requestLog.ValidationErrors = []string{`query parameter "limit": Must be greater than or equal to 1`}
+
+	assert.Equal(
+		t,
+		`time=2018-03-01T12:30:15.000Z level=warn server=server_1 method=GET url="/users/1?full=true" route=/users/{id} `+
+			`status=200 latency_ms=1.5 bytes=42 remote_addr=10.0.0.1:5123 user_agent="Mozilla/5.0 (X11)" `+
+			`validation_errors="query parameter \"limit\": Must be greater than or equal to 1"`+"\n",
+		writeAccessLog(t, AccessLogLogfmt, LevelInfo, requestLog),
+	)
+}
+
+func TestAccessLogCombined(t *testing.T) {
+	assert.Equal(
+		t,
+		`10.0.0.1 - - [01/Mar/2018:12:30:15 +0000] "GET /users/1?full=true HTTP/1.1" 200 42 "http://example.com/" "curl/7.58"`+"\n",
+		writeAccessLog(t, AccessLogCombined, LevelInfo, accessLogRequest()),
+	)
+
+	requestLog := accessLogRequest()
+	requestLog.Bytes = 0
+	requestLog.Referer = ""
+	requestLog.UserAgent = ""
+	assert.Equal(
+		t,
+		`10.0.0.1 - - [01/Mar/2018:12:30:15 +0000] "GET /users/1?full=true HTTP/1.1" 200 - "-" "-"`+"\n",
+		writeAccessLog(t, AccessLogCombined, LevelInfo, requestLog),
+	)
+}
+
+func TestAccessLogText(t *testing.T) {
+	entry := writeAccessLog(t, AccessLogText, LevelInfo, accessLogRequest())
+
+	assert.True(t, strings.HasSuffix(
+		entry,
+		" Requested server: server_1; endpoint: /users/{id}; url: /users/1; method: GET; response status: 200 \n",
+	))
+}
+
+func TestAccessLogLevels(t *testing.T) {
+	requestLog := accessLogRequest()
+	assert.Equal(t, "", writeAccessLog(t, AccessLogJSON, LevelWarn, requestLog))
+
+	requestLog.Unmatched = true
+	assert.Contains(t, writeAccessLog(t, AccessLogJSON, LevelWarn, requestLog), `"level":"warn"`)
+
+	requestLog.StatusCode = http.StatusInternalServerError
+	assert.Contains(t, writeAccessLog(t, AccessLogJSON, LevelError, requestLog), `"level":"error"`)
+
+	_, err := ParseLogLevel("verbose")
+	assert.Equal(t, "unknown log level verbose, expected one of debug, info, warn, error", err.Error())
+
+	_, err = NewAccessLogger("xml", LevelInfo, ioutil.Discard)
+	assert.Equal(t, "unknown access log format xml", err.Error())
+}
+
+func TestLogLevelHandlers(t *testing.T) {
+	server := NewServer(4534, false)
+	router := server.createRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/logging/level", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"level":"info"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("PUT", "/api/v1/logging/level", strings.NewReader(`{"level": "WARN"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"level":"warn"}`, w.Body.String())
+	assert.Equal(t, LevelWarn, server.accessLogger.Level())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("PUT", "/api/v1/logging/level", strings.NewReader(`{"level": "loud"}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, LevelWarn, server.accessLogger.Level())
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "mimicro-logs")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "access.log")
+
+	sink, err := OpenAccessLogSink(path, 10, 2)
+	assert.Nil(t, err)
+
+	for _, entry := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err := sink.Write([]byte(entry))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, sink.Close())
+
+	read := func(name string) string {
+		data, _ := ioutil.ReadFile(filepath.Join(dir, name))
+		return string(data)
+	}
+	assert.Equal(t, "fourth\n", read("access.log"))
+	assert.Equal(t, "third\n", read("access.log.1"))
+	assert.Equal(t, "second\n", read("access.log.2"))
+	_, err = os.Stat(filepath.Join(dir, "access.log.3"))
+	assert.True(t, os.IsNotExist(err))
+}
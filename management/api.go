@@ -0,0 +1,143 @@
+package management
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// apiPrefix is a prefix of routes of the current version of the management API
+const apiPrefix = "/api/v1"
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func isAPIRequest(req *http.Request) bool {
+	return strings.HasPrefix(req.URL.Path, apiPrefix+"/")
+}
+
+// writeError answers requests to the API with JSON errors and requests to deprecated routes with plain text
+func writeError(w http.ResponseWriter, req *http.Request, statusCode int, message string) {
+	if !isAPIRequest(req) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(statusCode)
+		w.Write([]byte(message))
+		return
+	}
+
+	payload, _ := json.Marshal(apiError{Error: message})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(payload)
+}
+
+func notFoundHandler(w http.ResponseWriter, req *http.Request) {
+	if !isAPIRequest(req) {
+		http.NotFound(w, req)
+		return
+	}
+	writeError(w, req, http.StatusNotFound, fmt.Sprintf("%s is not found", req.URL.Path))
+}
+
+// methods are handlers of a resource by methods
+type methods map[string]http.HandlerFunc
+
+// handle registers handlers of the resource. Other methods are answered with 405 Method Not Allowed
+func handle(router *mux.Router, path string, handlers methods) {
+	allowed := make([]string, 0, len(handlers))
+	for method, handler := range handlers {
+		router.HandleFunc(path, handler).Methods(method)
+		allowed = append(allowed, method)
+	}
+	sort.Strings(allowed)
+
+	router.HandleFunc(path, func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeError(w, req, http.StatusMethodNotAllowed, fmt.Sprintf("method %s is not allowed", req.Method))
+	})
+}
+
+// deprecated marks responses of the old route with the route of the API, which replaces it,
+// and logs the first call to it
+func deprecated(method string, path string, handler http.HandlerFunc) http.HandlerFunc {
+	var once sync.Once
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		once.Do(func() {
+			log.Printf("[Management] %s %s is deprecated, use %s %s", req.Method, req.URL.Path, method, path)
+		})
+
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, path))
+		handler(w, req)
+	}
+}
+
+func (server *Server) getStatistics(w http.ResponseWriter, req *http.Request) {
+	if server.statisticsStorage == nil {
+		writeError(w, req, http.StatusNotFound, "statistics are not collected")
+		return
+	}
+	server.statisticsStorage.GetStatisticsHandler(w, req)
+}
+
+func (server *Server) deleteStatistics(w http.ResponseWriter, req *http.Request) {
+	if server.statisticsStorage == nil {
+		writeError(w, req, http.StatusNotFound, "statistics are not collected")
+		return
+	}
+	server.statisticsStorage.del(createRequestPatternFromQuery(req.URL))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// OpenAPIHandler serves the description of the management API
+func (server *Server) OpenAPIHandler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(openAPIDocument))
+}
+
+// registerAPI adds routes of the current version of the API
+func (server *Server) registerAPI(router *mux.Router) {
+	api := router.PathPrefix(apiPrefix).Subrouter()
+
+	handle(api, "/openapi.json", methods{"GET": server.OpenAPIHandler})
+	handle(api, "/servers", methods{"GET": server.ServersHandler})
+	handle(api, "/statistics", methods{"GET": server.getStatistics, "DELETE": server.deleteStatistics})
+	handle(api, "/requests/tail", methods{"GET": server.tail.TailHandler})
+	handle(api, "/overrides", methods{
+		"GET":    server.GetOverridesHandler,
+		"PUT":    server.SetOverrideHandler,
+		"DELETE": server.DeleteOverridesHandler,
+	})
+	handle(api, "/logging/level", methods{
+		"GET": server.accessLogger.GetLogLevelHandler,
+		"PUT": server.accessLogger.SetLogLevelHandler,
+	})
+	handle(api, "/state", methods{
+		"GET":    server.GetStateHandler,
+		"PUT":    server.SetStateHandler,
+		"DELETE": server.DeleteStateHandler,
+	})
+}
+
+// registerDeprecatedRoutes adds routes, which were used before the API was versioned
+func (server *Server) registerDeprecatedRoutes(router *mux.Router) {
+	if server.statisticsStorage == nil {
+		return
+	}
+
+	router.HandleFunc(
+		"/statistics/get", deprecated("GET", apiPrefix+"/statistics", server.statisticsStorage.GetRecordsHandler),
+	).Methods("GET")
+	router.HandleFunc(
+		"/statistics/reset", deprecated("DELETE", apiPrefix+"/statistics", server.statisticsStorage.DeleteStatisticsHandler),
+	).Methods("GET")
+}
@@ -0,0 +1,134 @@
+package management
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/pokidovea/mimicro/mockServer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPIDocumentDescribesAllRoutes(t *testing.T) {
+	var document struct {
+		Paths map[string]map[string]interface{} `json:"paths"`
+	}
+	assert.Nil(t, json.Unmarshal([]byte(openAPIDocument), &document))
+
+	server := NewServer(4534, true)
+	server.EnableMetrics()
+
+	routes := make(map[string]bool)
+	server.createRouter().Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err == nil && (strings.HasPrefix(path, apiPrefix+"/") || path == "/health" || path == "/ready" || path == "/metrics") {
+			routes[path] = true
+		}
+		return nil
+	})
+
+	for path := range routes {
+		assert.Contains(t, document.Paths, path)
+	}
+	for path := range document.Paths {
+		assert.True(t, routes[path], path)
+	}
+
+	w := serve(server.createRouter(), "GET", "/api/v1/openapi.json", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+}
+
+func TestStatisticsResource(t *testing.T) {
+	server := NewServer(4534, true)
+	router := server.createRouter()
+	server.WriteRequestLog(mockServer.RequestLog{ServerName: "server_1", URL: "/users", Method: "GET", StatusCode: 200})
+	server.WriteRequestLog(mockServer.RequestLog{ServerName: "server_2", URL: "/users", Method: "GET", StatusCode: 200})
+
+	w := serve(router, "GET", "/api/v1/statistics?server=server_1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"total":1`)
+
+	w = serve(router, "DELETE", "/api/v1/statistics?server=server_1", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, requestsCounter{
+		{ServerName: "server_2", URL: "/users", Method: "GET", StatusCode: 200}: 1,
+	}, server.statisticsStorage.counts())
+
+	w = serve(router, "GET", "/api/v1/statistics?group_by=server", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, `{"error":"group_by must be url or endpoint"}`, w.Body.String())
+
+	w = serve(NewServer(4534, false).createRouter(), "DELETE", "/api/v1/statistics", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, `{"error":"statistics are not collected"}`, w.Body.String())
+}
+
+func TestAPIErrors(t *testing.T) {
+	server := NewServer(4534, true)
+	router := server.createRouter()
+
+	w := serve(router, "POST", "/api/v1/statistics", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "DELETE, GET", w.Header().Get("Allow"))
+	assert.Equal(t, `{"error":"method POST is not allowed"}`, w.Body.String())
+
+	w = serve(router, "GET", "/api/v1/unknown", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, `{"error":"/api/v1/unknown is not found"}`, w.Body.String())
+
+	w = serve(router, "PUT", "/api/v1/logging/level", `{"level": "loud"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, `{"error":"unknown log level loud, expected one of debug, info, warn, error"}`, w.Body.String())
+
+	server.SetAuth(Auth{Token: "secret"})
+	w = serve(server.handler(), "GET", "/api/v1/servers", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `{"error":"Unauthorized"}`, w.Body.String())
+
+	// deprecated routes keep plain text errors
+	w = serve(router, "GET", "/unknown", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "404 page not found\n", w.Body.String())
+
+	w = serve(router, "GET", "/statistics/get?group_by=server", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "group_by must be url or endpoint", w.Body.String())
+}
+
+func TestDeprecatedRoutes(t *testing.T) {
+	router := NewServer(4534, true).createRouter()
+
+	w := serve(router, "GET", "/statistics/reset", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "OK", w.Body.String())
+	assert.Equal(t, "true", w.Header().Get("Deprecation"))
+	assert.Equal(t, `</api/v1/statistics>; rel="successor-version"`, w.Header().Get("Link"))
+
+	w = serve(router, "GET", "/api/v1/statistics", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "", w.Header().Get("Deprecation"))
+}
+
+func TestDeprecatedStatisticsKeepArray(t *testing.T) {
+	server := NewServer(4534, true)
+	server.statisticsStorage.add(ReceivedRequest{ServerName: "server_1", URL: "/users/1", Method: "GET", StatusCode: 200})
+	router := server.createRouter()
+
+	w := serve(router, "GET", "/statistics/get", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `[{"server":"server_1","url":"/users/1","method":"GET","count":1,"statuses":{"200":1}}]`, w.Body.String())
+
+	server.statisticsStorage.del(createRequestPatternFromQuery(&url.URL{}))
+	w = serve(router, "GET", "/statistics/get", "")
+	assert.Equal(t, "[]", w.Body.String())
+
+	// routes, which were added after the API was versioned, have no aliases
+	for _, path := range []string{"/servers", "/overrides", "/requests/tail", "/logging/level"} {
+		assert.Equal(t, http.StatusNotFound, serve(router, "GET", path, "").Code, path)
+	}
+}
@@ -0,0 +1,87 @@
+package management
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// publicRoutes are health checks, which probes call without credentials
+var publicRoutes = map[string]bool{"/health": true, "/ready": true}
+
+// tokenCookie keeps the token for the dashboard, because browsers can't send the Bearer header
+// from pages and event sources
+const tokenCookie = "mimicro_token"
+
+// Auth protects routes of the management server except health checks. When the token or the username is set,
+// requests must have the header "Authorization: Bearer <token>" or the basic credentials.
+// The dashboard opened as /dashboard?token=<token> keeps the token in a cookie
+type Auth struct {
+	Token    string
+	Username string
+	Password string
+}
+
+func (auth Auth) enabled() bool {
+	return auth.Token != "" || auth.Username != ""
+}
+
+func secureEqual(a string, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func (auth Auth) authorized(req *http.Request) bool {
+	if auth.Token != "" {
+		header := req.Header.Get("Authorization")
+		if strings.HasPrefix(header, "Bearer ") && secureEqual(strings.TrimPrefix(header, "Bearer "), auth.Token) {
+			return true
+		}
+		if cookie, err := req.Cookie(tokenCookie); err == nil {
+			if token, err := url.QueryUnescape(cookie.Value); err == nil && secureEqual(token, auth.Token) {
+				return true
+			}
+		}
+	}
+
+	if auth.Username != "" {
+		username, password, ok := req.BasicAuth()
+		// both are compared to not reveal which one is wrong by the time
+		usernameMatches := secureEqual(username, auth.Username)
+		passwordMatches := secureEqual(password, auth.Password)
+		if ok && usernameMatches && passwordMatches {
+			return true
+		}
+	}
+
+	return false
+}
+
+// wrap rejects unauthorized requests to the handler
+func (auth Auth) wrap(handler http.Handler) http.Handler {
+	if !auth.enabled() {
+		return handler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if publicRoutes[req.URL.Path] || auth.authorized(req) {
+			handler.ServeHTTP(w, req)
+			return
+		}
+
+		// the token is moved from the URL to a cookie, so it doesn't stay in the history of the browser
+		if auth.Token != "" && req.URL.Path == "/dashboard" && secureEqual(req.URL.Query().Get("token"), auth.Token) {
+			w.Header().Add("Set-Cookie", tokenCookie+"="+url.QueryEscape(auth.Token)+"; Path=/; HttpOnly; SameSite=Strict")
+			http.Redirect(w, req, "dashboard", http.StatusSeeOther)
+			return
+		}
+
+		// browsers ask for credentials only for the basic scheme, so the dashboard can be opened
+		if auth.Username != "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="mimicro"`)
+		} else {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="mimicro"`)
+		}
+		writeError(w, req, http.StatusUnauthorized, "Unauthorized")
+	})
+}
@@ -0,0 +1,95 @@
+package management
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuth(t *testing.T) {
+	for _, test := range []struct {
+		auth          Auth
+		authorization string
+		code          int
+	}{
+		{Auth{}, "", http.StatusOK},
+		{Auth{Token: "secret"}, "", http.StatusUnauthorized},
+		{Auth{Token: "secret"}, "Bearer secret", http.StatusOK},
+		{Auth{Token: "secret"}, "Bearer wrong", http.StatusUnauthorized},
+		{Auth{Token: "secret"}, "secret", http.StatusUnauthorized},
+		{Auth{Username: "admin", Password: "pass"}, "Basic YWRtaW46cGFzcw==", http.StatusOK},
+		{Auth{Username: "admin", Password: "pass"}, "Basic YWRtaW46d3Jvbmc=", http.StatusUnauthorized},
+		{Auth{Username: "admin", Password: "pass"}, "Bearer pass", http.StatusUnauthorized},
+		{Auth{Token: "secret", Username: "admin", Password: "pass"}, "Bearer secret", http.StatusOK},
+		{Auth{Token: "secret", Username: "admin", Password: "pass"}, "Basic YWRtaW46cGFzcw==", http.StatusOK},
+	} {
+		server := NewServer(4534, false)
+		server.SetAuth(test.auth)
+
+		req := httptest.NewRequest("GET", "/api/v1/servers", nil)
+		if test.authorization != "" {
+			req.Header.Set("Authorization", test.authorization)
+		}
+		w := httptest.NewRecorder()
+		server.handler().ServeHTTP(w, req)
+
+		assert.Equal(t, test.code, w.Code, test.authorization)
+	}
+}
+
+func TestAuthChallenge(t *testing.T) {
+	server := NewServer(4534, false)
+	server.SetAuth(Auth{Token: "secret"})
+	w := httptest.NewRecorder()
+	server.handler().ServeHTTP(w, httptest.NewRequest("GET", "/statistics/reset", nil))
+	assert.Equal(t, `Bearer realm="mimicro"`, w.Header().Get("WWW-Authenticate"))
+
+	server.SetAuth(Auth{Token: "secret", Username: "admin"})
+	w = httptest.NewRecorder()
+	server.handler().ServeHTTP(w, httptest.NewRequest("GET", "/dashboard", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Basic realm="mimicro"`, w.Header().Get("WWW-Authenticate"))
+}
+
+func TestHealthChecksDontRequireAuth(t *testing.T) {
+	server := NewServer(4534, false)
+	server.SetAuth(Auth{Token: "secret"})
+	server.SetReady(nil)
+
+	for _, path := range []string{"/health", "/ready"} {
+		w := httptest.NewRecorder()
+		server.handler().ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, http.StatusOK, w.Code, path)
+	}
+}
+
+func TestDashboardToken(t *testing.T) {
+	server := NewServer(4534, false)
+	server.SetAuth(Auth{Token: "secret value"})
+
+	w := httptest.NewRecorder()
+	server.handler().ServeHTTP(w, httptest.NewRequest("GET", "/dashboard?token=wrong", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	server.handler().ServeHTTP(w, httptest.NewRequest("GET", "/dashboard?token=secret+value", nil))
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "/dashboard", w.Header().Get("Location"))
+	cookie := w.Header().Get("Set-Cookie")
+	assert.Equal(t, "mimicro_token=secret+value; Path=/; HttpOnly; SameSite=Strict", cookie)
+
+	// the query token is accepted only to open the dashboard
+	w = httptest.NewRecorder()
+	server.handler().ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/servers?token=secret+value", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	for _, path := range []string{"/dashboard", "/api/v1/servers"} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Cookie", "mimicro_token=secret+value")
+		w = httptest.NewRecorder()
+		server.handler().ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, path)
+	}
+}
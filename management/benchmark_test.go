@@ -0,0 +1,127 @@
+package management
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pokidovea/mimicro/mockServer"
+)
+
+func benchmarkRequestLog(i int) mockServer.RequestLog {
+	return mockServer.RequestLog{
+		ServerName: "server_1",
+		URL:        "/users/" + strconv.Itoa(i%100),
+		Method:     "GET",
+		StatusCode: http.StatusOK,
+		Endpoint:   "/users/{id}",
+		Duration:   time.Millisecond,
+	}
+}
+
+func benchmarkWriteRequestLog(b *testing.B, server *Server) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	if server.statisticsStorage != nil {
+		server.statisticsStorage.Start()
+		defer server.statisticsStorage.Stop()
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			server.WriteRequestLog(benchmarkRequestLog(i))
+			i++
+		}
+	})
+	b.StopTimer()
+
+	if server.statisticsStorage != nil {
+		b.Logf("not saved %d of %d", server.statisticsStorage.droppedCount(), b.N)
+	}
+}
+
+func BenchmarkWriteRequestLogWithoutStatistics(b *testing.B) {
+	benchmarkWriteRequestLog(b, NewServer(0, false))
+}
+
+func BenchmarkWriteRequestLogWithStatistics(b *testing.B) {
+	benchmarkWriteRequestLog(b, NewServer(0, true))
+}
+
+func BenchmarkWriteRequestLogWithMetrics(b *testing.B) {
+	server := NewServer(0, true)
+	server.EnableMetrics()
+	benchmarkWriteRequestLog(b, server)
+}
+
+// slowPersistence falls behind, so changes are dropped instead of blocking requests
+type slowPersistence struct{}
+
+func (slowPersistence) Load() (map[ReceivedRequest]int, error) { return nil, nil }
+func (slowPersistence) Add(ReceivedRequest) error              { time.Sleep(time.Millisecond); return nil }
+func (slowPersistence) Delete([]ReceivedRequest) error         { return nil }
+func (slowPersistence) Compact(map[ReceivedRequest]int) error  { return nil }
+func (slowPersistence) Close() error                           { return nil }
+
+func BenchmarkWriteRequestLogWithSlowStorage(b *testing.B) {
+	server := NewServer(0, true)
+	server.SetStatisticsPersistence(slowPersistence{})
+	benchmarkWriteRequestLog(b, server)
+}
+
+// BenchmarkStatisticsAdd counts different requests in parallel, so they get into different shards
+func BenchmarkStatisticsAdd(b *testing.B) {
+	storage := newStatisticsStorage()
+	requests := make([]ReceivedRequest, 100)
+	for i := range requests {
+		requests[i] = ReceivedRequest{ServerName: "server_1", URL: "/users/" + strconv.Itoa(i), Method: "GET", StatusCode: http.StatusOK}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			storage.add(requests[i%len(requests)])
+			i++
+		}
+	})
+}
+
+// BenchmarkStatisticsAddWhileReading counts requests in parallel with reports, which read all the statistics
+func BenchmarkStatisticsAddWhileReading(b *testing.B) {
+	storage := newStatisticsStorage()
+	for i := 0; i < 1000; i++ {
+		storage.add(ReceivedRequest{ServerName: "server_1", URL: "/orders/" + strconv.Itoa(i), Method: "GET"})
+	}
+	request := ReceivedRequest{ServerName: "server_1", URL: "/some_url", Method: "GET", StatusCode: http.StatusOK}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				storage.filter(RequestFilter{}.pattern())
+			}
+		}
+	}()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			storage.add(request)
+		}
+	})
+}
@@ -0,0 +1,300 @@
+package management
+
+import (
+	"net/http"
+)
+
+// DashboardHandler serves the web UI. It's a single page without external assets, so it works offline
+func (server *Server) DashboardHandler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(dashboard))
+}
+
+var dashboard = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>mimicro</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; margin: 0; color: #222; }
+header { background: #2d3e50; color: #fff; padding: 10px 20px; font-size: 18px; }
+main { display: flex; flex-wrap: wrap; }
+section { flex: 1 1 45%; min-width: 420px; margin: 10px; border: 1px solid #ddd; border-radius: 4px; padding: 10px; }
+h2 { font-size: 16px; margin: 0 0 10px; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 3px 6px; border-bottom: 1px solid #eee; vertical-align: top; }
+input, select, textarea, button { font-size: 13px; margin: 2px; }
+textarea { width: 95%; height: 60px; font-family: monospace; }
+.muted { color: #888; }
+.error { color: #b00; }
+.status-2 { color: #070; } .status-3 { color: #07a; } .status-4 { color: #a60; } .status-5 { color: #b00; }
+#feed { font-family: monospace; font-size: 12px; }
+</style>
+</head>
+<body>
+<header>mimicro</header>
+<main>
+<section>
+  <h2>Servers</h2>
+  <div id="servers" class="muted">Loading...</div>
+</section>
+
+<section>
+  <h2>Overrides</h2>
+  <form id="override-form">
+    <select id="override-server"></select>
+    <select id="override-endpoint"></select>
+    <select id="override-method"><option value="">any method</option></select>
+    <input id="override-status" type="number" min="100" max="599" value="503" style="width: 60px">
+    <input id="override-content-type" placeholder="Content-Type" value="application/json">
+    <textarea id="override-body" placeholder="body"></textarea>
+    <button type="submit">Override</button>
+    <span id="override-error" class="error"></span>
+  </form>
+  <table>
+    <thead><tr><th>Server</th><th>Endpoint</th><th>Method</th><th>Session</th><th>Status</th><th>Body</th><th></th></tr></thead>
+    <tbody id="overrides"></tbody>
+  </table>
+</section>
+
+<section>
+  <h2>Statistics</h2>
+  <form id="reset-form">
+    <input id="reset-server" placeholder="server">
+    <input id="reset-endpoint" placeholder="endpoint">
+    <input id="reset-url" placeholder="url">
+    <input id="reset-method" placeholder="method">
+    <button type="submit">Reset</button>
+  </form>
+  <div id="statistics-error" class="muted"></div>
+  <table>
+    <thead><tr><th>Server</th><th>Endpoint</th><th>Method</th><th>Count</th><th>Statuses</th></tr></thead>
+    <tbody id="statistics"></tbody>
+  </table>
+</section>
+
+<section>
+  <h2>Live requests</h2>
+  <form id="feed-form">
+    <input id="feed-server" placeholder="server">
+    <input id="feed-url" placeholder="url">
+    <input id="feed-method" placeholder="method">
+    <button type="submit">Filter</button>
+    <button type="button" id="feed-clear">Clear</button>
+  </form>
+  <table>
+    <thead><tr><th>Time</th><th>Server</th><th>Method</th><th>URL</th><th>Status</th><th>ms</th></tr></thead>
+    <tbody id="feed"></tbody>
+  </table>
+</section>
+</main>
+
+<script>
+var servers = [];
+
+function $(id) { return document.getElementById(id); }
+
+function text(value) {
+  var div = document.createElement("div");
+  div.textContent = value === undefined || value === null ? "" : String(value);
+  return div.innerHTML;
+}
+
+function query(params) {
+  var parts = [];
+  for (var name in params) {
+    if (params[name] !== "") {
+      parts.push(encodeURIComponent(name) + "=" + encodeURIComponent(params[name]));
+    }
+  }
+  return parts.length ? "?" + parts.join("&") : "";
+}
+
+function request(method, url, body, callback) {
+  var xhr = new XMLHttpRequest();
+  xhr.open(method, url);
+  xhr.onload = function () {
+    var data = null;
+    if ((xhr.getResponseHeader("Content-Type") || "").indexOf("application/json") === 0) {
+      try {
+        data = JSON.parse(xhr.responseText);
+      } catch (e) {
+        data = null;
+      }
+    }
+    callback(xhr.status, data);
+  };
+  // network errors are passed with the status 0, so callers keep working when mimicro is restarted
+  xhr.onerror = function () {
+    callback(0, null);
+  };
+  xhr.send(body === null ? null : JSON.stringify(body));
+}
+
+function statusClass(status) {
+  return "status-" + String(status).charAt(0);
+}
+
+function loadServers() {
+  request("GET", "api/v1/servers", null, function (status, data) {
+    servers = data || [];
+    var html = "";
+    servers.forEach(function (server) {
+      html += "<p><b>" + text(server.name) + "</b> <span class='muted'>" + text(server.address) + "</span></p><table>";
+      server.endpoints.forEach(function (endpoint) {
+        html += "<tr><td>" + text(endpoint.endpoint) + "</td><td>" + text((endpoint.methods || []).join(", ")) + "</td></tr>";
+      });
+      html += "</table>";
+    });
+    $("servers").innerHTML = html || "No servers";
+    $("servers").className = "";
+
+    $("override-server").innerHTML = servers.map(function (server) {
+      return "<option>" + text(server.name) + "</option>";
+    }).join("");
+    fillEndpoints();
+  });
+}
+
+function selectedEndpoints() {
+  var endpoints = [];
+  servers.forEach(function (server) {
+    if (server.name === $("override-server").value) {
+      endpoints = server.endpoints;
+    }
+  });
+  return endpoints;
+}
+
+function fillEndpoints() {
+  $("override-endpoint").innerHTML = selectedEndpoints().map(function (endpoint) {
+    return "<option>" + text(endpoint.endpoint) + "</option>";
+  }).join("");
+  fillMethods();
+}
+
+function fillMethods() {
+  var methods = [];
+  selectedEndpoints().forEach(function (endpoint) {
+    if (endpoint.endpoint === $("override-endpoint").value) {
+      methods = endpoint.methods || [];
+    }
+  });
+  $("override-method").innerHTML = "<option value=''>any method</option>" + methods.map(function (method) {
+    return "<option>" + text(method) + "</option>";
+  }).join("");
+}
+
+function loadOverrides() {
+  request("GET", "api/v1/overrides", null, function (status, data) {
+    $("overrides").innerHTML = (data || []).map(function (override, i) {
+      return "<tr><td>" + text(override.server) + "</td><td>" + text(override.endpoint) + "</td><td>" +
+        text(override.method || "any") + "</td><td>" + text(override.session || "any") + "</td><td class='" + statusClass(override.status) + "'>" + text(override.status) +
+        "</td><td><code>" + text(override.body) + "</code></td><td><button data-index='" + i + "'>Delete</button></td></tr>";
+    }).join("");
+
+    Array.prototype.forEach.call($("overrides").getElementsByTagName("button"), function (button) {
+      var override = data[button.getAttribute("data-index")];
+      button.onclick = function () {
+        var params = query({server: override.server, endpoint: override.endpoint});
+        params += "&method=" + encodeURIComponent(override.method || "") + "&session=" + encodeURIComponent(override.session || "");
+        request("DELETE", "api/v1/overrides" + params, null, loadOverrides);
+      };
+    });
+  });
+}
+
+function loadStatistics() {
+  request("GET", "api/v1/statistics?group_by=endpoint", null, function (status, data) {
+    if (status === 404) {
+      $("statistics-error").textContent = "Statistics are not collected. Start mimicro with -collect-statistics.";
+      $("reset-form").style.display = "none";
+      return;
+    }
+    setTimeout(loadStatistics, 2000);
+    if (status !== 200) {
+      $("statistics-error").textContent = "Cannot load statistics: " +
+        (status === 0 ? "mimicro is not reachable" : (data && data.error) || "status " + status) + ". Retrying...";
+      return;
+    }
+    $("statistics-error").textContent = "";
+    $("statistics").innerHTML = ((data && data.records) || []).map(function (record) {
+      var statuses = Object.keys(record.statuses).map(function (code) {
+        return "<span class='" + statusClass(code) + "'>" + text(code) + ": " + text(record.statuses[code]) + "</span>";
+      }).join(" ");
+      return "<tr><td>" + text(record.server) + "</td><td>" + text(record.endpoint || "(unmatched)") + "</td><td>" +
+        text(record.method) + "</td><td>" + text(record.count) + "</td><td>" + statuses + "</td></tr>";
+    }).join("");
+  });
+}
+
+var feed = null;
+
+function openFeed() {
+  if (feed) {
+    feed.close();
+  }
+  var params = query({server: $("feed-server").value, url: $("feed-url").value, method: $("feed-method").value});
+  feed = new EventSource("api/v1/requests/tail" + params);
+  feed.addEventListener("request", function (message) {
+    var event = JSON.parse(message.data);
+    var row = document.createElement("tr");
+    row.innerHTML = "<td>" + text(new Date(event.time).toLocaleTimeString()) + "</td><td>" + text(event.server) +
+      "</td><td>" + text(event.method) + "</td><td>" + text(event.url) + "</td><td class='" + statusClass(event.status) +
+      "'>" + text(event.status) + "</td><td>" + text(event.latency_ms) + "</td>";
+    $("feed").insertBefore(row, $("feed").firstChild);
+    while ($("feed").children.length > 200) {
+      $("feed").removeChild($("feed").lastChild);
+    }
+  });
+}
+
+$("override-server").onchange = fillEndpoints;
+$("override-endpoint").onchange = fillMethods;
+
+$("override-form").onsubmit = function (e) {
+  e.preventDefault();
+  var override = {
+    server: $("override-server").value,
+    endpoint: $("override-endpoint").value,
+    method: $("override-method").value,
+    status: parseInt($("override-status").value, 10),
+    body: $("override-body").value
+  };
+  if ($("override-content-type").value) {
+    override.headers = {"Content-Type": $("override-content-type").value};
+  }
+  request("PUT", "api/v1/overrides", override, function (status, data) {
+    $("override-error").textContent = status === 200 ? "" : (data && data.error) || "status " + status;
+    loadOverrides();
+  });
+};
+
+$("reset-form").onsubmit = function (e) {
+  e.preventDefault();
+  var params = query({
+    server: $("reset-server").value,
+    endpoint: $("reset-endpoint").value,
+    url: $("reset-url").value,
+    method: $("reset-method").value
+  });
+  request("DELETE", "api/v1/statistics" + params, null, function () {});
+};
+
+$("feed-form").onsubmit = function (e) {
+  e.preventDefault();
+  openFeed();
+};
+$("feed-clear").onclick = function () {
+  $("feed").innerHTML = "";
+};
+
+loadServers();
+loadOverrides();
+loadStatistics();
+openFeed();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,164 @@
+package management
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// deletedValue marks a record, which deletes the key
+const deletedValue = ^uint32(0)
+
+// kvStore is a minimal embedded key-value store. Every change is appended to the file as a record
+// and the latest record of a key wins. Compaction rewrites the file with live keys only.
+// A record consists of a checksum, lengths of the key and the value, the key and the value
+type kvStore struct {
+	path   string
+	file   *os.File
+	values map[string][]byte
+}
+
+func openKVStore(path string) (*kvStore, error) {
+	store := &kvStore{path: path, values: make(map[string][]byte)}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := store.read(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// cut off a record, which was written partially
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(size, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	store.file = file
+	return store, nil
+}
+
+// read loads records of the file and returns the size of its valid part.
+// A record, which is longer than the rest of the file, is a corrupt tail like a partially written one
+func (store *kvStore) read(file *os.File) (int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	reader := bufio.NewReader(file)
+	var size int64
+
+	for {
+		header := make([]byte, 12)
+		if _, err := io.ReadFull(reader, header); err != nil {
+			return size, nil
+		}
+
+		checksum := binary.BigEndian.Uint32(header[0:4])
+		keyLength := binary.BigEndian.Uint32(header[4:8])
+		valueLength := binary.BigEndian.Uint32(header[8:12])
+
+		bodyLength := int64(keyLength)
+		if valueLength != deletedValue {
+			bodyLength += int64(valueLength)
+		}
+		if bodyLength > info.Size()-size-int64(len(header)) {
+			return size, nil
+		}
+		body := make([]byte, bodyLength)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			return size, nil
+		}
+
+		if crc32.ChecksumIEEE(append(header[4:12:12], body...)) != checksum {
+			if _, err := reader.Peek(1); err == io.EOF {
+				return size, nil
+			}
+			return 0, errors.New("statistics file " + store.path + " is corrupted")
+		}
+
+		key := string(body[:keyLength])
+		if valueLength == deletedValue {
+			delete(store.values, key)
+		} else {
+			store.values[key] = body[keyLength:]
+		}
+		size += int64(len(header)) + bodyLength
+	}
+}
+
+func encodeRecord(key string, value []byte, deleted bool) []byte {
+	valueLength := uint32(len(value))
+	if deleted {
+		valueLength = deletedValue
+	}
+
+	record := make([]byte, 12, 12+len(key)+len(value))
+	binary.BigEndian.PutUint32(record[4:8], uint32(len(key)))
+	binary.BigEndian.PutUint32(record[8:12], valueLength)
+	record = append(record, key...)
+	record = append(record, value...)
+	binary.BigEndian.PutUint32(record[0:4], crc32.ChecksumIEEE(record[4:]))
+
+	return record
+}
+
+func (store *kvStore) get(key string) ([]byte, bool) {
+	value, ok := store.values[key]
+	return value, ok
+}
+
+func (store *kvStore) put(key string, value []byte) error {
+	if _, err := store.file.Write(encodeRecord(key, value, false)); err != nil {
+		return err
+	}
+	store.values[key] = value
+	return nil
+}
+
+func (store *kvStore) delete(key string) error {
+	if _, ok := store.values[key]; !ok {
+		return nil
+	}
+	if _, err := store.file.Write(encodeRecord(key, nil, true)); err != nil {
+		return err
+	}
+	delete(store.values, key)
+	return nil
+}
+
+// compact rewrites the file with the latest values of keys
+func (store *kvStore) compact() error {
+	err := rewriteFile(store.path, func(writer *bufio.Writer) error {
+		for key, value := range store.values {
+			if _, err := writer.Write(encodeRecord(key, value, false)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the old file is replaced, so the new one should be opened
+	store.file.Close()
+	store.file, err = os.OpenFile(store.path, os.O_WRONLY|os.O_APPEND, 0644)
+	return err
+}
+
+func (store *kvStore) close() error {
+	return store.file.Close()
+}
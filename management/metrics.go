@@ -0,0 +1,196 @@
+package management
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pokidovea/mimicro/mockServer"
+)
+
+// latencyBuckets are upper bounds of the latency histogram in seconds
+var latencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metricMethods are methods, which are kept in labels. Others are replaced by OTHER,
+// so clients can't create unlimited series by sending arbitrary methods
+var metricMethods = map[string]bool{
+	"GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true,
+	"DELETE": true, "CONNECT": true, "OPTIONS": true, "TRACE": true,
+}
+
+func metricMethod(method string) string {
+	if metricMethods[method] {
+		return method
+	}
+	return "OTHER"
+}
+
+// metricLabels identify a series of request metrics
+type metricLabels struct {
+	server   string
+	endpoint string
+	method   string
+	status   string
+}
+
+func (labels metricLabels) String() string {
+	return fmt.Sprintf(
+		`server="%s",endpoint="%s",method="%s",status="%s"`,
+		escapeLabel(labels.server), escapeLabel(labels.endpoint), escapeLabel(labels.method), escapeLabel(labels.status),
+	)
+}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelReplacer.Replace(value)
+}
+
+type histogram struct {
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+func (h *histogram) observe(value float64) {
+	for i, bound := range latencyBuckets {
+		if value <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+// metrics are collected in the Prometheus format
+type metrics struct {
+	mutex     sync.Mutex
+	requests  map[metricLabels]uint64
+	latencies map[metricLabels]*histogram
+	inFlight  map[string]int64
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		requests:  make(map[metricLabels]uint64),
+		latencies: make(map[metricLabels]*histogram),
+		inFlight:  make(map[string]int64),
+	}
+}
+
+func (m *metrics) observe(requestLog mockServer.RequestLog) {
+	labels := metricLabels{
+		server:   requestLog.ServerName,
+		endpoint: requestLog.Endpoint,
+		method:   metricMethod(requestLog.Method),
+		status:   strconv.Itoa(requestLog.StatusCode),
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.requests[labels]++
+
+	latency, ok := m.latencies[labels]
+	if !ok {
+		latency = &histogram{buckets: make([]uint64, len(latencyBuckets))}
+		m.latencies[labels] = latency
+	}
+	latency.observe(requestLog.Duration.Seconds())
+}
+
+func (m *metrics) addInFlight(serverName string, delta int64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.inFlight[serverName] += delta
+}
+
+// trackInFlight returns a middleware, which counts requests being handled by the server
+func (m *metrics) trackInFlight(serverName string) mockServer.Middleware {
+	m.addInFlight(serverName, 0)
+
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			m.addInFlight(serverName, 1)
+			defer m.addInFlight(serverName, -1)
+
+			handler.ServeHTTP(w, req)
+		})
+	}
+}
+
+func sortedLabels(series map[metricLabels]uint64) []metricLabels {
+	labels := make([]metricLabels, 0, len(series))
+	for label := range series {
+		labels = append(labels, label)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].String() < labels[j].String()
+	})
+	return labels
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// write renders metrics in the Prometheus text format
+func (m *metrics) write(buffer *bytes.Buffer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	labels := sortedLabels(m.requests)
+
+	buffer.WriteString("# HELP mimicro_requests_total Number of requests received by mock servers.\n")
+	buffer.WriteString("# TYPE mimicro_requests_total counter\n")
+	for _, label := range labels {
+		fmt.Fprintf(buffer, "mimicro_requests_total{%s} %d\n", label, m.requests[label])
+	}
+
+	buffer.WriteString("# HELP mimicro_request_duration_seconds Time of handling requests by mock servers.\n")
+	buffer.WriteString("# TYPE mimicro_request_duration_seconds histogram\n")
+	for _, label := range labels {
+		latency := m.latencies[label]
+		for i, bound := range latencyBuckets {
+			fmt.Fprintf(
+				buffer, "mimicro_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n",
+				label, formatFloat(bound), latency.buckets[i],
+			)
+		}
+		fmt.Fprintf(buffer, "mimicro_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", label, latency.count)
+		fmt.Fprintf(buffer, "mimicro_request_duration_seconds_sum{%s} %s\n", label, formatFloat(latency.sum))
+		fmt.Fprintf(buffer, "mimicro_request_duration_seconds_count{%s} %d\n", label, latency.count)
+	}
+
+	servers := make([]string, 0, len(m.inFlight))
+	for server := range m.inFlight {
+		servers = append(servers, server)
+	}
+	sort.Strings(servers)
+
+	buffer.WriteString("# HELP mimicro_requests_in_flight Number of requests being handled by mock servers.\n")
+	buffer.WriteString("# TYPE mimicro_requests_in_flight gauge\n")
+	for _, server := range servers {
+		fmt.Fprintf(buffer, "mimicro_requests_in_flight{server=\"%s\"} %d\n", escapeLabel(server), m.inFlight[server])
+	}
+}
+
+// writeInjectedFaults renders numbers of active overrides of servers
+func writeInjectedFaults(buffer *bytes.Buffer, counts map[string]int) {
+	servers := make([]string, 0, len(counts))
+	for server := range counts {
+		servers = append(servers, server)
+	}
+	sort.Strings(servers)
+
+	buffer.WriteString("# HELP mimicro_injected_faults Number of active overrides of responses of mock servers.\n")
+	buffer.WriteString("# TYPE mimicro_injected_faults gauge\n")
+	for _, server := range servers {
+		fmt.Fprintf(buffer, "mimicro_injected_faults{server=\"%s\"} %d\n", escapeLabel(server), counts[server])
+	}
+}
@@ -0,0 +1,131 @@
+package management
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pokidovea/mimicro/mockServer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsWrite(t *testing.T) {
+	m := newMetrics()
+
+	m.observe(mockServer.RequestLog{
+		ServerName: "server_1",
+		Method:     "GET",
+		StatusCode: http.StatusOK,
+		Endpoint:   "/users/{id}",
+		Duration:   20 * time.Millisecond,
+	})
+	m.observe(mockServer.RequestLog{
+		ServerName: "server_1",
+		Method:     "GET",
+		StatusCode: http.StatusOK,
+		Endpoint:   "/users/{id}",
+		Duration:   3 * time.Second,
+	})
+	m.observe(mockServer.RequestLog{
+		ServerName: "server_1",
+		Method:     "POST",
+		StatusCode: http.StatusNotFound,
+		Unmatched:  true,
+	})
+	m.observe(mockServer.RequestLog{
+		ServerName: "server_1",
+		Method:     "RANDOM1",
+		StatusCode: http.StatusNotFound,
+		Unmatched:  true,
+	})
+
+	buffer := new(bytes.Buffer)
+	m.write(buffer)
+	output := buffer.String()
+
+	assert.Contains(t, output, "# TYPE mimicro_requests_total counter\n")
+	assert.Contains(t, output, `mimicro_requests_total{server="server_1",endpoint="/users/{id}",method="GET",status="200"} 2`+"\n")
+	assert.Contains(t, output, `mimicro_requests_total{server="server_1",endpoint="",method="POST",status="404"} 1`+"\n")
+	assert.Contains(t, output, `mimicro_requests_total{server="server_1",endpoint="",method="OTHER",status="404"} 1`+"\n")
+	assert.NotContains(t, output, "RANDOM1")
+
+	labels := `server="server_1",endpoint="/users/{id}",method="GET",status="200"`
+	assert.Contains(t, output, "# TYPE mimicro_request_duration_seconds histogram\n")
+	assert.Contains(t, output, "mimicro_request_duration_seconds_bucket{"+labels+`,le="0.01"} 0`+"\n")
+	assert.Contains(t, output, "mimicro_request_duration_seconds_bucket{"+labels+`,le="0.025"} 1`+"\n")
+	assert.Contains(t, output, "mimicro_request_duration_seconds_bucket{"+labels+`,le="5"} 2`+"\n")
+	assert.Contains(t, output, "mimicro_request_duration_seconds_bucket{"+labels+`,le="+Inf"} 2`+"\n")
+	assert.Contains(t, output, "mimicro_request_duration_seconds_sum{"+labels+"} 3.02\n")
+	assert.Contains(t, output, "mimicro_request_duration_seconds_count{"+labels+"} 2\n")
+
+	// series are sorted, so the output is stable
+	assert.True(t, strings.Index(output, `endpoint=""`) < strings.Index(output, `endpoint="/users/{id}"`))
+}
+
+func TestMetricsEscapeLabels(t *testing.T) {
+	labels := metricLabels{server: `a"b`, endpoint: `^/x\d+$`, method: "GET", status: "200"}
+
+	assert.Equal(t, `server="a\"b",endpoint="^/x\\d+$",method="GET",status="200"`, labels.String())
+}
+
+func TestInFlightMiddleware(t *testing.T) {
+	server := NewServer(4534, false)
+	server.EnableMetrics()
+
+	var inFlight string
+	handler := server.InFlightMiddleware("server_1")(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		buffer := new(bytes.Buffer)
+		server.metrics.write(buffer)
+		inFlight = buffer.String()
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.Contains(t, inFlight, `mimicro_requests_in_flight{server="server_1"} 1`+"\n")
+
+	buffer := new(bytes.Buffer)
+	server.metrics.write(buffer)
+	assert.Contains(t, buffer.String(), `mimicro_requests_in_flight{server="server_1"} 0`+"\n")
+}
+
+func TestMetricsHandler(t *testing.T) {
+	server := NewServer(4534, false)
+	router := server.createRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	server.EnableMetrics()
+	server.WriteRequestLog(mockServer.RequestLog{ServerName: "server_1", Method: "GET", StatusCode: http.StatusOK})
+	router = server.createRouter()
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/plain; version=0.0.4", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `mimicro_requests_total{server="server_1",endpoint="",method="GET",status="200"} 1`)
+}
+
+func TestMetricsOfInjectedFaults(t *testing.T) {
+	server := newServerWithMockServers(t)
+	server.EnableMetrics()
+	router := server.createRouter()
+
+	w := serve(router, "GET", "/metrics", "")
+	assert.Contains(t, w.Body.String(), "# TYPE mimicro_injected_faults gauge\n")
+	assert.Contains(t, w.Body.String(), `mimicro_injected_faults{server="server_1"} 0`+"\n")
+
+	serve(router, "PUT", "/api/v1/overrides", `{"server": "server_1", "endpoint": "/users/{id}", "status": 503}`)
+	serve(router, "PUT", "/api/v1/overrides", `{"server": "server_1", "endpoint": "/users/{id}", "status": 404, "session": "test-1"}`)
+
+	w = serve(router, "GET", "/metrics", "")
+	assert.Contains(t, w.Body.String(), `mimicro_injected_faults{server="server_1"} 2`+"\n")
+
+	serve(router, "DELETE", "/api/v1/overrides", "")
+
+	w = serve(router, "GET", "/metrics", "")
+	assert.Contains(t, w.Body.String(), `mimicro_injected_faults{server="server_1"} 0`+"\n")
+}
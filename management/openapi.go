@@ -0,0 +1,371 @@
+package management
+
+// openAPIDocument describes the management API. It's served on /api/v1/openapi.json
+var openAPIDocument = `
+{
+    "openapi": "3.0.0",
+    "info": {
+        "title": "mimicro management API",
+        "version": "1.0.0",
+        "description": "Statistics, live requests and runtime configuration of mimicro mock servers. Routes outside of /api/v1 except health checks, metrics and the dashboard are deprecated."
+    },
+    "security": [{}, {"bearerAuth": []}, {"basicAuth": []}],
+    "paths": {
+        "/health": {
+            "get": {
+                "summary": "Check that the management server is alive",
+                "responses": {
+                    "200": {"description": "The server is alive", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Health"}}}}
+                }
+            }
+        },
+        "/ready": {
+            "get": {
+                "summary": "Check that all mock servers are bound",
+                "responses": {
+                    "200": {"description": "Mock servers are ready", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Health"}}}},
+                    "503": {"description": "Mock servers are starting or stopping", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Health"}}}}
+                }
+            }
+        },
+        "/metrics": {
+            "get": {
+                "summary": "Get Prometheus metrics, when they are enabled by -metrics",
+                "responses": {
+                    "200": {"description": "Metrics in the Prometheus text format", "content": {"text/plain": {"schema": {"type": "string"}}}},
+                    "404": {"description": "Metrics are not enabled"}
+                }
+            }
+        },
+        "/api/v1/openapi.json": {
+            "get": {
+                "summary": "Get this document",
+                "responses": {
+                    "200": {"description": "The OpenAPI document", "content": {"application/json": {"schema": {"type": "object"}}}}
+                }
+            }
+        },
+        "/api/v1/servers": {
+            "get": {
+                "summary": "List mock servers with their endpoints",
+                "responses": {
+                    "200": {
+                        "description": "Mock servers",
+                        "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/ServerInfo"}}}}
+                    },
+                    "401": {"$ref": "#/components/responses/Unauthorized"}
+                }
+            }
+        },
+        "/api/v1/statistics": {
+            "get": {
+                "summary": "Get statistics of requests, when they are collected",
+                "parameters": [
+                    {"$ref": "#/components/parameters/server"},
+                    {"$ref": "#/components/parameters/endpoint"},
+                    {"$ref": "#/components/parameters/url"},
+                    {"$ref": "#/components/parameters/method"},
+                    {"$ref": "#/components/parameters/unmatched"},
+                    {"$ref": "#/components/parameters/session"},
+                    {"name": "group_by", "in": "query", "schema": {"type": "string", "enum": ["url", "endpoint"], "default": "url"}},
+                    {"name": "format", "in": "query", "schema": {"type": "string", "enum": ["json", "csv"], "default": "json"}},
+                    {"name": "offset", "in": "query", "schema": {"type": "integer", "minimum": 0, "default": 0}},
+                    {"name": "limit", "in": "query", "description": "0 means all records", "schema": {"type": "integer", "minimum": 0, "default": 0}}
+                ],
+                "responses": {
+                    "200": {
+                        "description": "A page of statistics records",
+                        "content": {
+                            "application/json": {"schema": {"$ref": "#/components/schemas/StatisticsReport"}},
+                            "text/csv": {"schema": {"type": "string"}}
+                        }
+                    },
+                    "400": {"$ref": "#/components/responses/Error"},
+                    "401": {"$ref": "#/components/responses/Unauthorized"},
+                    "404": {"$ref": "#/components/responses/Error"}
+                }
+            },
+            "delete": {
+                "summary": "Reset statistics of requests, which match the filter",
+                "parameters": [
+                    {"$ref": "#/components/parameters/server"},
+                    {"$ref": "#/components/parameters/endpoint"},
+                    {"$ref": "#/components/parameters/url"},
+                    {"$ref": "#/components/parameters/method"},
+                    {"$ref": "#/components/parameters/unmatched"},
+                    {"$ref": "#/components/parameters/session"}
+                ],
+                "responses": {
+                    "204": {"description": "Statistics are reset"},
+                    "401": {"$ref": "#/components/responses/Unauthorized"},
+                    "404": {"$ref": "#/components/responses/Error"}
+                }
+            }
+        },
+        "/api/v1/requests/tail": {
+            "get": {
+                "summary": "Stream requests and their responses as server-sent events named request",
+                "parameters": [
+                    {"$ref": "#/components/parameters/server"},
+                    {"$ref": "#/components/parameters/endpoint"},
+                    {"$ref": "#/components/parameters/url"},
+                    {"$ref": "#/components/parameters/method"},
+                    {"$ref": "#/components/parameters/unmatched"},
+                    {"$ref": "#/components/parameters/session"}
+                ],
+                "responses": {
+                    "200": {
+                        "description": "A stream of events, which data is a TailEvent",
+                        "content": {"text/event-stream": {"schema": {"type": "string"}}}
+                    },
+                    "401": {"$ref": "#/components/responses/Unauthorized"},
+                    "503": {"$ref": "#/components/responses/Error"}
+                }
+            }
+        },
+        "/api/v1/overrides": {
+            "get": {
+                "summary": "List overrides of responses",
+                "responses": {
+                    "200": {
+                        "description": "Overrides",
+                        "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/ResponseOverride"}}}}
+                    },
+                    "401": {"$ref": "#/components/responses/Unauthorized"}
+                }
+            },
+            "put": {
+                "summary": "Override responses of an endpoint or replace the override of the same endpoint and method",
+                "requestBody": {
+                    "required": true,
+                    "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ResponseOverride"}}}
+                },
+                "responses": {
+                    "200": {"description": "The override", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ResponseOverride"}}}},
+                    "400": {"$ref": "#/components/responses/Error"},
+                    "401": {"$ref": "#/components/responses/Unauthorized"}
+                }
+            },
+            "delete": {
+                "summary": "Delete overrides, which match the filter, or all of them",
+                "parameters": [
+                    {"$ref": "#/components/parameters/server"},
+                    {"$ref": "#/components/parameters/endpoint"},
+                    {"name": "method", "in": "query", "description": "An empty value matches overrides of all methods", "schema": {"type": "string"}},
+                    {"name": "session", "in": "query", "description": "An empty value matches overrides of all sessions", "schema": {"type": "string"}}
+                ],
+                "responses": {
+                    "204": {"description": "Overrides are deleted"},
+                    "401": {"$ref": "#/components/responses/Unauthorized"}
+                }
+            }
+        },
+        "/api/v1/logging/level": {
+            "get": {
+                "summary": "Get the level of access logs",
+                "responses": {
+                    "200": {"description": "The level", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/LogLevel"}}}},
+                    "401": {"$ref": "#/components/responses/Unauthorized"}
+                }
+            },
+            "put": {
+                "summary": "Change the level of access logs",
+                "requestBody": {
+                    "required": true,
+                    "content": {"application/json": {"schema": {"$ref": "#/components/schemas/LogLevel"}}}
+                },
+                "responses": {
+                    "200": {"description": "The new level", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/LogLevel"}}}},
+                    "400": {"$ref": "#/components/responses/Error"},
+                    "401": {"$ref": "#/components/responses/Unauthorized"}
+                }
+            }
+        },
+        "/api/v1/state": {
+            "get": {
+                "summary": "Export statistics, overrides and the level of access logs",
+                "responses": {
+                    "200": {"description": "The state", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/State"}}}},
+                    "401": {"$ref": "#/components/responses/Unauthorized"}
+                }
+            },
+            "put": {
+                "summary": "Replace the state with the exported one",
+                "requestBody": {
+                    "required": true,
+                    "content": {"application/json": {"schema": {"$ref": "#/components/schemas/State"}}}
+                },
+                "responses": {
+                    "200": {"description": "The new state", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/State"}}}},
+                    "400": {"$ref": "#/components/responses/Error"},
+                    "401": {"$ref": "#/components/responses/Unauthorized"}
+                }
+            },
+            "delete": {
+                "summary": "Reset the state to the one loaded from the config: delete statistics and overrides and restore the level of access logs",
+                "responses": {
+                    "204": {"description": "The state is reset"},
+                    "401": {"$ref": "#/components/responses/Unauthorized"}
+                }
+            }
+        }
+    },
+    "components": {
+        "securitySchemes": {
+            "bearerAuth": {"type": "http", "scheme": "bearer", "description": "Required, when mimicro runs with -management-token"},
+            "basicAuth": {"type": "http", "scheme": "basic", "description": "Required, when mimicro runs with -management-basic-auth"}
+        },
+        "parameters": {
+            "server": {"name": "server", "in": "query", "description": "A name of the mock server", "schema": {"type": "string"}},
+            "endpoint": {"name": "endpoint", "in": "query", "description": "A url or a regex of the endpoint like in the config", "schema": {"type": "string"}},
+            "url": {"name": "url", "in": "query", "description": "A requested path without the query", "schema": {"type": "string"}},
+            "method": {"name": "method", "in": "query", "description": "A method in any case", "schema": {"type": "string"}},
+            "unmatched": {"name": "unmatched", "in": "query", "description": "Only requests, which didn't match any endpoint", "schema": {"type": "boolean"}},
+            "session": {"name": "session", "in": "query", "description": "A value of the session header, X-Mimicro-Session by default", "schema": {"type": "string"}}
+        },
+        "responses": {
+            "Error": {"description": "An error", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+            "Unauthorized": {"description": "The token or the credentials are missing or wrong", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+        },
+        "schemas": {
+            "Error": {
+                "type": "object",
+                "required": ["error"],
+                "properties": {"error": {"type": "string"}}
+            },
+            "Health": {
+                "type": "object",
+                "properties": {
+                    "status": {"type": "string", "enum": ["starting", "ready", "stopping"]},
+                    "servers": {
+                        "type": "array",
+                        "items": {"type": "object", "properties": {"name": {"type": "string"}, "address": {"type": "string"}}}
+                    }
+                }
+            },
+            "ServerInfo": {
+                "type": "object",
+                "properties": {
+                    "name": {"type": "string"},
+                    "address": {"type": "string", "description": "Empty until the server is bound"},
+                    "endpoints": {
+                        "type": "array",
+                        "items": {
+                            "type": "object",
+                            "properties": {"endpoint": {"type": "string"}, "methods": {"type": "array", "items": {"type": "string"}}}
+                        }
+                    }
+                }
+            },
+            "StatisticsRecord": {
+                "type": "object",
+                "properties": {
+                    "server": {"type": "string"},
+                    "endpoint": {"type": "string"},
+                    "url": {"type": "string", "description": "Empty, when records are grouped by the endpoint"},
+                    "method": {"type": "string"},
+                    "count": {"type": "integer"},
+                    "statuses": {"type": "object", "description": "Numbers of requests by response statuses", "additionalProperties": {"type": "integer"}},
+                    "validation_failed": {"type": "boolean"},
+                    "operation": {"type": "string"},
+                    "unmatched": {"type": "boolean"},
+                    "session": {"type": "string", "description": "Empty for requests without the session header"}
+                }
+            },
+            "StatisticsReport": {
+                "type": "object",
+                "properties": {
+                    "records": {"type": "array", "items": {"$ref": "#/components/schemas/StatisticsRecord"}},
+                    "total": {"type": "integer", "description": "A number of records on all pages"},
+                    "offset": {"type": "integer"},
+                    "limit": {"type": "integer"},
+                    "totals": {
+                        "type": "array",
+                        "items": {
+                            "type": "object",
+                            "properties": {"server": {"type": "string"}, "method": {"type": "string"}, "count": {"type": "integer"}}
+                        }
+                    },
+                    "dropped": {"type": "integer", "description": "A number of requests, which were not saved by the persistence"}
+                }
+            },
+            "TailEvent": {
+                "type": "object",
+                "properties": {
+                    "time": {"type": "string", "format": "date-time"},
+                    "server": {"type": "string"},
+                    "endpoint": {"type": "string"},
+                    "url": {"type": "string"},
+                    "method": {"type": "string"},
+                    "headers": {"type": "object", "additionalProperties": {"type": "array", "items": {"type": "string"}}, "description": "Values of credentials like Authorization and Cookie are redacted"},
+                    "remote_addr": {"type": "string"},
+                    "status": {"type": "integer"},
+                    "bytes": {"type": "integer"},
+                    "latency_ms": {"type": "number"},
+                    "validation_errors": {"type": "array", "items": {"type": "string"}},
+                    "operation": {"type": "string"},
+                    "unmatched": {"type": "boolean"},
+                    "session": {"type": "string", "description": "Empty for requests without the session header"},
+                    "response_headers": {"type": "object", "additionalProperties": {"type": "array", "items": {"type": "string"}}},
+                    "response_body": {"type": "string", "description": "The first 4096 bytes of the response body"},
+                    "response_body_truncated": {"type": "boolean"}
+                }
+            },
+            "ResponseOverride": {
+                "type": "object",
+                "required": ["server", "endpoint"],
+                "properties": {
+                    "server": {"type": "string"},
+                    "endpoint": {"type": "string", "description": "A url or a regex of the endpoint like in the config"},
+                    "method": {"type": "string", "description": "Responses to all methods are overridden, when it's empty"},
+                    "status": {"type": "integer", "minimum": 100, "maximum": 599, "default": 200},
+                    "headers": {"type": "object", "additionalProperties": {"type": "string"}},
+                    "body": {"type": "string"},
+                    "session": {"type": "string", "description": "Responses to requests of all sessions are overridden, when it's empty"}
+                }
+            },
+            "LogLevel": {
+                "type": "object",
+                "required": ["level"],
+                "properties": {"level": {"type": "string", "enum": ["debug", "info", "warn", "error"]}}
+            },
+            "ReceivedRequest": {
+                "type": "object",
+                "properties": {
+                    "server": {"type": "string"},
+                    "endpoint": {"type": "string"},
+                    "url": {"type": "string"},
+                    "method": {"type": "string"},
+                    "status": {"type": "integer"},
+                    "validation_failed": {"type": "boolean"},
+                    "operation": {"type": "string"},
+                    "unmatched": {"type": "boolean"},
+                    "session": {"type": "string", "description": "Empty for requests without the session header"}
+                }
+            },
+            "StatisticsEntry": {
+                "type": "object",
+                "properties": {
+                    "request": {"$ref": "#/components/schemas/ReceivedRequest"},
+                    "count": {"type": "integer", "minimum": 1}
+                }
+            },
+            "State": {
+                "type": "object",
+                "required": ["version"],
+                "properties": {
+                    "version": {"type": "integer", "enum": [1]},
+                    "statistics": {
+                        "type": "array",
+                        "nullable": true,
+                        "description": "Null, when statistics are not collected",
+                        "items": {"$ref": "#/components/schemas/StatisticsEntry"}
+                    },
+                    "overrides": {"type": "array", "items": {"$ref": "#/components/schemas/ResponseOverride"}},
+                    "log_level": {"type": "string", "enum": ["debug", "info", "warn", "error"], "description": "The level isn't changed, when it's empty"}
+                }
+            }
+        }
+    }
+}
+`
@@ -0,0 +1,238 @@
+package management
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pokidovea/mimicro/mockServer"
+)
+
+// ResponseOverride replaces responses of the endpoint until it's deleted
+type ResponseOverride struct {
+	ServerName string `json:"server"`
+	Endpoint   string `json:"endpoint"`
+	// Method is empty to override responses to all methods
+	Method     string            `json:"method,omitempty"`
+	StatusCode int               `json:"status"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       string            `json:"body"`
+	// Session is empty to override responses to requests of all sessions
+	Session string `json:"session,omitempty"`
+}
+
+type overrideKey struct {
+	serverName string
+	endpoint   string
+	method     string
+	session    string
+}
+
+func (override ResponseOverride) key() overrideKey {
+	return overrideKey{
+		serverName: override.ServerName, endpoint: override.Endpoint, method: override.Method, session: override.Session,
+	}
+}
+
+func (override ResponseOverride) response() *mockServer.Override {
+	headers := http.Header{}
+	for header, value := range override.Headers {
+		headers.Set(header, value)
+	}
+	return &mockServer.Override{StatusCode: override.StatusCode, Headers: headers, Body: []byte(override.Body)}
+}
+
+// overrides keep responses, which replace configured ones, in memory
+type overrides struct {
+	mutex     sync.RWMutex
+	items     map[overrideKey]ResponseOverride
+	responses map[overrideKey]*mockServer.Override
+}
+
+func newOverrides() *overrides {
+	return &overrides{
+		items:     make(map[overrideKey]ResponseOverride),
+		responses: make(map[overrideKey]*mockServer.Override),
+	}
+}
+
+func (o *overrides) set(override ResponseOverride) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.items[override.key()] = override
+	o.responses[override.key()] = override.response()
+}
+
+// replace drops all overrides and sets the passed ones instead
+func (o *overrides) replace(overrides []ResponseOverride) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.items = make(map[overrideKey]ResponseOverride, len(overrides))
+	o.responses = make(map[overrideKey]*mockServer.Override, len(overrides))
+	for _, override := range overrides {
+		o.items[override.key()] = override
+		o.responses[override.key()] = override.response()
+	}
+}
+
+// find prefers the override of the session to the one of all sessions
+// and the override of the method to the one of all methods
+func (o *overrides) find(session string, serverName string, endpoint string, method string) *mockServer.Override {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	if len(o.responses) == 0 {
+		return nil
+	}
+
+	sessions := []string{""}
+	if session != "" {
+		sessions = []string{session, ""}
+	}
+	for _, session := range sessions {
+		for _, method := range []string{method, ""} {
+			key := overrideKey{serverName: serverName, endpoint: endpoint, method: method, session: session}
+			if response, ok := o.responses[key]; ok {
+				return response
+			}
+		}
+	}
+	return nil
+}
+
+// list returns overrides sorted by server, endpoint, method and session
+func (o *overrides) list() []ResponseOverride {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	result := make([]ResponseOverride, 0, len(o.items))
+	for _, override := range o.items {
+		result = append(result, override)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		switch {
+		case result[i].ServerName != result[j].ServerName:
+			return result[i].ServerName < result[j].ServerName
+		case result[i].Endpoint != result[j].Endpoint:
+			return result[i].Endpoint < result[j].Endpoint
+		case result[i].Method != result[j].Method:
+			return result[i].Method < result[j].Method
+		default:
+			return result[i].Session < result[j].Session
+		}
+	})
+	return result
+}
+
+// countByServer returns numbers of overrides of every server
+func (o *overrides) countByServer() map[string]int {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	counts := make(map[string]int)
+	for key := range o.items {
+		counts[key.serverName]++
+	}
+	return counts
+}
+
+// del deletes overrides, which match the pattern. Only server, endpoint, method and session of the pattern are used
+func (o *overrides) del(pattern requestPattern) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	for key := range o.items {
+		if (pattern.ServerName == "*" || pattern.ServerName == key.serverName) &&
+			(pattern.Endpoint == "*" || pattern.Endpoint == key.endpoint) &&
+			(pattern.Method == "*" || pattern.Method == key.method) &&
+			(pattern.Session == "*" || pattern.Session == key.session) {
+			delete(o.items, key)
+			delete(o.responses, key)
+		}
+	}
+}
+
+// validateOverride checks the override and normalizes its method and status
+func validateOverride(override *ResponseOverride, servers []mockServer.MockServer) error {
+	if override.ServerName == "" || override.Endpoint == "" {
+		return errors.New("server and endpoint are required")
+	}
+	override.Method = strings.ToUpper(override.Method)
+	if override.StatusCode == 0 {
+		override.StatusCode = http.StatusOK
+	}
+	if override.StatusCode < 100 || override.StatusCode > 599 {
+		return fmt.Errorf("status %d is not valid", override.StatusCode)
+	}
+
+	// servers are unknown, when the management server runs without mock servers
+	if len(servers) == 0 {
+		return nil
+	}
+	for _, server := range servers {
+		if server.Name != override.ServerName {
+			continue
+		}
+		for _, endpoint := range server.Endpoints {
+			if endpoint.Pattern() == override.Endpoint {
+				return nil
+			}
+		}
+		return fmt.Errorf("endpoint %s is not found in server %s", override.Endpoint, override.ServerName)
+	}
+	return fmt.Errorf("server %s is not found", override.ServerName)
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, value interface{}) {
+	payload, err := json.Marshal(value)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("Internal server error"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(payload)
+}
+
+// GetOverridesHandler returns all overrides
+func (server *Server) GetOverridesHandler(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, http.StatusOK, server.overrides.list())
+}
+
+// SetOverrideHandler adds the override passed in the body or replaces the one of the same endpoint and method
+func (server *Server) SetOverrideHandler(w http.ResponseWriter, req *http.Request) {
+	var override ResponseOverride
+	if err := json.NewDecoder(req.Body).Decode(&override); err != nil {
+		writeError(w, req, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validateOverride(&override, server.mockServers); err != nil {
+		writeError(w, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	server.overrides.set(override)
+	writeJSON(w, http.StatusOK, override)
+}
+
+// FindOverride returns the override of the endpoint for the request or nil. It's passed to mock servers
+func (server *Server) FindOverride(req *http.Request, serverName string, endpoint string) *mockServer.Override {
+	session := ""
+	if server.sessionHeader != "" {
+		session = req.Header.Get(server.sessionHeader)
+	}
+	return server.overrides.find(session, serverName, endpoint, req.Method)
+}
+
+// DeleteOverridesHandler deletes overrides, which match the server, the endpoint, the method and the session from the query
+func (server *Server) DeleteOverridesHandler(w http.ResponseWriter, req *http.Request) {
+	server.overrides.del(createRequestPatternFromQuery(req.URL))
+	w.WriteHeader(http.StatusNoContent)
+}
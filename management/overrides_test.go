@@ -0,0 +1,152 @@
+package management
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/pokidovea/mimicro/mockServer"
+	"github.com/stretchr/testify/assert"
+)
+
+func newServerWithMockServers(t *testing.T) *Server {
+	config := `
+name: server_1
+port: 4573
+endpoints:
+  - url: /users/{id}
+    GET:
+      template: "ok"
+    DELETE:
+      template: "deleted"
+`
+	var server1 mockServer.MockServer
+	assert.Nil(t, yaml.Unmarshal([]byte(config), &server1))
+
+	server := NewServer(4534, false)
+	server.SetMockServers([]mockServer.MockServer{server1})
+	return server
+}
+
+func serve(router http.Handler, method string, url string, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, url, strings.NewReader(body)))
+	return w
+}
+
+func TestServersHandler(t *testing.T) {
+	server := newServerWithMockServers(t)
+	server.SetReady([]ServerAddress{{Name: "server_1", Address: "[::]:4573"}})
+
+	w := serve(server.createRouter(), "GET", "/api/v1/servers", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(
+		t,
+		`[{"name":"server_1","address":"[::]:4573","endpoints":[{"endpoint":"/users/{id}","methods":["GET","DELETE"]}]}]`,
+		w.Body.String(),
+	)
+}
+
+func TestOverridesHandlers(t *testing.T) {
+	server := newServerWithMockServers(t)
+	router := server.createRouter()
+
+	w := serve(router, "PUT", "/api/v1/overrides", `{"server": "server_1", "endpoint": "/users/{id}", "status": 503, "body": "down"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"server":"server_1","endpoint":"/users/{id}","status":503,"body":"down"}`, w.Body.String())
+
+	w = serve(router, "PUT", "/api/v1/overrides", `{"server": "server_1", "endpoint": "/users/{id}", "method": "get", "body": "{}",`+
+		`"headers": {"content-type": "application/json"}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	getUser := httptest.NewRequest("GET", "/users/1", nil)
+	override := server.FindOverride(getUser, "server_1", "/users/{id}")
+	assert.Equal(t, &mockServer.Override{
+		StatusCode: http.StatusOK,
+		Headers:    http.Header{"Content-Type": {"application/json"}},
+		Body:       []byte("{}"),
+	}, override)
+
+	deleteUser := httptest.NewRequest("DELETE", "/users/1", nil)
+	assert.Equal(t, http.StatusServiceUnavailable, server.FindOverride(deleteUser, "server_1", "/users/{id}").StatusCode)
+	assert.Nil(t, server.FindOverride(getUser, "server_2", "/users/{id}"))
+
+	w = serve(router, "GET", "/api/v1/overrides", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(
+		t,
+		`[{"server":"server_1","endpoint":"/users/{id}","status":503,"body":"down"},`+
+			`{"server":"server_1","endpoint":"/users/{id}","method":"GET","status":200,"headers":{"content-type":"application/json"},"body":"{}"}]`,
+		w.Body.String(),
+	)
+
+	w = serve(router, "DELETE", "/api/v1/overrides?server=server_1&method=", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Nil(t, server.FindOverride(deleteUser, "server_1", "/users/{id}"))
+	assert.NotNil(t, server.FindOverride(getUser, "server_1", "/users/{id}"))
+
+	serve(router, "DELETE", "/api/v1/overrides", "")
+	assert.Equal(t, []ResponseOverride{}, server.overrides.list())
+}
+
+func TestInvalidOverrides(t *testing.T) {
+	router := newServerWithMockServers(t).createRouter()
+
+	for body, message := range map[string]string{
+		`{"server": "server_1"}`: "server and endpoint are required",
+		`{"server": "server_1", "endpoint": "/users/{id}", "status": 1000}`: "status 1000 is not valid",
+		`{"server": "server_2", "endpoint": "/users/{id}"}`:                 "server server_2 is not found",
+		`{"server": "server_1", "endpoint": "/users"}`:                      "endpoint /users is not found in server server_1",
+	} {
+		w := serve(router, "PUT", "/api/v1/overrides", body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		assert.Equal(t, `{"error":"`+message+`"}`, w.Body.String(), body)
+	}
+
+	w := serve(router, "PUT", "/api/v1/overrides", `{"server": "server_1", "endpoint": "/users/{id}", "status": "error"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "cannot unmarshal string")
+}
+
+func TestDashboardHandler(t *testing.T) {
+	w := serve(NewServer(4534, false).createRouter(), "GET", "/dashboard", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `new EventSource("api/v1/requests/tail" + params)`)
+	// statistics are polled after errors, which are shown on the page
+	assert.Contains(t, w.Body.String(), "setTimeout(loadStatistics, 2000);\n    if (status !== 200) {")
+}
+
+func TestSessionOverrides(t *testing.T) {
+	server := newServerWithMockServers(t)
+	router := server.createRouter()
+
+	serve(router, "PUT", "/api/v1/overrides", `{"server": "server_1", "endpoint": "/users/{id}", "status": 503}`)
+	serve(router, "PUT", "/api/v1/overrides", `{"server": "server_1", "endpoint": "/users/{id}", "status": 404, "session": "test-1"}`)
+
+	find := func(session string) int {
+		req := httptest.NewRequest("GET", "/users/1", nil)
+		if session != "" {
+			req.Header.Set("X-Mimicro-Session", session)
+		}
+		return server.FindOverride(req, "server_1", "/users/{id}").StatusCode
+	}
+	assert.Equal(t, 404, find("test-1"))
+	assert.Equal(t, 503, find("test-2"))
+	assert.Equal(t, 503, find(""))
+
+	w := serve(router, "GET", "/api/v1/overrides", "")
+	assert.Equal(
+		t,
+		`[{"server":"server_1","endpoint":"/users/{id}","status":503,"body":""},`+
+			`{"server":"server_1","endpoint":"/users/{id}","status":404,"body":"","session":"test-1"}]`,
+		w.Body.String(),
+	)
+
+	serve(router, "DELETE", "/api/v1/overrides?session=test-1", "")
+	assert.Equal(t, 503, find("test-1"))
+	assert.Len(t, server.overrides.list(), 1)
+}
@@ -0,0 +1,266 @@
+package management
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// StatisticsPersistence saves statistics of requests, so they are kept after restarts
+type StatisticsPersistence interface {
+	// Load returns numbers of requests, which were saved before
+	Load() (map[ReceivedRequest]int, error)
+	// Add saves the received request
+	Add(request ReceivedRequest) error
+	// Delete removes saved requests
+	Delete(requests []ReceivedRequest) error
+	// Compact rewrites saved data, so it contains only the passed numbers of requests
+	Compact(requests map[ReceivedRequest]int) error
+	Close() error
+}
+
+// rewriteFile replaces the file with the written content atomically
+func rewriteFile(path string, write func(writer *bufio.Writer) error) error {
+	tmpPath := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(tmp)
+	err = write(writer)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+	}
+
+	return err
+}
+
+// jsonLinesRecord is a line of the statistics file
+type jsonLinesRecord struct {
+	Request ReceivedRequest `json:"request"`
+	Count   int             `json:"count,omitempty"`
+	Deleted bool            `json:"deleted,omitempty"`
+}
+
+// jsonLinesPersistence appends every change of statistics to a file as a JSON line
+type jsonLinesPersistence struct {
+	path string
+	file *os.File
+}
+
+// NewJSONLinesPersistence saves statistics into an append-only file of JSON lines
+func NewJSONLinesPersistence(path string) (StatisticsPersistence, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	// cut off a line, which was written partially, so new lines aren't appended to it
+	data, err := ioutil.ReadAll(file)
+	if err == nil && len(data) > 0 && data[len(data)-1] != '\n' {
+		err = file.Truncate(int64(bytes.LastIndexByte(data, '\n') + 1))
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &jsonLinesPersistence{path: path, file: file}, nil
+}
+
+func (persistence *jsonLinesPersistence) Load() (map[ReceivedRequest]int, error) {
+	data, err := ioutil.ReadFile(persistence.path)
+	if err != nil {
+		return nil, err
+	}
+
+	requests := make(map[ReceivedRequest]int)
+	lines := bytes.Split(data, []byte("\n"))
+
+	for i, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var record jsonLinesRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, fmt.Errorf("statistics file %s is corrupted at line %d: %s", persistence.path, i+1, err)
+		}
+
+		if record.Deleted {
+			delete(requests, record.Request)
+		} else {
+			requests[record.Request] += record.Count
+		}
+	}
+
+	return requests, nil
+}
+
+func (persistence *jsonLinesPersistence) write(record jsonLinesRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = persistence.file.Write(append(line, '\n'))
+	return err
+}
+
+func (persistence *jsonLinesPersistence) Add(request ReceivedRequest) error {
+	return persistence.write(jsonLinesRecord{Request: request, Count: 1})
+}
+
+func (persistence *jsonLinesPersistence) Delete(requests []ReceivedRequest) error {
+	for _, request := range requests {
+		if err := persistence.write(jsonLinesRecord{Request: request, Deleted: true}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (persistence *jsonLinesPersistence) Compact(requests map[ReceivedRequest]int) error {
+	err := rewriteFile(persistence.path, func(writer *bufio.Writer) error {
+		encoder := json.NewEncoder(writer)
+		for request, count := range requests {
+			if err := encoder.Encode(jsonLinesRecord{Request: request, Count: count}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the old file is replaced, so the new one should be opened
+	persistence.file.Close()
+	persistence.file, err = os.OpenFile(persistence.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	return err
+}
+
+func (persistence *jsonLinesPersistence) Close() error {
+	return persistence.file.Close()
+}
+
+// kvPersistence keeps numbers of requests in the embedded key-value store
+type kvPersistence struct {
+	store *kvStore
+}
+
+// NewKeyValuePersistence saves statistics into the embedded key-value store
+func NewKeyValuePersistence(path string) (StatisticsPersistence, error) {
+	store, err := openKVStore(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kvPersistence{store: store}, nil
+}
+
+func requestKey(request ReceivedRequest) (string, error) {
+	key, err := json.Marshal(request)
+	return string(key), err
+}
+
+func (persistence *kvPersistence) Load() (map[ReceivedRequest]int, error) {
+	requests := make(map[ReceivedRequest]int)
+
+	for key, value := range persistence.store.values {
+		var request ReceivedRequest
+		if err := json.Unmarshal([]byte(key), &request); err != nil {
+			return nil, err
+		}
+		count, err := strconv.Atoi(string(value))
+		if err != nil {
+			return nil, err
+		}
+		requests[request] = count
+	}
+
+	return requests, nil
+}
+
+func (persistence *kvPersistence) Add(request ReceivedRequest) error {
+	key, err := requestKey(request)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	if value, ok := persistence.store.get(key); ok {
+		count, _ = strconv.Atoi(string(value))
+	}
+
+	return persistence.store.put(key, []byte(strconv.Itoa(count+1)))
+}
+
+func (persistence *kvPersistence) Delete(requests []ReceivedRequest) error {
+	for _, request := range requests {
+		key, err := requestKey(request)
+		if err != nil {
+			return err
+		}
+		if err := persistence.store.delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Compact drops outdated records. Usually the store contains the passed numbers already,
+// so only numbers, which differ from them, are rewritten
+func (persistence *kvPersistence) Compact(requests map[ReceivedRequest]int) error {
+	keys := make(map[string]bool, len(requests))
+	for request, count := range requests {
+		key, err := requestKey(request)
+		if err != nil {
+			return err
+		}
+		keys[key] = true
+
+		value := []byte(strconv.Itoa(count))
+		if saved, ok := persistence.store.get(key); !ok || !bytes.Equal(saved, value) {
+			if err := persistence.store.put(key, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	var outdated []string
+	for key := range persistence.store.values {
+		if !keys[key] {
+			outdated = append(outdated, key)
+		}
+	}
+	for _, key := range outdated {
+		if err := persistence.store.delete(key); err != nil {
+			return err
+		}
+	}
+
+	return persistence.store.compact()
+}
+
+func (persistence *kvPersistence) Close() error {
+	return persistence.store.close()
+}
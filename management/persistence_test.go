@@ -0,0 +1,175 @@
+package management
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var persistences = map[string]func(path string) (StatisticsPersistence, error){
+	"jsonl": NewJSONLinesPersistence,
+	"kv":    NewKeyValuePersistence,
+}
+
+func tempStatisticsPath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "mimicro-statistics")
+	assert.Nil(t, err)
+
+	return filepath.Join(dir, "statistics"), func() { os.RemoveAll(dir) }
+}
+
+func TestPersistenceReplay(t *testing.T) {
+	request1 := ReceivedRequest{ServerName: "server_1", Endpoint: "/users/{id}", URL: "/users/1", Method: "GET", StatusCode: 200}
+	request2 := ReceivedRequest{ServerName: "server_1", URL: `/"quoted"`, Method: "GET", StatusCode: 404, Unmatched: true}
+
+	for name, open := range persistences {
+		path, cleanup := tempStatisticsPath(t)
+		defer cleanup()
+
+		persistence, err := open(path)
+		assert.Nil(t, err, name)
+		assert.Nil(t, persistence.Add(request1), name)
+		assert.Nil(t, persistence.Add(request1), name)
+		assert.Nil(t, persistence.Add(request2), name)
+		assert.Nil(t, persistence.Delete([]ReceivedRequest{request2}), name)
+		assert.Nil(t, persistence.Add(request2), name)
+		assert.Nil(t, persistence.Close(), name)
+
+		persistence, err = open(path)
+		assert.Nil(t, err, name)
+		requests, err := persistence.Load()
+		assert.Nil(t, err, name)
+		assert.Equal(t, map[ReceivedRequest]int{request1: 2, request2: 1}, requests, name)
+		assert.Nil(t, persistence.Close(), name)
+	}
+}
+
+func TestPersistenceCompaction(t *testing.T) {
+	request := ReceivedRequest{ServerName: "server_1", URL: "/some_url", Method: "GET", StatusCode: 200}
+
+	for name, open := range persistences {
+		path, cleanup := tempStatisticsPath(t)
+		defer cleanup()
+
+		persistence, err := open(path)
+		assert.Nil(t, err, name)
+		for i := 0; i < 100; i++ {
+			assert.Nil(t, persistence.Add(request), name)
+		}
+
+		before, _ := os.Stat(path)
+		assert.Nil(t, persistence.Compact(map[ReceivedRequest]int{request: 100}), name)
+		after, _ := os.Stat(path)
+		assert.True(t, after.Size() < before.Size(), name)
+
+		// the persistence keeps working after the file is replaced
+		assert.Nil(t, persistence.Add(request), name)
+		requests, err := persistence.Load()
+		assert.Nil(t, err, name)
+		assert.Equal(t, map[ReceivedRequest]int{request: 101}, requests, name)
+		assert.Nil(t, persistence.Close(), name)
+	}
+}
+
+func TestPersistenceIgnoresPartialRecord(t *testing.T) {
+	request := ReceivedRequest{ServerName: "server_1", URL: "/some_url", Method: "GET", StatusCode: 200}
+
+	for name, open := range persistences {
+		path, cleanup := tempStatisticsPath(t)
+		defer cleanup()
+
+		persistence, err := open(path)
+		assert.Nil(t, err, name)
+		assert.Nil(t, persistence.Add(request), name)
+		assert.Nil(t, persistence.Add(request), name)
+		assert.Nil(t, persistence.Close(), name)
+
+		// cut the last record like after a crash
+		info, _ := os.Stat(path)
+		assert.Nil(t, os.Truncate(path, info.Size()-3), name)
+
+		persistence, err = open(path)
+		assert.Nil(t, err, name)
+		requests, err := persistence.Load()
+		assert.Nil(t, err, name)
+		assert.Equal(t, map[ReceivedRequest]int{request: 1}, requests, name)
+
+		assert.Nil(t, persistence.Add(request), name)
+		assert.Nil(t, persistence.Close(), name)
+
+		persistence, _ = open(path)
+		requests, _ = persistence.Load()
+		assert.Equal(t, map[ReceivedRequest]int{request: 2}, requests, name)
+		persistence.Close()
+	}
+}
+
+func TestKeyValuePersistenceIgnoresRecordLongerThanFile(t *testing.T) {
+	path, cleanup := tempStatisticsPath(t)
+	defer cleanup()
+
+	request := ReceivedRequest{ServerName: "server_1", URL: "/some_url", Method: "GET", StatusCode: 200}
+	persistence, err := NewKeyValuePersistence(path)
+	assert.Nil(t, err)
+	assert.Nil(t, persistence.Add(request))
+	assert.Nil(t, persistence.Close())
+
+	info, _ := os.Stat(path)
+	// a record with huge lengths of the key and the value
+	garbage := []byte{0, 0, 0, 0, 0xff, 0xff, 0xff, 0xfe, 0xff, 0xff, 0xff, 0xfe, 1, 2, 3}
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	file.Write(garbage)
+	file.Close()
+
+	persistence, err = NewKeyValuePersistence(path)
+	assert.Nil(t, err)
+	requests, err := persistence.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, map[ReceivedRequest]int{request: 1}, requests)
+	assert.Nil(t, persistence.Close())
+
+	truncated, _ := os.Stat(path)
+	assert.Equal(t, info.Size(), truncated.Size())
+}
+
+func TestPersistenceWithCorruptedFile(t *testing.T) {
+	path, cleanup := tempStatisticsPath(t)
+	defer cleanup()
+
+	ioutil.WriteFile(path, []byte("not json\n{}\n"), 0644)
+
+	persistence, err := NewJSONLinesPersistence(path)
+	assert.Nil(t, err)
+	_, err = persistence.Load()
+	assert.Contains(t, err.Error(), "statistics file "+path+" is corrupted at line 1")
+	persistence.Close()
+}
+
+func TestStorageRestoresStatistics(t *testing.T) {
+	path, cleanup := tempStatisticsPath(t)
+	defer cleanup()
+
+	request1 := ReceivedRequest{ServerName: "server_1", URL: "/some_url", Method: "GET", StatusCode: 200}
+	request2 := ReceivedRequest{ServerName: "server_2", URL: "/some_url", Method: "GET", StatusCode: 200}
+
+	storage := newStatisticsStorage()
+	storage.persistence, _ = NewJSONLinesPersistence(path)
+	assert.Nil(t, storage.Start())
+	storage.add(request1)
+	storage.add(request2)
+	storage.del(requestPattern{ServerName: "server_2", Endpoint: "*", URL: "*", Method: "*"})
+	storage.Stop()
+
+	storage = newStatisticsStorage()
+	storage.persistence, _ = NewJSONLinesPersistence(path)
+	assert.Nil(t, storage.Start())
+	assert.Equal(t, requestsCounter{request1: 1}, storage.counts())
+	storage.Stop()
+
+	// the file is compacted on stop
+	data, _ := ioutil.ReadFile(path)
+	assert.Equal(t, `{"request":{"server":"server_1","url":"/some_url","method":"GET","status":200},"count":1}`+"\n", string(data))
+}
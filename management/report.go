@@ -0,0 +1,292 @@
+package management
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// statistics can be grouped by the url or the endpoint
+const (
+	groupByURL      = "url"
+	groupByEndpoint = "endpoint"
+)
+
+// StatisticsRecord contains numbers of similar requests with different response statuses
+type StatisticsRecord struct {
+	ServerName string `json:"server"`
+	Endpoint   string `json:"endpoint,omitempty"`
+	// URL is empty when records are grouped by the endpoint
+	URL              string      `json:"url,omitempty"`
+	Method           string      `json:"method"`
+	Count            int         `json:"count"`
+	Statuses         map[int]int `json:"statuses"`
+	ValidationFailed bool        `json:"validation_failed,omitempty"`
+	Operation        string      `json:"operation,omitempty"`
+	Unmatched        bool        `json:"unmatched,omitempty"`
+	Session          string      `json:"session,omitempty"`
+}
+
+// StatisticsTotal is a number of requests received by the server with the method
+type StatisticsTotal struct {
+	ServerName string `json:"server"`
+	Method     string `json:"method"`
+	Count      int    `json:"count"`
+}
+
+// StatisticsReport is a page of statistics records with totals of all records, which match the filter
+type StatisticsReport struct {
+	Records []StatisticsRecord `json:"records"`
+	// Total is a number of records on all pages
+	Total  int               `json:"total"`
+	Offset int               `json:"offset"`
+	Limit  int               `json:"limit,omitempty"`
+	Totals []StatisticsTotal `json:"totals"`
+	// Dropped is a number of requests, which were not saved, because the persistence was busy or stopped
+	Dropped uint64 `json:"dropped"`
+}
+
+// Requests splits records grouped by the url into requests with their response statuses
+func (report StatisticsReport) Requests() map[ReceivedRequest]int {
+	requests := make(map[ReceivedRequest]int)
+	for _, record := range report.Records {
+		for status, count := range record.Statuses {
+			requests[ReceivedRequest{
+				ServerName:       record.ServerName,
+				Endpoint:         record.Endpoint,
+				URL:              record.URL,
+				Method:           record.Method,
+				StatusCode:       status,
+				ValidationFailed: record.ValidationFailed,
+				Operation:        record.Operation,
+				Unmatched:        record.Unmatched,
+				Session:          record.Session,
+			}] += count
+		}
+	}
+	return requests
+}
+
+func (record StatisticsRecord) less(other StatisticsRecord) bool {
+	switch {
+	case record.ServerName != other.ServerName:
+		return record.ServerName < other.ServerName
+	case record.Endpoint != other.Endpoint:
+		return record.Endpoint < other.Endpoint
+	case record.URL != other.URL:
+		return record.URL < other.URL
+	case record.Method != other.Method:
+		return record.Method < other.Method
+	case record.Operation != other.Operation:
+		return record.Operation < other.Operation
+	case record.ValidationFailed != other.ValidationFailed:
+		return !record.ValidationFailed
+	case record.Unmatched != other.Unmatched:
+		return !record.Unmatched
+	default:
+		return record.Session < other.Session
+	}
+}
+
+// records merges requests, which differ only in response statuses, and sorts them.
+// Grouping by the endpoint merges requests of different urls as well
+func (counter requestsCounter) records(groupBy string) []StatisticsRecord {
+	indexes := make(map[ReceivedRequest]int)
+	records := []StatisticsRecord{}
+
+	for request, count := range counter {
+		key := request
+		key.StatusCode = 0
+		if groupBy == groupByEndpoint {
+			key.URL = ""
+		}
+
+		index, ok := indexes[key]
+		if !ok {
+			index = len(records)
+			indexes[key] = index
+			records = append(records, StatisticsRecord{
+				ServerName:       key.ServerName,
+				Endpoint:         key.Endpoint,
+				URL:              key.URL,
+				Method:           key.Method,
+				Statuses:         make(map[int]int),
+				ValidationFailed: key.ValidationFailed,
+				Operation:        key.Operation,
+				Unmatched:        key.Unmatched,
+				Session:          key.Session,
+			})
+		}
+		records[index].Count += count
+		records[index].Statuses[request.StatusCode] += count
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].less(records[j])
+	})
+
+	return records
+}
+
+func totals(records []StatisticsRecord) []StatisticsTotal {
+	indexes := make(map[StatisticsTotal]int)
+	result := []StatisticsTotal{}
+
+	for _, record := range records {
+		key := StatisticsTotal{ServerName: record.ServerName, Method: record.Method}
+		index, ok := indexes[key]
+		if !ok {
+			index = len(result)
+			indexes[key] = index
+			result = append(result, key)
+		}
+		result[index].Count += record.Count
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].ServerName != result[j].ServerName {
+			return result[i].ServerName < result[j].ServerName
+		}
+		return result[i].Method < result[j].Method
+	})
+
+	return result
+}
+
+// newStatisticsReport returns the page of records starting from the offset. The limit 0 means all records
+func newStatisticsReport(records []StatisticsRecord, offset int, limit int) StatisticsReport {
+	report := StatisticsReport{Total: len(records), Offset: offset, Limit: limit, Totals: totals(records)}
+
+	if offset > len(records) {
+		offset = len(records)
+	}
+	end := len(records)
+	if limit > 0 && limit < end-offset {
+		end = offset + limit
+	}
+	report.Records = records[offset:end]
+
+	return report
+}
+
+// reportQuery contains parameters of the statistics report passed in the query
+type reportQuery struct {
+	groupBy string
+	offset  int
+	limit   int
+	format  string
+}
+
+func parseReportQuery(URL *url.URL) (reportQuery, error) {
+	query := reportQuery{groupBy: groupByURL, format: "json"}
+	values := URL.Query()
+
+	if groupBy := values.Get("group_by"); groupBy != "" {
+		if groupBy != groupByURL && groupBy != groupByEndpoint {
+			return query, errors.New("group_by must be url or endpoint")
+		}
+		query.groupBy = groupBy
+	}
+
+	if format := values.Get("format"); format != "" {
+		if format != "json" && format != "csv" {
+			return query, errors.New("format must be json or csv")
+		}
+		query.format = format
+	}
+
+	var err error
+	if offset := values.Get("offset"); offset != "" {
+		if query.offset, err = strconv.Atoi(offset); err != nil || query.offset < 0 {
+			return query, errors.New("offset must be a non-negative integer")
+		}
+	}
+	if limit := values.Get("limit"); limit != "" {
+		if query.limit, err = strconv.Atoi(limit); err != nil || query.limit < 0 {
+			return query, errors.New("limit must be a non-negative integer")
+		}
+	}
+
+	return query, nil
+}
+
+// writeCSV writes a row for every response status of records
+func writeCSV(w http.ResponseWriter, records []StatisticsRecord) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{
+		"server", "endpoint", "url", "method", "status", "count", "validation_failed", "operation", "unmatched", "session",
+	})
+
+	for _, record := range records {
+		statuses := make([]int, 0, len(record.Statuses))
+		for status := range record.Statuses {
+			statuses = append(statuses, status)
+		}
+		sort.Ints(statuses)
+
+		for _, status := range statuses {
+			writer.Write([]string{
+				record.ServerName,
+				record.Endpoint,
+				record.URL,
+				record.Method,
+				strconv.Itoa(status),
+				strconv.Itoa(record.Statuses[status]),
+				strconv.FormatBool(record.ValidationFailed),
+				record.Operation,
+				strconv.FormatBool(record.Unmatched),
+				record.Session,
+			})
+		}
+	}
+
+	writer.Flush()
+}
+
+func (storage *statisticsStorage) GetStatisticsHandler(w http.ResponseWriter, req *http.Request) {
+	storage.writeStatistics(w, req, false)
+}
+
+// GetRecordsHandler serves the deprecated route /statistics/get, which answers only the array of records
+func (storage *statisticsStorage) GetRecordsHandler(w http.ResponseWriter, req *http.Request) {
+	storage.writeStatistics(w, req, true)
+}
+
+func (storage *statisticsStorage) writeStatistics(w http.ResponseWriter, req *http.Request, onlyRecords bool) {
+	query, err := parseReportQuery(req.URL)
+	if err != nil {
+		writeError(w, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	pattern := createRequestPatternFromQuery(req.URL)
+	report := newStatisticsReport(storage.filter(pattern).records(query.groupBy), query.offset, query.limit)
+	report.Dropped = storage.droppedCount()
+
+	if query.format == "csv" {
+		writeCSV(w, report.Records)
+		return
+	}
+
+	var payload []byte
+	if onlyRecords {
+		payload, err = json.Marshal(report.Records)
+	} else {
+		payload, err = json.Marshal(report)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("Internal server error"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(payload)
+}
@@ -1,27 +1,73 @@
 package management
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
-	"os"
-	"os/signal"
+	"net/url"
 	"strconv"
 	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/pokidovea/mimicro/mockServer"
 )
 
 // Server represents a server, responsible for statistics and administration
 type Server struct {
-	Port              int
+	Port int
+	// Bind is an address of the interface to listen on. The server listens on all interfaces, when it's empty
+	Bind string
+	// Addr is an address the server is bound to after the start
+	Addr              net.Addr
+	srv               *http.Server
 	statisticsStorage *statisticsStorage
+	metrics           *metrics
+	accessLogger      *AccessLogger
+	tail              *tail
+	overrides         *overrides
+	mockServers       []mockServer.MockServer
+	auth              Auth
+	sessionHeader     string
+
+	statusMutex sync.RWMutex
+	status      string
+	servers     []ServerAddress
+}
+
+// statuses of the server reported by health checks
+const (
+	statusStarting = "starting"
+	statusReady    = "ready"
+	statusStopping = "stopping"
+)
+
+// ServerAddress is an address, which a mock server is bound to
+type ServerAddress struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+type healthResponse struct {
+	Status  string          `json:"status"`
+	Servers []ServerAddress `json:"servers"`
 }
 
 // NewServer creates a new management server record
 func NewServer(port int, collectStatistics bool) *Server {
-	server := Server{Port: port}
+	server := Server{
+		Port:          port,
+		status:        statusStarting,
+		servers:       []ServerAddress{},
+		accessLogger:  defaultAccessLogger(),
+		tail:          newTail(),
+		overrides:     newOverrides(),
+		sessionHeader: DefaultSessionHeader,
+	}
 
 	if collectStatistics {
 		server.statisticsStorage = newStatisticsStorage()
@@ -30,76 +76,309 @@ func NewServer(port int, collectStatistics bool) *Server {
 	return &server
 }
 
-// WriteRequestLog is called by mock servers to write request into log and statistics into storage
-func (server *Server) WriteRequestLog(serverName, URL, method string, statusCode int) {
+// DefaultSessionHeader is a header, which value partitions statistics and selects overrides of the session
+const DefaultSessionHeader = "X-Mimicro-Session"
+
+// newReceivedRequest takes the session from the header. The session is empty, when the header is empty
+func newReceivedRequest(requestLog mockServer.RequestLog, sessionHeader string) ReceivedRequest {
 	request := ReceivedRequest{
-		ServerName: serverName,
-		URL:        URL,
-		Method:     method,
-		StatusCode: statusCode,
+		ServerName:       requestLog.ServerName,
+		Endpoint:         requestLog.Endpoint,
+		URL:              requestPath(requestLog.URL),
+		Method:           requestLog.Method,
+		StatusCode:       requestLog.StatusCode,
+		ValidationFailed: len(requestLog.ValidationErrors) > 0,
+		Operation:        requestLog.Operation,
+		Unmatched:        requestLog.Unmatched,
+	}
+	if sessionHeader != "" {
+		request.Session = requestLog.Header.Get(sessionHeader)
+	}
+	return request
+}
+
+// WriteRequestLog is called by mock servers to write request into log and statistics into storage
+func (server *Server) WriteRequestLog(requestLog mockServer.RequestLog) {
+	request := newReceivedRequest(requestLog, server.sessionHeader)
+
+	server.accessLogger.Write(requestLog)
+	server.tail.publish(requestLog, request)
+
+	if server.statisticsStorage != nil {
+		server.statisticsStorage.add(request)
+	}
+	if server.metrics != nil {
+		server.metrics.observe(requestLog)
+	}
+}
+
+// SetMockServers passes the config of mock servers, which are listed on /servers and can be overridden.
+// It must be called before Start
+func (server *Server) SetMockServers(servers []mockServer.MockServer) {
+	server.mockServers = servers
+}
+
+// SetSessionHeader changes the header, which partitions statistics and selects overrides of sessions.
+// Sessions are disabled by the empty header. It must be called before Start
+func (server *Server) SetSessionHeader(header string) {
+	server.sessionHeader = header
+}
+
+// SetAuth makes all routes require the token or the basic credentials. It must be called before Start
+func (server *Server) SetAuth(auth Auth) {
+	server.auth = auth
+}
+
+// SetAccessLogger replaces the logger of requests. It must be called before Start
+func (server *Server) SetAccessLogger(logger *AccessLogger) {
+	server.accessLogger = logger
+}
+
+// EnableMetrics makes the server collect metrics of mock servers and serve them on /metrics.
+// It must be called before Start
+func (server *Server) EnableMetrics() {
+	server.metrics = newMetrics()
+}
+
+// SetStatisticsPersistence makes statistics to be saved by the persistence and restored on start.
+// It must be called before Start and does nothing if statistics are not collected
+func (server *Server) SetStatisticsPersistence(persistence StatisticsPersistence) {
+	if server.statisticsStorage != nil {
+		server.statisticsStorage.persistence = persistence
+	}
+}
+
+// InFlightMiddleware returns a middleware, which counts requests being handled by the mock server.
+// It does nothing if metrics are not enabled
+func (server *Server) InFlightMiddleware(serverName string) mockServer.Middleware {
+	if server.metrics == nil {
+		return func(handler http.Handler) http.Handler { return handler }
+	}
+	return server.metrics.trackInFlight(serverName)
+}
+
+// requestPath removes the query from the requested url
+func requestPath(requestURL string) string {
+	parsed, err := url.Parse(requestURL)
+	if err != nil {
+		return requestURL
+	}
+	return parsed.Path
+}
+
+// SetReady is called when all mock servers are bound to their addresses
+func (server *Server) SetReady(servers []ServerAddress) {
+	server.statusMutex.Lock()
+	defer server.statusMutex.Unlock()
+
+	server.status = statusReady
+	server.servers = servers
+}
+
+// SetStopping is called when mock servers begin to stop, so they are not ready anymore
+func (server *Server) SetStopping() {
+	server.statusMutex.Lock()
+	defer server.statusMutex.Unlock()
+
+	server.status = statusStopping
+}
+
+func (server *Server) health() healthResponse {
+	server.statusMutex.RLock()
+	defer server.statusMutex.RUnlock()
+
+	return healthResponse{Status: server.status, Servers: server.servers}
+}
+
+func writeHealth(w http.ResponseWriter, statusCode int, health healthResponse) {
+	payload, err := json.Marshal(health)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("Internal server error"))
+		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(payload)
+}
+
+// HealthHandler reports that the management server is alive
+func (server *Server) HealthHandler(w http.ResponseWriter, req *http.Request) {
+	writeHealth(w, http.StatusOK, server.health())
+}
 
-	log.Printf("Requested %s \n", request)
+// ReadyHandler reports whether all mock servers are bound and serve requests
+func (server *Server) ReadyHandler(w http.ResponseWriter, req *http.Request) {
+	health := server.health()
+
+	statusCode := http.StatusOK
+	if health.Status != statusReady {
+		statusCode = http.StatusServiceUnavailable
+	}
+	writeHealth(w, statusCode, health)
+}
+
+// ServerInfo describes a mock server with its endpoints
+type ServerInfo struct {
+	Name string `json:"name"`
+	// Address is empty until the server is bound
+	Address   string         `json:"address"`
+	Endpoints []EndpointInfo `json:"endpoints"`
+}
+
+// EndpointInfo describes an endpoint of a mock server
+type EndpointInfo struct {
+	Endpoint string   `json:"endpoint"`
+	Methods  []string `json:"methods"`
+}
+
+func (server *Server) serverInfos() []ServerInfo {
+	addresses := make(map[string]string)
+	for _, address := range server.health().Servers {
+		addresses[address.Name] = address.Address
+	}
+
+	infos := make([]ServerInfo, 0, len(server.mockServers))
+	for _, mockServer := range server.mockServers {
+		info := ServerInfo{Name: mockServer.Name, Address: addresses[mockServer.Name], Endpoints: []EndpointInfo{}}
+		for _, endpoint := range mockServer.Endpoints {
+			info.Endpoints = append(info.Endpoints, EndpointInfo{Endpoint: endpoint.Pattern(), Methods: endpoint.Methods()})
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// ServersHandler lists mock servers with their addresses and endpoints
+func (server *Server) ServersHandler(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, http.StatusOK, server.serverInfos())
+}
+
+// MetricsHandler serves metrics in the Prometheus text format
+func (server *Server) MetricsHandler(w http.ResponseWriter, req *http.Request) {
+	buffer := new(bytes.Buffer)
+	server.metrics.write(buffer)
+
+	// servers without overrides are reported with zeros
+	faults := server.overrides.countByServer()
+	for _, mock := range server.mockServers {
+		if _, ok := faults[mock.Name]; !ok {
+			faults[mock.Name] = 0
+		}
+	}
+	writeInjectedFaults(buffer, faults)
 
 	if server.statisticsStorage != nil {
-		server.statisticsStorage.RequestsChannel <- request
+		buffer.WriteString("# HELP mimicro_statistics_dropped_total Number of requests, which were not saved by the statistics storage.\n")
+		buffer.WriteString("# TYPE mimicro_statistics_dropped_total counter\n")
+		fmt.Fprintf(buffer, "mimicro_statistics_dropped_total %d\n", server.statisticsStorage.droppedCount())
 	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buffer.Bytes())
 }
 
-func (server Server) startHTTPServer() *http.Server {
+func (server *Server) createRouter() *mux.Router {
 	router := mux.NewRouter()
+	router.NotFoundHandler = http.HandlerFunc(notFoundHandler)
+
+	router.HandleFunc("/health", server.HealthHandler).Methods("GET")
+	router.HandleFunc("/ready", server.ReadyHandler).Methods("GET")
+	router.HandleFunc("/dashboard", server.DashboardHandler).Methods("GET")
+
+	if server.metrics != nil {
+		router.HandleFunc("/metrics", server.MetricsHandler).Methods("GET")
+	}
+
+	server.registerAPI(router)
+	server.registerDeprecatedRoutes(router)
+
+	return router
+}
+
+// handler returns the router protected by the auth
+func (server *Server) handler() http.Handler {
+	return server.auth.wrap(server.createRouter())
+}
+
+// Start binds the port of the management server and serves requests in background until Shutdown is called
+func (server *Server) Start() error {
+	listener, err := net.Listen("tcp", net.JoinHostPort(server.Bind, strconv.Itoa(server.Port)))
+	if err != nil {
+		return fmt.Errorf("[Management] cannot bind port %d: %s", server.Port, err)
+	}
+	server.Addr = listener.Addr()
 
 	if server.statisticsStorage != nil {
-		router.HandleFunc("/statistics/get", server.statisticsStorage.GetStatisticsHandler).Methods("GET")
-		router.HandleFunc("/statistics/reset", server.statisticsStorage.DeleteStatisticsHandler).Methods("GET")
+		if err := server.statisticsStorage.Start(); err != nil {
+			listener.Close()
+			return err
+		}
 	}
 
-	srv := &http.Server{
-		Addr:           ":" + strconv.Itoa(server.Port),
-		Handler:        router,
+	server.srv = &http.Server{
+		Handler:        server.handler(),
 		ReadTimeout:    10 * time.Second,
 		WriteTimeout:   10 * time.Second,
 		MaxHeaderBytes: 1 << 20,
 	}
 
+	log.Printf("[Management] Listening on %s", server.Addr)
+
 	go func() {
-		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		if err := server.srv.Serve(listener); err != http.ErrServerClosed {
 			// cannot panic, because this probably is an intentional close
-			log.Printf("Httpserver: ListenAndServe() error: %s", err)
+			log.Printf("[Management] Serve error: %s", err)
 		}
 	}()
-	return srv
+
+	return nil
 }
 
-// Serve method starts the server and does some operations after it stops
-func (server Server) Serve(wg *sync.WaitGroup) {
-	log.Printf("[Management] Starting...")
+// Shutdown stops the management server gracefully and stops collecting statistics.
+// Mock servers must be stopped before, because they write logs of requests into the statistics
+func (server *Server) Shutdown(ctx context.Context) error {
+	log.Printf("[Management] Stopping...")
+
+	server.SetStopping()
+	server.tail.close()
 
 	if server.statisticsStorage != nil {
-		server.statisticsStorage.Start()
+		server.statisticsStorage.Stop()
 	}
 
-	interrupt := make(chan os.Signal, 1)
-	signal.Notify(interrupt, os.Interrupt)
-	defer close(interrupt)
-	defer signal.Stop(interrupt)
+	if err := server.srv.Shutdown(ctx); err != nil {
+		return err
+	}
 
-	srv := server.startHTTPServer()
-	<-interrupt
+	log.Printf("[Management] Stopped")
+	return nil
+}
 
-	if server.statisticsStorage != nil {
-		server.statisticsStorage.Stop()
+// Statistics returns numbers of received requests, which match the filter.
+// Requests, which were logged before the call, are always counted
+func (server *Server) Statistics(filter RequestFilter) map[ReceivedRequest]int {
+	if server.statisticsStorage == nil {
+		return nil
 	}
 
-	log.Printf("[Management] Stopping...")
+	return server.statisticsStorage.filter(filter.pattern())
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Printf("[Management] Shutdown error: %s", err)
+// DroppedStatistics returns a number of requests, which were not saved, because the statistics persistence was busy or stopped
+func (server *Server) DroppedStatistics() uint64 {
+	if server.statisticsStorage == nil {
+		return 0
 	}
+	return server.statisticsStorage.droppedCount()
+}
 
-	log.Printf("[Management] Stopped")
+// ResetStatistics deletes statistics of requests, which match the filter
+func (server *Server) ResetStatistics(filter RequestFilter) {
+	if server.statisticsStorage == nil {
+		return
+	}
 
-	wg.Done()
+	server.statisticsStorage.del(filter.pattern())
 }
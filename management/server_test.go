@@ -2,8 +2,10 @@ package management
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/pokidovea/mimicro/mockServer"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -24,19 +26,23 @@ func TestNewServerWithStatistics(t *testing.T) {
 func TestWriteRequestLogWithoutStatistics(t *testing.T) {
 	server := NewServer(4534, false)
 
-	server.WriteRequestLog("server_1", "/some/url", "GET", http.StatusOK)
+	server.WriteRequestLog(mockServer.RequestLog{
+		ServerName: "server_1",
+		URL:        "/some/url",
+		Method:     "GET",
+		StatusCode: http.StatusOK,
+	})
 }
 
 func TestWriteRequestLogWithStatistics(t *testing.T) {
 	server := NewServer(4534, true)
 
-	// make the channel buffered to test in one thread
-	server.statisticsStorage.RequestsChannel = make(chan ReceivedRequest, 1)
-
-	server.WriteRequestLog("server_1", "/some/url", "GET", http.StatusOK)
-
-	var request ReceivedRequest
-	request = <-server.statisticsStorage.RequestsChannel
+	server.WriteRequestLog(mockServer.RequestLog{
+		ServerName: "server_1",
+		URL:        "/some/url",
+		Method:     "GET",
+		StatusCode: http.StatusOK,
+	})
 
 	expectedRequest := ReceivedRequest{
 		ServerName: "server_1",
@@ -45,5 +51,63 @@ func TestWriteRequestLogWithStatistics(t *testing.T) {
 		StatusCode: http.StatusOK,
 	}
 
-	assert.Equal(t, expectedRequest, request)
+	assert.Equal(t, requestsCounter{expectedRequest: 1}, server.statisticsStorage.counts())
+}
+
+func TestWriteRequestLogWithValidationErrors(t *testing.T) {
+	server := NewServer(4534, true)
+
+	server.WriteRequestLog(mockServer.RequestLog{
+		ServerName:       "server_1",
+		URL:              "/some/url?limit=0",
+		Method:           "GET",
+		StatusCode:       http.StatusBadRequest,
+		Endpoint:         "/some/url",
+		ValidationErrors: []string{"query parameter \"limit\": Must be greater than or equal to 1"},
+	})
+
+	expectedRequest := ReceivedRequest{
+		ServerName:       "server_1",
+		Endpoint:         "/some/url",
+		URL:              "/some/url",
+		Method:           "GET",
+		StatusCode:       http.StatusBadRequest,
+		ValidationFailed: true,
+	}
+
+	assert.Equal(t, requestsCounter{expectedRequest: 1}, server.statisticsStorage.counts())
+}
+
+func TestHealthAndReadiness(t *testing.T) {
+	server := NewServer(4534, false)
+	router := server.createRouter()
+
+	check := func(url string) (int, string) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		return w.Code, w.Body.String()
+	}
+
+	status, body := check("/health")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `{"status":"starting","servers":[]}`, body)
+
+	status, body = check("/ready")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, `{"status":"starting","servers":[]}`, body)
+
+	server.SetReady([]ServerAddress{{Name: "server_1", Address: "[::]:4573"}})
+
+	status, body = check("/ready")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `{"status":"ready","servers":[{"name":"server_1","address":"[::]:4573"}]}`, body)
+
+	server.SetStopping()
+
+	status, body = check("/ready")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, `{"status":"stopping","servers":[{"name":"server_1","address":"[::]:4573"}]}`, body)
+
+	status, _ = check("/health")
+	assert.Equal(t, http.StatusOK, status)
 }
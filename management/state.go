@@ -0,0 +1,128 @@
+package management
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// StateVersion is a version of the state document. Documents of other versions are not restored
+const StateVersion = 1
+
+// State is everything the management server changes at runtime: statistics, overrides and the level of access logs
+type State struct {
+	Version int `json:"version"`
+	// Statistics are nil, when statistics are not collected
+	Statistics []StatisticsEntry  `json:"statistics"`
+	Overrides  []ResponseOverride `json:"overrides"`
+	LogLevel   string             `json:"log_level"`
+}
+
+// StatisticsEntry is a number of the same received requests
+type StatisticsEntry struct {
+	Request ReceivedRequest `json:"request"`
+	Count   int             `json:"count"`
+}
+
+func newStatisticsEntries(requests requestsCounter) []StatisticsEntry {
+	entries := make([]StatisticsEntry, 0, len(requests))
+	for request, count := range requests {
+		entries = append(entries, StatisticsEntry{Request: request, Count: count})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Request.String() < entries[j].Request.String()
+	})
+	return entries
+}
+
+// State returns the current state of the server
+func (server *Server) State() State {
+	state := State{
+		Version:   StateVersion,
+		Overrides: server.overrides.list(),
+		LogLevel:  server.accessLogger.Level().String(),
+	}
+	if server.statisticsStorage != nil {
+		state.Statistics = newStatisticsEntries(server.statisticsStorage.filter(RequestFilter{}.pattern()))
+	}
+	return state
+}
+
+// RestoreState replaces the current state of the server with the passed one.
+// Nothing is changed, if the state is not valid
+func (server *Server) RestoreState(state State) error {
+	if state.Version != StateVersion {
+		return fmt.Errorf("state version %d is not supported, expected %d", state.Version, StateVersion)
+	}
+
+	level := server.accessLogger.Level()
+	if state.LogLevel != "" {
+		var err error
+		if level, err = ParseLogLevel(state.LogLevel); err != nil {
+			return err
+		}
+	}
+
+	overrides := make([]ResponseOverride, len(state.Overrides))
+	for i, override := range state.Overrides {
+		if err := validateOverride(&override, server.mockServers); err != nil {
+			return fmt.Errorf("override %d: %s", i, err)
+		}
+		overrides[i] = override
+	}
+
+	if len(state.Statistics) > 0 && server.statisticsStorage == nil {
+		return errors.New("statistics are not collected")
+	}
+	requests := make(requestsCounter, len(state.Statistics))
+	for i, entry := range state.Statistics {
+		if entry.Count <= 0 {
+			return fmt.Errorf("statistics entry %d: count %d is not positive", i, entry.Count)
+		}
+		requests[entry.Request] += entry.Count
+	}
+
+	if server.statisticsStorage != nil {
+		server.statisticsStorage.replace(requests)
+	}
+	server.overrides.replace(overrides)
+	server.accessLogger.SetLevel(level)
+	return nil
+}
+
+// ResetState returns the server to the state after the start with the config:
+// statistics and overrides are deleted and the level of access logs is restored
+func (server *Server) ResetState() {
+	if server.statisticsStorage != nil {
+		server.statisticsStorage.replace(requestsCounter{})
+	}
+	server.overrides.replace(nil)
+	server.accessLogger.ResetLevel()
+}
+
+// GetStateHandler exports the state of the server as a JSON document
+func (server *Server) GetStateHandler(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, http.StatusOK, server.State())
+}
+
+// SetStateHandler imports the state exported by GetStateHandler
+func (server *Server) SetStateHandler(w http.ResponseWriter, req *http.Request) {
+	var state State
+	if err := json.NewDecoder(req.Body).Decode(&state); err != nil {
+		writeError(w, req, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := server.RestoreState(state); err != nil {
+		writeError(w, req, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, server.State())
+}
+
+// DeleteStateHandler resets the state of the server to the one loaded from the config
+func (server *Server) DeleteStateHandler(w http.ResponseWriter, req *http.Request) {
+	server.ResetState()
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,148 @@
+package management
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateHandlers(t *testing.T) {
+	server := newServerWithMockServers(t)
+	server.statisticsStorage = newStatisticsStorage()
+	router := server.createRouter()
+
+	user1 := ReceivedRequest{ServerName: "server_1", Endpoint: "/users/{id}", URL: "/users/1", Method: "GET", StatusCode: 200}
+	server.statisticsStorage.add(user1)
+	server.statisticsStorage.add(user1)
+	serve(router, "PUT", "/api/v1/overrides", `{"server": "server_1", "endpoint": "/users/{id}", "status": 503, "body": "down"}`)
+
+	w := serve(router, "GET", "/api/v1/state", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	snapshot := w.Body.String()
+	assert.Equal(
+		t,
+		`{"version":1,`+
+			`"statistics":[{"request":{"server":"server_1","endpoint":"/users/{id}","url":"/users/1","method":"GET","status":200},"count":2}],`+
+			`"overrides":[{"server":"server_1","endpoint":"/users/{id}","status":503,"body":"down"}],`+
+			`"log_level":"info"}`,
+		snapshot,
+	)
+
+	server.statisticsStorage.add(user1)
+	serve(router, "DELETE", "/api/v1/overrides", "")
+	serve(router, "PUT", "/api/v1/logging/level", `{"level": "error"}`)
+
+	w = serve(router, "PUT", "/api/v1/state", snapshot)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, snapshot, w.Body.String())
+	assert.Equal(t, 2, server.statisticsStorage.get(user1))
+	assert.NotNil(t, server.overrides.find("", "server_1", "/users/{id}", "GET"))
+
+	server.accessLogger.SetLevel(LevelError)
+	w = serve(router, "DELETE", "/api/v1/state", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = serve(router, "GET", "/api/v1/state", "")
+	assert.Equal(t, `{"version":1,"statistics":[],"overrides":[],"log_level":"info"}`, w.Body.String())
+}
+
+func TestStateWithoutStatistics(t *testing.T) {
+	server := newServerWithMockServers(t)
+	router := server.createRouter()
+
+	w := serve(router, "GET", "/api/v1/state", "")
+	assert.Equal(t, `{"version":1,"statistics":null,"overrides":[],"log_level":"info"}`, w.Body.String())
+
+	w = serve(router, "PUT", "/api/v1/state", `{"version": 1, "statistics": [], "log_level": "warn"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, LevelWarn, server.accessLogger.Level())
+
+	w = serve(router, "PUT", "/api/v1/state", `{"version": 1, "statistics": [{"request": {"server": "server_1"}, "count": 1}]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, `{"error":"statistics are not collected"}`, w.Body.String())
+}
+
+func TestRestoreInvalidState(t *testing.T) {
+	server := newServerWithMockServers(t)
+	server.statisticsStorage = newStatisticsStorage()
+	server.overrides.set(ResponseOverride{ServerName: "server_1", Endpoint: "/users/{id}", StatusCode: 503})
+
+	for state, message := range map[string]string{
+		`{"version": 2}`:                      "state version 2 is not supported, expected 1",
+		`{"version": 1, "log_level": "loud"}`: "unknown log level loud, expected one of debug, info, warn, error",
+		`{"version": 1, "overrides": [{"server": "server_2", "endpoint": "/"}]}`:          "override 0: server server_2 is not found",
+		`{"version": 1, "statistics": [{"request": {"server": "server_1"}, "count": 0}]}`: "statistics entry 0: count 0 is not positive",
+		`{"version": "1"}`: "json: cannot unmarshal string",
+	} {
+		w := serve(server.createRouter(), "PUT", "/api/v1/state", state)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), message)
+	}
+
+	// nothing is changed by invalid states
+	assert.Len(t, server.overrides.list(), 1)
+}
+
+func TestReplaceSavesStatistics(t *testing.T) {
+	path, cleanup := tempStatisticsPath(t)
+	defer cleanup()
+
+	request1 := ReceivedRequest{ServerName: "server_1", URL: "/some_url", Method: "GET", StatusCode: 200}
+	request2 := ReceivedRequest{ServerName: "server_2", URL: "/some_url", Method: "GET", StatusCode: 200}
+
+	for name, open := range persistences {
+		storage := newStatisticsStorage()
+		storage.persistence, _ = open(path + name)
+		assert.Nil(t, storage.Start())
+		storage.add(request1)
+		storage.replace(requestsCounter{request2: 3})
+		storage.add(request2)
+		storage.Stop()
+
+		storage = newStatisticsStorage()
+		storage.persistence, _ = open(path + name)
+		assert.Nil(t, storage.Start(), name)
+		assert.Equal(t, requestsCounter{request2: 4}, storage.counts(), name)
+		storage.Stop()
+	}
+}
+
+func TestReplaceNeverBlocks(t *testing.T) {
+	path, cleanup := tempStatisticsPath(t)
+	defer cleanup()
+
+	storage := newStatisticsStorage()
+	storage.persistence, _ = NewJSONLinesPersistence(path)
+	assert.Nil(t, storage.restore())
+
+	request1 := ReceivedRequest{ServerName: "server_1", URL: "/url_1", Method: "GET"}
+	request2 := ReceivedRequest{ServerName: "server_1", URL: "/url_2", Method: "GET"}
+
+	// the queue is full and nobody saves changes yet
+	storage.changes = make(chan statisticsChange, 1)
+	storage.finished = make(chan struct{})
+	storage.add(request1)
+
+	done := make(chan struct{})
+	go func() {
+		storage.replace(requestsCounter{request2: 3})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("replacement is blocked by the persistence")
+	}
+	assert.True(t, storage.resync)
+
+	go storage.run()
+	storage.Stop()
+
+	storage = newStatisticsStorage()
+	storage.persistence, _ = NewJSONLinesPersistence(path)
+	assert.Nil(t, storage.Start())
+	assert.Equal(t, requestsCounter{request2: 3}, storage.counts())
+	storage.Stop()
+}
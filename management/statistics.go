@@ -1,8 +1,6 @@
 package management
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,42 +8,137 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 // ReceivedRequest represents a request that was sent to a mock server
 type ReceivedRequest struct {
-	ServerName string
-	URL        string
-	Method     string
-	StatusCode int
+	ServerName string `json:"server"`
+	// Endpoint is the url (or regex) of the matched endpoint like in the config. It's empty for unmatched requests
+	Endpoint string `json:"endpoint,omitempty"`
+	// URL is the requested path without the query
+	URL              string `json:"url"`
+	Method           string `json:"method"`
+	StatusCode       int    `json:"status"`
+	ValidationFailed bool   `json:"validation_failed,omitempty"`
+	Operation        string `json:"operation,omitempty"`
+	Unmatched        bool   `json:"unmatched,omitempty"`
+	// Session is a value of the session header. It's empty for requests without the header
+	Session string `json:"session,omitempty"`
 }
 
 func (request ReceivedRequest) String() string {
-	return fmt.Sprintf(
-		"server: %s; url: %s; method: %s; response status: %d",
-		request.ServerName,
+	description := fmt.Sprintf("server: %s; ", request.ServerName)
+	if request.Endpoint != "" {
+		description = fmt.Sprintf("%sendpoint: %s; ", description, request.Endpoint)
+	}
+	description = fmt.Sprintf(
+		"%surl: %s; method: %s; response status: %d",
+		description,
 		request.URL,
 		request.Method,
 		request.StatusCode,
 	)
+
+	if request.Operation != "" {
+		description = fmt.Sprintf("%s; operation: %s", description, request.Operation)
+	}
+	if request.Unmatched {
+		description = fmt.Sprintf("%s; unmatched", description)
+	}
+	if request.Session != "" {
+		description = fmt.Sprintf("%s; session: %s", description, request.Session)
+	}
+
+	return description
+}
+
+// RequestFilter selects requests in statistics. Empty fields match any requests
+type RequestFilter struct {
+	ServerName    string
+	Endpoint      string
+	URL           string
+	Method        string
+	OnlyUnmatched bool
+	Session       string
+}
+
+func (filter RequestFilter) pattern() requestPattern {
+	pattern := requestPattern{
+		ServerName: "*", Endpoint: "*", URL: "*", Method: "*", OnlyUnmatched: filter.OnlyUnmatched, Session: "*",
+	}
+
+	if filter.ServerName != "" {
+		pattern.ServerName = filter.ServerName
+	}
+	if filter.Endpoint != "" {
+		pattern.Endpoint = filter.Endpoint
+	}
+	if filter.URL != "" {
+		pattern.URL = filter.URL
+	}
+	if filter.Method != "" {
+		pattern.Method = strings.ToUpper(filter.Method)
+	}
+	if filter.Session != "" {
+		pattern.Session = filter.Session
+	}
+
+	return pattern
+}
+
+// Query returns parameters of the management API, which select the same requests
+func (filter RequestFilter) Query() url.Values {
+	query := url.Values{}
+	if filter.ServerName != "" {
+		query.Set("server", filter.ServerName)
+	}
+	if filter.Endpoint != "" {
+		query.Set("endpoint", filter.Endpoint)
+	}
+	if filter.URL != "" {
+		query.Set("url", filter.URL)
+	}
+	if filter.Method != "" {
+		query.Set("method", filter.Method)
+	}
+	if filter.OnlyUnmatched {
+		query.Set("unmatched", "true")
+	}
+	if filter.Session != "" {
+		query.Set("session", filter.Session)
+	}
+	return query
 }
 
 type requestPattern struct {
-	ServerName string
-	URL        string
-	Method     string
+	ServerName    string
+	Endpoint      string
+	URL           string
+	Method        string
+	OnlyUnmatched bool
+	Session       string
 }
 
 func (pattern requestPattern) matches(request ReceivedRequest) bool {
 	if pattern.ServerName != "*" && pattern.ServerName != request.ServerName {
 		return false
 	}
+	if pattern.Endpoint != "*" && pattern.Endpoint != request.Endpoint {
+		return false
+	}
 	if pattern.URL != "*" && pattern.URL != request.URL {
 		return false
 	}
 	if pattern.Method != "*" && pattern.Method != request.Method {
 		return false
 	}
+	if pattern.OnlyUnmatched && !request.Unmatched {
+		return false
+	}
+	if pattern.Session != "*" && pattern.Session != request.Session {
+		return false
+	}
 
 	return true
 }
@@ -60,6 +153,13 @@ func createRequestPatternFromQuery(URL *url.URL) requestPattern {
 		pattern.ServerName = "*"
 	}
 
+	endpoints, ok := URL.Query()["endpoint"]
+	if ok && len(endpoints) > 0 {
+		pattern.Endpoint = endpoints[0]
+	} else {
+		pattern.Endpoint = "*"
+	}
+
 	urls, ok := URL.Query()["url"]
 	if ok && len(urls) > 0 {
 		pattern.URL = urls[0]
@@ -74,79 +174,317 @@ func createRequestPatternFromQuery(URL *url.URL) requestPattern {
 		pattern.Method = "*"
 	}
 
+	unmatched, ok := URL.Query()["unmatched"]
+	if ok && len(unmatched) > 0 {
+		pattern.OnlyUnmatched, _ = strconv.ParseBool(unmatched[0])
+	}
+
+	sessions, ok := URL.Query()["session"]
+	if ok && len(sessions) > 0 {
+		pattern.Session = sessions[0]
+	} else {
+		pattern.Session = "*"
+	}
+
 	return pattern
 }
 
 type requestsCounter map[ReceivedRequest]int
 
-func (counter requestsCounter) MarshalJSON() ([]byte, error) {
-	buffer := bytes.NewBufferString("[")
-	length := len(counter)
-	count := 0
-	for request, requestsCount := range counter {
-		buffer.WriteString("{")
-		buffer.WriteString(fmt.Sprintf("\"server\":\"%s\",", request.ServerName))
-		buffer.WriteString(fmt.Sprintf("\"url\":\"%s\",", request.URL))
-		buffer.WriteString(fmt.Sprintf("\"method\":\"%s\",", request.Method))
-		buffer.WriteString(fmt.Sprintf("\"count\":%s", strconv.Itoa(requestsCount)))
-		buffer.WriteString("}")
-		count++
-		if count < length {
-			buffer.WriteString(",")
-		}
-	}
-	buffer.WriteString("]")
-	return buffer.Bytes(), nil
+// statisticsShards is a number of parts of counters, which are locked separately
+const statisticsShards = 32
+
+// statisticsShard counts a part of requests, selected by hashes of the requests
+type statisticsShard struct {
+	mutex    sync.Mutex
+	requests requestsCounter
 }
 
+// statisticsStorage counts requests in memory. Changes are passed to the persistence in background,
+// so a slow persistence never blocks handling of requests
 type statisticsStorage struct {
-	mutex           sync.RWMutex
-	RequestsChannel chan ReceivedRequest
-	requests        requestsCounter
+	// dropped is the first field to be aligned for atomic operations on 32-bit platforms
+	dropped uint64
+	// mutex is locked for reading to count and read requests, so different shards are used concurrently,
+	// and for writing to change all the statistics at once
+	mutex  sync.RWMutex
+	shards [statisticsShards]statisticsShard
+
+	// persistence saves statistics, so they are restored after restarts
+	persistence StatisticsPersistence
+	changes     chan statisticsChange
+	// saved are numbers of requests saved by the persistence. Only the running storage uses it
+	saved requestsCounter
+	// unsaved is a number of changes saved since the last compaction
+	unsaved  int
+	finished chan struct{}
+	stopped  bool
+	// resync is set when a deletion or a replacement couldn't be queued. Changes aren't queued then,
+	// and the persistence is compacted from the statistics in memory instead
+	resync bool
+}
+
+// statisticsChange is a received request, requests deleted from statistics or statistics replaced entirely
+type statisticsChange struct {
+	request  ReceivedRequest
+	deleted  []ReceivedRequest
+	replaced requestsCounter
 }
 
+// changesSize is a number of changes, which can wait to be saved, before new ones are dropped
+const changesSize = 10000
+
+// compactEvery is a number of saved changes, after which the persistence is compacted
+const compactEvery = 10000
+
 func newStatisticsStorage() *statisticsStorage {
 	storage := new(statisticsStorage)
-	storage.requests = make(requestsCounter)
-	storage.RequestsChannel = make(chan ReceivedRequest, 100)
+	storage.clear()
 	return storage
 }
 
+// clear drops all statistics. The caller holds the mutex for writing
+func (storage *statisticsStorage) clear() {
+	for i := range storage.shards {
+		storage.shards[i].requests = make(requestsCounter)
+	}
+}
+
+// hashString adds the string to the FNV-1a hash
+func hashString(hash uint32, value string) uint32 {
+	for i := 0; i < len(value); i++ {
+		hash ^= uint32(value[i])
+		hash *= 16777619
+	}
+	return hash
+}
+
+// shard returns the shard, which counts the request
+func (storage *statisticsStorage) shard(request ReceivedRequest) *statisticsShard {
+	hash := hashString(2166136261, request.ServerName)
+	hash = hashString(hash, request.URL)
+	hash = hashString(hash, request.Method)
+	hash = hashString(hash, request.Session)
+	hash ^= uint32(request.StatusCode)
+	return &storage.shards[hash%statisticsShards]
+}
+
+// droppedCount returns a number of requests, which were not saved, because the persistence was busy or stopped
+func (storage *statisticsStorage) droppedCount() uint64 {
+	return atomic.LoadUint64(&storage.dropped)
+}
+
+// add counts the request. It never blocks on the persistence: if it falls behind, the change is dropped.
+// Requests of different shards are counted concurrently
 func (storage *statisticsStorage) add(request ReceivedRequest) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	shard := storage.shard(request)
+	shard.mutex.Lock()
+	shard.requests[request]++
+	shard.mutex.Unlock()
+
+	if storage.changes == nil {
+		return
+	}
+	if storage.stopped {
+		atomic.AddUint64(&storage.dropped, 1)
+		return
+	}
+	if storage.resync {
+		return
+	}
+
+	select {
+	case storage.changes <- statisticsChange{request: request}:
+	default:
+		atomic.AddUint64(&storage.dropped, 1)
+	}
+}
+
+func (storage *statisticsStorage) del(pattern requestPattern) {
 	storage.mutex.Lock()
 	defer storage.mutex.Unlock()
-	storage.requests[request]++
+
+	var deleted []ReceivedRequest
+	for i := range storage.shards {
+		for request := range storage.shards[i].requests {
+			if pattern.matches(request) {
+				delete(storage.shards[i].requests, request)
+				deleted = append(deleted, request)
+			}
+		}
+	}
+
+	if len(deleted) > 0 {
+		storage.queue(statisticsChange{deleted: deleted})
+	}
 }
 
-func (storage *statisticsStorage) del(pattern requestPattern) {
+// replace drops all statistics and counts the requests instead
+func (storage *statisticsStorage) replace(requests requestsCounter) {
 	storage.mutex.Lock()
 	defer storage.mutex.Unlock()
 
-	for request := range storage.requests {
-		if pattern.matches(request) {
-			delete(storage.requests, request)
+	storage.clear()
+	saved := make(requestsCounter, len(requests))
+	for request, count := range requests {
+		storage.shard(request).requests[request] = count
+		saved[request] = count
+	}
+
+	storage.queue(statisticsChange{replaced: saved})
+}
+
+// queue passes the deletion or the replacement to the persistence without blocking. They aren't dropped,
+// otherwise deleted requests would be restored: when the persistence falls behind, it's marked for resync.
+// The caller holds the mutex
+func (storage *statisticsStorage) queue(change statisticsChange) {
+	if storage.changes == nil || storage.stopped || storage.resync {
+		return
+	}
+
+	select {
+	case storage.changes <- change:
+	default:
+		storage.resync = true
+	}
+}
+
+// synchronize replaces saved statistics with the ones in memory, if the storage is marked for resync.
+// Queued changes are already counted in memory, so they are discarded
+func (storage *statisticsStorage) synchronize() {
+	storage.mutex.Lock()
+	if !storage.resync {
+		storage.mutex.Unlock()
+		return
+	}
+
+	storage.resync = false
+	storage.saved = make(requestsCounter)
+	for i := range storage.shards {
+		for request, count := range storage.shards[i].requests {
+			storage.saved[request] = count
 		}
 	}
+	for drained := false; !drained; {
+		select {
+		case _, ok := <-storage.changes:
+			drained = !ok
+		default:
+			drained = true
+		}
+	}
+	storage.mutex.Unlock()
+
+	storage.unsaved = 0
+	if err := storage.persistence.Compact(storage.saved); err != nil {
+		log.Printf("[Statistics storage] Cannot compact statistics: %s", err)
+	}
+}
+
+// save passes the change to the persistence and compacts it from time to time
+func (storage *statisticsStorage) save(change statisticsChange) {
+	if change.replaced != nil {
+		storage.saved = change.replaced
+		storage.unsaved = 0
+		if err := storage.persistence.Compact(storage.saved); err != nil {
+			log.Printf("[Statistics storage] Cannot save statistics: %s", err)
+		}
+		return
+	}
+
+	var err error
+	if change.deleted != nil {
+		err = storage.persistence.Delete(change.deleted)
+		for _, request := range change.deleted {
+			delete(storage.saved, request)
+		}
+	} else {
+		err = storage.persistence.Add(change.request)
+		storage.saved[change.request]++
+	}
+	if err != nil {
+		log.Printf("[Statistics storage] Cannot save statistics: %s", err)
+	}
+
+	storage.unsaved++
+	if storage.unsaved < compactEvery {
+		return
+	}
+
+	storage.unsaved = 0
+	if err := storage.persistence.Compact(storage.saved); err != nil {
+		log.Printf("[Statistics storage] Cannot compact statistics: %s", err)
+	}
+}
+
+// restore loads statistics saved by the persistence and compacts it
+func (storage *statisticsStorage) restore() error {
+	requests, err := storage.persistence.Load()
+	if err != nil {
+		return fmt.Errorf("[Statistics storage] cannot load statistics: %s", err)
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	storage.saved = make(requestsCounter)
+	for request, count := range requests {
+		storage.shard(request).requests[request] += count
+		storage.saved[request] += count
+	}
+	log.Printf("[Statistics storage] Loaded %d records", len(requests))
+
+	return storage.persistence.Compact(storage.saved)
 }
 
 func (storage *statisticsStorage) get(request ReceivedRequest) int {
 	storage.mutex.RLock()
 	defer storage.mutex.RUnlock()
 
-	return storage.requests[request]
+	shard := storage.shard(request)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	return shard.requests[request]
 }
 
+// iterate calls the function for counters of requests until it returns false.
+// Requests are counted meanwhile, only the shard being iterated waits
 func (storage *statisticsStorage) iterate(f func(request ReceivedRequest, count int) bool) {
 	storage.mutex.RLock()
 	defer storage.mutex.RUnlock()
 
-	for request, count := range storage.requests {
-		if !f(request, count) {
+	for i := range storage.shards {
+		if !storage.shards[i].iterate(f) {
 			return
 		}
 	}
 }
 
+func (shard *statisticsShard) iterate(f func(request ReceivedRequest, count int) bool) bool {
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	for request, count := range shard.requests {
+		if !f(request, count) {
+			return false
+		}
+	}
+	return true
+}
+
+// counts returns a copy of all counters
+func (storage *statisticsStorage) counts() requestsCounter {
+	requests := make(requestsCounter)
+	storage.iterate(func(request ReceivedRequest, count int) bool {
+		requests[request] = count
+		return true
+	})
+	return requests
+}
+
 func (storage *statisticsStorage) filter(pattern requestPattern) requestsCounter {
 	records := make(requestsCounter)
 
@@ -166,33 +504,51 @@ func (storage *statisticsStorage) run() {
 	log.Printf("[Statistics storage] Starting...")
 
 	defer log.Printf("[Statistics storage] Stopped")
+	defer close(storage.finished)
 
-	for request := range storage.RequestsChannel {
-		storage.add(request)
+	for change := range storage.changes {
+		storage.save(change)
+		storage.synchronize()
 	}
 }
 
-func (storage *statisticsStorage) Start() {
+// Start restores saved statistics and begins to save changes
+func (storage *statisticsStorage) Start() error {
+	if storage.persistence == nil {
+		return nil
+	}
+
+	if err := storage.restore(); err != nil {
+		return err
+	}
+
+	storage.changes = make(chan statisticsChange, changesSize)
+	storage.finished = make(chan struct{})
 	go storage.run()
+	return nil
 }
 
+// Stop waits until all changes are saved and closes the persistence.
+// Requests are still counted after that, but they are not saved
 func (storage *statisticsStorage) Stop() {
-	close(storage.RequestsChannel)
-}
+	storage.mutex.Lock()
+	if storage.changes == nil || storage.stopped {
+		storage.mutex.Unlock()
+		return
+	}
+	storage.stopped = true
+	close(storage.changes)
+	storage.mutex.Unlock()
 
-func (storage *statisticsStorage) GetStatisticsHandler(w http.ResponseWriter, req *http.Request) {
-	pattern := createRequestPatternFromQuery(req.URL)
+	<-storage.finished
+	storage.synchronize()
 
-	statistics := storage.filter(pattern)
-	payload, err := json.Marshal(statistics)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("Internal server error"))
-		return
+	if err := storage.persistence.Compact(storage.saved); err != nil {
+		log.Printf("[Statistics storage] Cannot compact statistics: %s", err)
+	}
+	if err := storage.persistence.Close(); err != nil {
+		log.Printf("[Statistics storage] Cannot close statistics: %s", err)
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(payload)
 }
 
 func (storage *statisticsStorage) DeleteStatisticsHandler(w http.ResponseWriter, req *http.Request) {
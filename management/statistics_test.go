@@ -1,14 +1,20 @@
 package management
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/pokidovea/mimicro/mockServer"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -66,10 +72,10 @@ func TestDeleteRequests(t *testing.T) {
 
 	storage.del(pattern)
 
-	assert.Equal(t, 1, len(storage.requests))
+	assert.Equal(t, 1, len(storage.counts()))
 }
 
-func TestCollectFromChannel(t *testing.T) {
+func TestCollectConcurrently(t *testing.T) {
 	storage := newStatisticsStorage()
 
 	request := ReceivedRequest{
@@ -80,13 +86,20 @@ func TestCollectFromChannel(t *testing.T) {
 
 	storage.Start()
 
-	storage.RequestsChannel <- request
-	storage.RequestsChannel <- request
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				storage.add(request)
+			}
+		}()
+	}
+	wg.Wait()
 	storage.Stop()
 
-	time.Sleep(10 * time.Millisecond)
-
-	assert.Equal(t, 2, storage.get(request))
+	assert.Equal(t, 1000, storage.get(request))
 }
 
 func TestStringifyRequest(t *testing.T) {
@@ -136,14 +149,12 @@ func TestGetStatisticsHandlerWhenNothingPassed(t *testing.T) {
 
 	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
-	expectedValues := []string{
-		`[{"server":"server_1","url":"/some_url","method":"POST","count":2},` +
-			`{"server":"server_2","url":"/another_url","method":"GET","count":1}]`,
-		`[{"server":"server_2","url":"/another_url","method":"GET","count":1},` +
-			`{"server":"server_1","url":"/some_url","method":"POST","count":2}]`,
-	}
-
-	assert.Contains(t, expectedValues, string(body))
+	assert.Equal(
+		t,
+		`{"records":[{"server":"server_1","url":"/some_url","method":"POST","count":2,"statuses":{"0":2}},{"server":"server_2","url":"/another_url","method":"GET","count":1,"statuses":{"0":1}}`+
+			`],"total":2,"offset":0,"totals":[{"server":"server_1","method":"POST","count":2},{"server":"server_2","method":"GET","count":1}],"dropped":0}`,
+		string(body),
+	)
 }
 
 func TestGetStatisticsHandlerWhenServerNamePassed(t *testing.T) {
@@ -185,14 +196,12 @@ func TestGetStatisticsHandlerWhenServerNamePassed(t *testing.T) {
 
 	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
-	expectedValues := []string{
-		`[{"server":"server_1","url":"/some_url","method":"POST","count":2},` +
-			`{"server":"server_1","url":"/another_url","method":"GET","count":1}]`,
-		`[{"server":"server_1","url":"/another_url","method":"GET","count":1},` +
-			`{"server":"server_1","url":"/some_url","method":"POST","count":2}]`,
-	}
-
-	assert.Contains(t, expectedValues, string(body))
+	assert.Equal(
+		t,
+		`{"records":[{"server":"server_1","url":"/another_url","method":"GET","count":1,"statuses":{"0":1}},{"server":"server_1","url":"/some_url","method":"POST","count":2,"statuses":{"0":2}}`+
+			`],"total":2,"offset":0,"totals":[{"server":"server_1","method":"GET","count":1},{"server":"server_1","method":"POST","count":2}],"dropped":0}`,
+		string(body),
+	)
 }
 
 func TestGetStatisticsHandlerWhenURLPassed(t *testing.T) {
@@ -234,14 +243,12 @@ func TestGetStatisticsHandlerWhenURLPassed(t *testing.T) {
 
 	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
-	expectedValues := []string{
-		`[{"server":"server_1","url":"/another_url","method":"GET","count":2},` +
-			`{"server":"server_2","url":"/another_url","method":"POST","count":1}]`,
-		`[{"server":"server_2","url":"/another_url","method":"POST","count":1},` +
-			`{"server":"server_1","url":"/another_url","method":"GET","count":2}]`,
-	}
-
-	assert.Contains(t, expectedValues, string(body))
+	assert.Equal(
+		t,
+		`{"records":[{"server":"server_1","url":"/another_url","method":"GET","count":2,"statuses":{"0":2}},{"server":"server_2","url":"/another_url","method":"POST","count":1,"statuses":{"0":1}}`+
+			`],"total":2,"offset":0,"totals":[{"server":"server_1","method":"GET","count":2},{"server":"server_2","method":"POST","count":1}],"dropped":0}`,
+		string(body),
+	)
 }
 
 func TestGetStatisticsHandlerWhenMethodPassed(t *testing.T) {
@@ -283,14 +290,12 @@ func TestGetStatisticsHandlerWhenMethodPassed(t *testing.T) {
 
 	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
-	expectedValues := []string{
-		`[{"server":"server_1","url":"/some_url","method":"POST","count":1},` +
-			`{"server":"server_2","url":"/another_url","method":"POST","count":2}]`,
-		`[{"server":"server_2","url":"/another_url","method":"POST","count":2},` +
-			`{"server":"server_1","url":"/some_url","method":"POST","count":1}]`,
-	}
-
-	assert.Contains(t, expectedValues, string(body))
+	assert.Equal(
+		t,
+		`{"records":[{"server":"server_1","url":"/some_url","method":"POST","count":1,"statuses":{"0":1}},{"server":"server_2","url":"/another_url","method":"POST","count":2,"statuses":{"0":2}}`+
+			`],"total":2,"offset":0,"totals":[{"server":"server_1","method":"POST","count":1},{"server":"server_2","method":"POST","count":2}],"dropped":0}`,
+		string(body),
+	)
 }
 
 func TestGetStatisticsHandlerWhenPassedAllParams(t *testing.T) {
@@ -339,7 +344,11 @@ func TestGetStatisticsHandlerWhenPassedAllParams(t *testing.T) {
 	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 
-	assert.Equal(t, `[{"server":"server_2","url":"/another_url","method":"POST","count":1}]`, string(body))
+	assert.Equal(
+		t,
+		`{"records":[{"server":"server_2","url":"/another_url","method":"POST","count":1,"statuses":{"0":1}}],"total":1,"offset":0,"totals":[{"server":"server_2","method":"POST","count":1}],"dropped":0}`,
+		string(body),
+	)
 }
 
 func TestDeleteStatisticsHandlerWhenNothingPassed(t *testing.T) {
@@ -377,7 +386,7 @@ func TestDeleteStatisticsHandlerWhenNothingPassed(t *testing.T) {
 
 	assert.Contains(t, "OK", string(body))
 
-	assert.Len(t, storage.requests, 0)
+	assert.Len(t, storage.counts(), 0)
 }
 
 func TestDeleteStatisticsHandlerWhenServerNamePassed(t *testing.T) {
@@ -415,7 +424,7 @@ func TestDeleteStatisticsHandlerWhenServerNamePassed(t *testing.T) {
 
 	assert.Contains(t, "OK", string(body))
 
-	assert.Equal(t, requestsCounter{request1: 2}, storage.requests)
+	assert.Equal(t, requestsCounter{request1: 2}, storage.counts())
 }
 
 func TestDeleteStatisticsHandlerWhenURLPassed(t *testing.T) {
@@ -458,7 +467,7 @@ func TestDeleteStatisticsHandlerWhenURLPassed(t *testing.T) {
 
 	assert.Contains(t, "OK", string(body))
 
-	assert.Equal(t, requestsCounter{request1: 1}, storage.requests)
+	assert.Equal(t, requestsCounter{request1: 1}, storage.counts())
 }
 
 func TestDeleteStatisticsHandlerWhenMethodPassed(t *testing.T) {
@@ -501,5 +510,403 @@ func TestDeleteStatisticsHandlerWhenMethodPassed(t *testing.T) {
 
 	assert.Contains(t, "OK", string(body))
 
-	assert.Equal(t, requestsCounter{request2: 1, request3: 1}, storage.requests)
+	assert.Equal(t, requestsCounter{request2: 1, request3: 1}, storage.counts())
+}
+
+func TestStringifyGraphQLRequest(t *testing.T) {
+	request := ReceivedRequest{
+		ServerName: "Simple server",
+		URL:        "/graphql",
+		Method:     "POST",
+		StatusCode: http.StatusOK,
+		Operation:  "GetUser",
+	}
+
+	assert.Equal(
+		t,
+		"server: Simple server; url: /graphql; method: POST; response status: 200; operation: GetUser",
+		fmt.Sprintf("%s", request),
+	)
+}
+
+func TestGetStatisticsHandlerWhenUnmatchedPassed(t *testing.T) {
+	router := mux.NewRouter()
+	storage := newStatisticsStorage()
+	request1 := ReceivedRequest{
+		ServerName: "server_1",
+		URL:        "/some_url",
+		Method:     "GET",
+		StatusCode: http.StatusOK,
+	}
+	request2 := ReceivedRequest{
+		ServerName: "server_1",
+		URL:        "/forgotten_url",
+		Method:     "GET",
+		StatusCode: http.StatusNotFound,
+		Unmatched:  true,
+	}
+
+	storage.add(request1)
+	storage.add(request2)
+
+	router.HandleFunc("/url", storage.GetStatisticsHandler)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/url?unmatched=true", nil)
+
+	router.ServeHTTP(w, req)
+
+	resp := w.Result()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(
+		t,
+		`{"records":[{"server":"server_1","url":"/forgotten_url","method":"GET","count":1,"statuses":{"404":1},"unmatched":true}],"total":1,"offset":0,"totals":[{"server":"server_1","method":"GET","count":1}],"dropped":0}`,
+		string(body),
+	)
+	assert.Equal(
+		t,
+		"server: Simple server; url: /forgotten_url; method: GET; response status: 404; unmatched",
+		fmt.Sprintf("%s", ReceivedRequest{ServerName: "Simple server", URL: "/forgotten_url", Method: "GET", StatusCode: 404, Unmatched: true}),
+	)
+}
+
+func TestStringifyRequestWithEndpoint(t *testing.T) {
+	request := ReceivedRequest{
+		ServerName: "Simple server",
+		Endpoint:   "/users/{id}",
+		URL:        "/users/1",
+		Method:     "GET",
+		StatusCode: http.StatusOK,
+	}
+
+	assert.Equal(
+		t,
+		"server: Simple server; endpoint: /users/{id}; url: /users/1; method: GET; response status: 200",
+		request.String(),
+	)
+}
+
+func addUserRequests(storage *statisticsStorage) {
+	storage.add(ReceivedRequest{
+		ServerName: "server_1", Endpoint: "/users/{id}", URL: "/users/1", Method: "GET", StatusCode: http.StatusOK,
+	})
+	storage.add(ReceivedRequest{
+		ServerName: "server_1", Endpoint: "/users/{id}", URL: "/users/1", Method: "GET", StatusCode: http.StatusOK,
+	})
+	storage.add(ReceivedRequest{
+		ServerName: "server_1", Endpoint: "/users/{id}", URL: "/users/1", Method: "GET", StatusCode: http.StatusNotFound,
+	})
+	storage.add(ReceivedRequest{
+		ServerName: "server_1", Endpoint: "/users/{id}", URL: "/users/2", Method: "GET", StatusCode: http.StatusOK,
+	})
+	storage.add(ReceivedRequest{
+		ServerName: "server_1", Endpoint: "/orders", URL: "/orders", Method: "GET", StatusCode: http.StatusOK,
+	})
+}
+
+func getStatistics(storage *statisticsStorage, url string) (int, string) {
+	router := mux.NewRouter()
+	router.HandleFunc("/url", storage.GetStatisticsHandler)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+
+	body, _ := ioutil.ReadAll(w.Result().Body)
+	return w.Code, string(body)
+}
+
+func TestGetStatisticsHandlerWhenURLPassedWithStatuses(t *testing.T) {
+	storage := newStatisticsStorage()
+	addUserRequests(storage)
+
+	status, body := getStatistics(storage, "/url?url=/users/1")
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(
+		t,
+		`{"records":[{"server":"server_1","endpoint":"/users/{id}","url":"/users/1","method":"GET","count":3,"statuses":{"200":2,"404":1}}],`+
+			`"total":1,"offset":0,"totals":[{"server":"server_1","method":"GET","count":3}],"dropped":0}`,
+		body,
+	)
+}
+
+func TestGetStatisticsHandlerWhenEndpointPassed(t *testing.T) {
+	storage := newStatisticsStorage()
+	addUserRequests(storage)
+
+	status, body := getStatistics(storage, "/url?endpoint=/users/{id}")
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(
+		t,
+		`{"records":[{"server":"server_1","endpoint":"/users/{id}","url":"/users/1","method":"GET","count":3,"statuses":{"200":2,"404":1}},`+
+			`{"server":"server_1","endpoint":"/users/{id}","url":"/users/2","method":"GET","count":1,"statuses":{"200":1}}],`+
+			`"total":2,"offset":0,"totals":[{"server":"server_1","method":"GET","count":4}],"dropped":0}`,
+		body,
+	)
+}
+
+func TestGetStatisticsHandlerGroupedByEndpoint(t *testing.T) {
+	storage := newStatisticsStorage()
+	addUserRequests(storage)
+
+	status, body := getStatistics(storage, "/url?endpoint=/users/{id}&group_by=endpoint")
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(
+		t,
+		`{"records":[{"server":"server_1","endpoint":"/users/{id}","method":"GET","count":4,"statuses":{"200":3,"404":1}}],`+
+			`"total":1,"offset":0,"totals":[{"server":"server_1","method":"GET","count":4}],"dropped":0}`,
+		body,
+	)
+
+	status, body = getStatistics(storage, "/url?group_by=status")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "group_by must be url or endpoint", body)
+}
+
+func TestDeleteStatisticsHandlerWhenEndpointPassed(t *testing.T) {
+	router := mux.NewRouter()
+	storage := newStatisticsStorage()
+	addUserRequests(storage)
+
+	router.HandleFunc("/url", storage.DeleteStatisticsHandler)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/url?endpoint=/users/{id}", nil))
+
+	assert.Equal(
+		t,
+		requestsCounter{
+			{ServerName: "server_1", Endpoint: "/orders", URL: "/orders", Method: "GET", StatusCode: http.StatusOK}: 1,
+		},
+		storage.counts(),
+	)
+}
+
+func TestGetStatisticsHandlerEscapesJSON(t *testing.T) {
+	storage := newStatisticsStorage()
+	storage.add(ReceivedRequest{ServerName: "server_1", URL: `/say/"hi"\`, Method: "GET", StatusCode: http.StatusOK})
+
+	_, body := getStatistics(storage, "/url")
+
+	var report StatisticsReport
+	assert.Nil(t, json.Unmarshal([]byte(body), &report))
+	assert.Equal(t, `/say/"hi"\`, report.Records[0].URL)
+}
+
+func TestGetStatisticsHandlerWithPagination(t *testing.T) {
+	storage := newStatisticsStorage()
+	addUserRequests(storage)
+
+	status, body := getStatistics(storage, "/url?offset=1&limit=1")
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(
+		t,
+		`{"records":[{"server":"server_1","endpoint":"/users/{id}","url":"/users/1","method":"GET","count":3,"statuses":{"200":2,"404":1}}],`+
+			`"total":3,"offset":1,"limit":1,"totals":[{"server":"server_1","method":"GET","count":5}],"dropped":0}`,
+		body,
+	)
+
+	_, body = getStatistics(storage, "/url?offset=10")
+	assert.Equal(t, `{"records":[],"total":3,"offset":10,"totals":[{"server":"server_1","method":"GET","count":5}],"dropped":0}`, body)
+
+	status, body = getStatistics(storage, "/url?limit=-1")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "limit must be a non-negative integer", body)
+
+	// the end of the page doesn't overflow
+	status, body = getStatistics(storage, "/url?offset=1&limit="+strconv.FormatInt(math.MaxInt64, 10))
+	assert.Equal(t, http.StatusOK, status)
+	var report StatisticsReport
+	assert.Nil(t, json.Unmarshal([]byte(body), &report))
+	assert.Len(t, report.Records, 2)
+	assert.Equal(t, math.MaxInt64, report.Limit)
+}
+
+func TestGetStatisticsHandlerInCSV(t *testing.T) {
+	storage := newStatisticsStorage()
+	addUserRequests(storage)
+	storage.add(ReceivedRequest{ServerName: "server_1", URL: "/a,b", Method: "GET", StatusCode: http.StatusNotFound, Unmatched: true})
+	storage.add(ReceivedRequest{ServerName: "server_1", URL: "/a,b", Method: "GET", StatusCode: http.StatusNotFound, Unmatched: true, Session: "test-1"})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/url", storage.GetStatisticsHandler)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/url?format=csv&url=*", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	assert.Equal(
+		t,
+		"server,endpoint,url,method,status,count,validation_failed,operation,unmatched,session\n"+
+			"server_1,,\"/a,b\",GET,404,1,false,,true,\n"+
+			"server_1,,\"/a,b\",GET,404,1,false,,true,test-1\n"+
+			"server_1,/orders,/orders,GET,200,1,false,,false,\n"+
+			"server_1,/users/{id},/users/1,GET,200,2,false,,false,\n"+
+			"server_1,/users/{id},/users/1,GET,404,1,false,,false,\n"+
+			"server_1,/users/{id},/users/2,GET,200,1,false,,false,\n",
+		w.Body.String(),
+	)
+}
+
+func TestAddNeverBlocks(t *testing.T) {
+	path, cleanup := tempStatisticsPath(t)
+	defer cleanup()
+
+	storage := newStatisticsStorage()
+	storage.persistence, _ = NewJSONLinesPersistence(path)
+	assert.Nil(t, storage.restore())
+	// nobody saves changes, so they can't be passed to the persistence
+	storage.changes = make(chan statisticsChange)
+
+	request := ReceivedRequest{ServerName: "server_1", URL: "/some_url", Method: "GET"}
+	storage.add(request)
+	storage.add(request)
+
+	assert.Equal(t, 2, storage.get(request))
+	assert.Equal(t, uint64(2), storage.droppedCount())
+}
+
+func TestDeleteNeverBlocks(t *testing.T) {
+	path, cleanup := tempStatisticsPath(t)
+	defer cleanup()
+
+	storage := newStatisticsStorage()
+	storage.persistence, _ = NewJSONLinesPersistence(path)
+	assert.Nil(t, storage.restore())
+
+	request1 := ReceivedRequest{ServerName: "server_1", URL: "/url_1", Method: "GET"}
+	request2 := ReceivedRequest{ServerName: "server_1", URL: "/url_2", Method: "GET"}
+	storage.add(request1)
+	storage.add(request2)
+
+	// the queue is full and nobody saves changes yet
+	storage.changes = make(chan statisticsChange, 1)
+	storage.finished = make(chan struct{})
+	storage.add(request2)
+
+	done := make(chan struct{})
+	go func() {
+		storage.del(requestPattern{ServerName: "*", Endpoint: "*", URL: "/url_1", Method: "*", Session: "*"})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("deletion is blocked by the persistence")
+	}
+	assert.True(t, storage.resync)
+
+	go storage.run()
+	storage.Stop()
+	assert.Equal(t, uint64(0), storage.droppedCount())
+
+	// the persistence is compacted from the statistics in memory, so the deletion isn't lost
+	storage = newStatisticsStorage()
+	storage.persistence, _ = NewJSONLinesPersistence(path)
+	assert.Nil(t, storage.Start())
+	assert.Equal(t, requestsCounter{request2: 2}, storage.counts())
+	storage.Stop()
+}
+
+func TestAddAfterStop(t *testing.T) {
+	path, cleanup := tempStatisticsPath(t)
+	defer cleanup()
+
+	storage := newStatisticsStorage()
+	storage.persistence, _ = NewJSONLinesPersistence(path)
+	request := ReceivedRequest{ServerName: "server_1", URL: "/some_url", Method: "GET"}
+
+	assert.Nil(t, storage.Start())
+	storage.add(request)
+	storage.Stop()
+	storage.Stop()
+
+	assert.NotPanics(t, func() { storage.add(request) })
+	assert.NotPanics(t, func() { storage.del(requestPattern{ServerName: "*", Endpoint: "*", URL: "*", Method: "*"}) })
+
+	assert.Equal(t, uint64(1), storage.droppedCount())
+
+	_, body := getStatistics(storage, "/url")
+	assert.Contains(t, body, `"dropped":1}`)
+}
+
+func TestRequestFilterQuery(t *testing.T) {
+	for _, filter := range []RequestFilter{
+		{},
+		{ServerName: "server_1", Method: "get"},
+		{Endpoint: "/users/{id}", URL: "/users/1?x=y", OnlyUnmatched: true},
+		{Session: "test-1"},
+	} {
+		URL := &url.URL{Path: "/api/v1/statistics", RawQuery: filter.Query().Encode()}
+		assert.Equal(t, filter.pattern(), createRequestPatternFromQuery(URL))
+	}
+}
+
+func TestStatisticsReportRequests(t *testing.T) {
+	storage := newStatisticsStorage()
+	requests := requestsCounter{
+		{ServerName: "server_1", Endpoint: "/users/{id}", URL: "/users/1", Method: "GET", StatusCode: 200}: 2,
+		{ServerName: "server_1", Endpoint: "/users/{id}", URL: "/users/1", Method: "GET", StatusCode: 404}: 1,
+		{ServerName: "server_1", URL: "/unknown", Method: "POST", StatusCode: 404, Unmatched: true}:        1,
+	}
+	for request, count := range requests {
+		for i := 0; i < count; i++ {
+			storage.add(request)
+		}
+	}
+
+	report := newStatisticsReport(storage.filter(RequestFilter{}.pattern()).records(groupByURL), 0, 0)
+	assert.Equal(t, map[ReceivedRequest]int(requests), report.Requests())
+}
+
+func TestSessionsPartitionStatistics(t *testing.T) {
+	server := NewServer(0, true)
+	router := server.createRouter()
+
+	for _, session := range []string{"test-1", "test-1", "test-2", ""} {
+		header := http.Header{}
+		if session != "" {
+			header.Set("X-Mimicro-Session", session)
+		}
+		server.WriteRequestLog(mockServer.RequestLog{
+			ServerName: "server_1", URL: "/users", Method: "GET", StatusCode: 200, Header: header,
+		})
+	}
+
+	w := serve(router, "GET", "/api/v1/statistics?session=test-1", "")
+	assert.Equal(
+		t,
+		`{"records":[{"server":"server_1","url":"/users","method":"GET","count":2,"statuses":{"200":2},"session":"test-1"}],`+
+			`"total":1,"offset":0,"totals":[{"server":"server_1","method":"GET","count":2}],"dropped":0}`,
+		w.Body.String(),
+	)
+
+	assert.Equal(t, 1, len(server.Statistics(RequestFilter{Session: "test-2"})))
+	assert.Equal(t, 3, len(server.Statistics(RequestFilter{})))
+
+	w = serve(router, "DELETE", "/api/v1/statistics?session=test-1", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, requestsCounter{
+		{ServerName: "server_1", URL: "/users", Method: "GET", StatusCode: 200, Session: "test-2"}: 1,
+		{ServerName: "server_1", URL: "/users", Method: "GET", StatusCode: 200}:                    1,
+	}, server.statisticsStorage.counts())
+}
+
+func TestCustomSessionHeader(t *testing.T) {
+	server := NewServer(0, true)
+	server.SetSessionHeader("X-Test")
+
+	header := http.Header{"X-Test": {"test-1"}, "X-Mimicro-Session": {"test-2"}}
+	server.WriteRequestLog(mockServer.RequestLog{ServerName: "server_1", URL: "/users", Method: "GET", StatusCode: 200, Header: header})
+
+	server.SetSessionHeader("")
+	server.WriteRequestLog(mockServer.RequestLog{ServerName: "server_1", URL: "/users", Method: "GET", StatusCode: 200, Header: header})
+
+	assert.Equal(t, requestsCounter{
+		{ServerName: "server_1", URL: "/users", Method: "GET", StatusCode: 200, Session: "test-1"}: 1,
+		{ServerName: "server_1", URL: "/users", Method: "GET", StatusCode: 200}:                    1,
+	}, server.statisticsStorage.counts())
 }
@@ -0,0 +1,232 @@
+package management
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pokidovea/mimicro/mockServer"
+)
+
+// tailBufferSize is a number of events kept for a slow client. Next events are dropped until it reads them
+const tailBufferSize = 100
+
+// tailHeartbeat is a period of comments sent to idle clients, so proxies don't close the stream
+const tailHeartbeat = 15 * time.Second
+
+// credentialHeaders are headers, which values are hidden from clients of the tail
+var credentialHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+	"X-Auth-Token":        true,
+}
+
+// redactedValue replaces values of credential headers
+const redactedValue = "[REDACTED]"
+
+// TailEvent is a request handled by a mock server with its response.
+// The response body is cut to mockServer.ResponseBodyLogLimit bytes
+type TailEvent struct {
+	Time             time.Time           `json:"time"`
+	ServerName       string              `json:"server"`
+	Endpoint         string              `json:"endpoint,omitempty"`
+	URL              string              `json:"url"`
+	Method           string              `json:"method"`
+	Headers          map[string][]string `json:"headers,omitempty"`
+	RemoteAddr       string              `json:"remote_addr,omitempty"`
+	StatusCode       int                 `json:"status"`
+	Bytes            int64               `json:"bytes"`
+	LatencyMs        float64             `json:"latency_ms"`
+	ValidationErrors []string            `json:"validation_errors,omitempty"`
+	Operation        string              `json:"operation,omitempty"`
+	Unmatched        bool                `json:"unmatched,omitempty"`
+	Session          string              `json:"session,omitempty"`
+
+	ResponseHeaders       map[string][]string `json:"response_headers,omitempty"`
+	ResponseBody          string              `json:"response_body,omitempty"`
+	ResponseBodyTruncated bool                `json:"response_body_truncated,omitempty"`
+}
+
+// redactHeaders returns a copy of the headers with hidden values of credentials
+func redactHeaders(header http.Header) map[string][]string {
+	if len(header) == 0 {
+		return nil
+	}
+
+	redacted := make(map[string][]string, len(header))
+	for name, values := range header {
+		if credentialHeaders[http.CanonicalHeaderKey(name)] {
+			values = []string{redactedValue}
+		}
+		redacted[name] = values
+	}
+	return redacted
+}
+
+func newTailEvent(requestLog mockServer.RequestLog) TailEvent {
+	return TailEvent{
+		Time:             requestLog.Time,
+		ServerName:       requestLog.ServerName,
+		Endpoint:         requestLog.Endpoint,
+		URL:              requestLog.URL,
+		Method:           requestLog.Method,
+		Headers:          redactHeaders(requestLog.Header),
+		RemoteAddr:       requestLog.RemoteAddr,
+		StatusCode:       requestLog.StatusCode,
+		Bytes:            requestLog.Bytes,
+		LatencyMs:        float64(requestLog.Duration/time.Microsecond) / 1000,
+		ValidationErrors: requestLog.ValidationErrors,
+		Operation:        requestLog.Operation,
+		Unmatched:        requestLog.Unmatched,
+
+		ResponseHeaders:       redactHeaders(requestLog.ResponseHeader),
+		ResponseBody:          string(requestLog.ResponseBody),
+		ResponseBodyTruncated: requestLog.Bytes > int64(len(requestLog.ResponseBody)),
+	}
+}
+
+type tailSubscriber struct {
+	pattern requestPattern
+	events  chan TailEvent
+}
+
+// tail sends requests to clients of the live stream
+type tail struct {
+	mutex       sync.RWMutex
+	subscribers map[*tailSubscriber]struct{}
+	closed      bool
+}
+
+func newTail() *tail {
+	return &tail{subscribers: make(map[*tailSubscriber]struct{})}
+}
+
+// subscribe returns nil after the tail is closed
+func (t *tail) subscribe(pattern requestPattern) *tailSubscriber {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.closed {
+		return nil
+	}
+
+	subscriber := &tailSubscriber{pattern: pattern, events: make(chan TailEvent, tailBufferSize)}
+	t.subscribers[subscriber] = struct{}{}
+	return subscriber
+}
+
+func (t *tail) unsubscribe(subscriber *tailSubscriber) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if _, ok := t.subscribers[subscriber]; ok {
+		delete(t.subscribers, subscriber)
+		close(subscriber.events)
+	}
+}
+
+// publish sends the request to subscribers, whose filters match the received request. It never waits for slow subscribers
+func (t *tail) publish(requestLog mockServer.RequestLog, request ReceivedRequest) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	if len(t.subscribers) == 0 {
+		return
+	}
+
+	event := newTailEvent(requestLog)
+	event.Session = request.Session
+	for subscriber := range t.subscribers {
+		if !subscriber.pattern.matches(request) {
+			continue
+		}
+		select {
+		case subscriber.events <- event:
+		default:
+		}
+	}
+}
+
+// close ends streams of all subscribers
+func (t *tail) close() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.closed = true
+	for subscriber := range t.subscribers {
+		delete(t.subscribers, subscriber)
+		close(subscriber.events)
+	}
+}
+
+// TailHandler streams requests, which match the filter from the query, as server-sent events.
+// The connection is hijacked, so the write timeout of the management server doesn't end the stream
+func (t *tail) TailHandler(w http.ResponseWriter, req *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		writeError(w, req, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+
+	subscriber := t.subscribe(createRequestPatternFromQuery(req.URL))
+	if subscriber == nil {
+		writeError(w, req, http.StatusServiceUnavailable, "server is stopping")
+		return
+	}
+	defer t.unsubscribe(subscriber)
+
+	conn, buffer, err := hijacker.Hijack()
+	if err != nil {
+		log.Printf("[Management] Cannot start the tail: %s", err)
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Time{})
+
+	// the client doesn't send anything, so reading ends when it disconnects
+	disconnected := make(chan struct{})
+	go func() {
+		buffer.Reader.WriteTo(ioutil.Discard)
+		close(disconnected)
+	}()
+
+	buffer.WriteString("HTTP/1.1 200 OK\r\n")
+	buffer.WriteString("Content-Type: text/event-stream\r\n")
+	buffer.WriteString("Cache-Control: no-cache\r\n")
+	buffer.WriteString("Connection: close\r\n\r\n")
+	if err := buffer.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(tailHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event, ok := <-subscriber.events:
+			if !ok {
+				return
+			}
+			payload, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(buffer, "event: request\ndata: %s\n\n", payload)
+		case <-heartbeat.C:
+			buffer.WriteString(": heartbeat\n\n")
+		case <-disconnected:
+			return
+		}
+
+		if err := buffer.Flush(); err != nil {
+			return
+		}
+	}
+}
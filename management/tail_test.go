@@ -0,0 +1,177 @@
+package management
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pokidovea/mimicro/mockServer"
+	"github.com/stretchr/testify/assert"
+)
+
+// readEvent returns the data of the next event of the stream skipping heartbeats
+func readEvent(t *testing.T, reader *bufio.Reader) string {
+	var data string
+	for {
+		line, err := reader.ReadString('\n')
+		assert.Nil(t, err)
+
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		case line == "" && data != "":
+			return data
+		}
+	}
+}
+
+func TestTailStreamsFilteredRequests(t *testing.T) {
+	server := NewServer(4534, false)
+	httpServer := httptest.NewServer(server.createRouter())
+	defer httpServer.Close()
+
+	response, err := http.Get(httpServer.URL + "/api/v1/requests/tail?server=server_1&method=post")
+	assert.Nil(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+	requestLog := mockServer.RequestLog{
+		ServerName: "server_1",
+		Endpoint:   "/users",
+		URL:        "/users?active=true",
+		Method:     "POST",
+		StatusCode: http.StatusCreated,
+		Duration:   2 * time.Millisecond,
+		Time:       time.Date(2018, 3, 1, 12, 30, 15, 0, time.UTC),
+		Header:     http.Header{"Content-Type": {"application/json"}, "Authorization": {"Bearer secret"}},
+		Bytes:      12,
+
+		ResponseHeader: http.Header{"Content-Type": {"application/json"}, "Set-Cookie": {"session=secret"}},
+		ResponseBody:   []byte(`{"id": "42"}`),
+	}
+	server.WriteRequestLog(mockServer.RequestLog{ServerName: "server_2", URL: "/users", Method: "POST", StatusCode: 201})
+	server.WriteRequestLog(mockServer.RequestLog{ServerName: "server_1", URL: "/users", Method: "GET", StatusCode: 200})
+	server.WriteRequestLog(requestLog)
+
+	assert.Equal(
+		t,
+		`{"time":"2018-03-01T12:30:15Z","server":"server_1","endpoint":"/users","url":"/users?active=true","method":"POST",`+
+			`"headers":{"Authorization":["[REDACTED]"],"Content-Type":["application/json"]},"status":201,"bytes":12,"latency_ms":2,`+
+			`"response_headers":{"Content-Type":["application/json"],"Set-Cookie":["[REDACTED]"]},"response_body":"{\"id\": \"42\"}"}`,
+		readEvent(t, bufio.NewReader(response.Body)),
+	)
+}
+
+func TestTailEndsOnShutdown(t *testing.T) {
+	server := NewServer(4534, false)
+	httpServer := httptest.NewServer(server.createRouter())
+	defer httpServer.Close()
+
+	response, err := http.Get(httpServer.URL + "/api/v1/requests/tail")
+	assert.Nil(t, err)
+	defer response.Body.Close()
+
+	server.tail.close()
+
+	_, err = bufio.NewReader(response.Body).ReadString('\n')
+	assert.Equal(t, io.EOF, err)
+
+	response, err = http.Get(httpServer.URL + "/api/v1/requests/tail")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
+	response.Body.Close()
+}
+
+func TestTailEventOfTruncatedResponse(t *testing.T) {
+	event := newTailEvent(mockServer.RequestLog{Bytes: mockServer.ResponseBodyLogLimit + 1, ResponseBody: []byte("{")})
+	assert.Equal(t, "{", event.ResponseBody)
+	assert.True(t, event.ResponseBodyTruncated)
+}
+
+func TestTailDropsEventsForSlowClients(t *testing.T) {
+	tail := newTail()
+	subscriber := tail.subscribe(createRequestPatternFromQuery(httptest.NewRequest("GET", "/", nil).URL))
+
+	for i := 0; i < tailBufferSize+10; i++ {
+		requestLog := mockServer.RequestLog{ServerName: "server_1", URL: "/users", Method: "GET", StatusCode: 200}
+		tail.publish(requestLog, newReceivedRequest(requestLog, DefaultSessionHeader))
+	}
+	assert.Equal(t, tailBufferSize, len(subscriber.events))
+
+	tail.unsubscribe(subscriber)
+	assert.Equal(t, 0, len(tail.subscribers))
+}
+
+// waitForSubscribers waits until the tail has the number of subscribers
+func waitForSubscribers(t *testing.T, tail *tail, count int) {
+	for i := 0; i < 100; i++ {
+		tail.mutex.RLock()
+		current := len(tail.subscribers)
+		tail.mutex.RUnlock()
+		if current == count {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("the tail doesn't have %d subscribers", count)
+}
+
+func TestTailNeverBlocksOnSlowClients(t *testing.T) {
+	server := NewServer(4534, false)
+	httpServer := httptest.NewServer(server.createRouter())
+	defer httpServer.Close()
+
+	// the client sends the request and never reads the stream
+	conn, err := net.Dial("tcp", httpServer.Listener.Addr().String())
+	assert.Nil(t, err)
+	defer conn.Close()
+	fmt.Fprintf(conn, "GET /api/v1/requests/tail HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	waitForSubscribers(t, server.tail, 1)
+
+	requestLog := mockServer.RequestLog{
+		ServerName:   "server_1",
+		URL:          "/users",
+		Method:       "GET",
+		StatusCode:   200,
+		Bytes:        mockServer.ResponseBodyLogLimit,
+		ResponseBody: []byte(strings.Repeat("x", mockServer.ResponseBodyLogLimit)),
+	}
+	done := make(chan struct{})
+	go func() {
+		// much more than both the buffer of events and buffers of the connection hold
+		for i := 0; i < tailBufferSize*20; i++ {
+			server.WriteRequestLog(requestLog)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("publishing is blocked by a slow client")
+	}
+}
+
+func TestTailUnsubscribesDisconnectedClients(t *testing.T) {
+	server := NewServer(4534, false)
+	httpServer := httptest.NewServer(server.createRouter())
+	defer httpServer.Close()
+
+	response, err := http.Get(httpServer.URL + "/api/v1/requests/tail")
+	assert.Nil(t, err)
+	waitForSubscribers(t, server.tail, 1)
+
+	response.Body.Close()
+	waitForSubscribers(t, server.tail, 0)
+
+	// requests are still handled after that
+	server.WriteRequestLog(mockServer.RequestLog{ServerName: "server_1", URL: "/users", Method: "GET", StatusCode: 200})
+}
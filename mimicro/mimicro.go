@@ -0,0 +1,222 @@
+// Package mimicro starts mock servers inside Go programs and tests
+package mimicro
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pokidovea/mimicro/management"
+	"github.com/pokidovea/mimicro/mockServer"
+)
+
+// DefaultDrainTimeout limits the time of waiting for active requests on Close
+const DefaultDrainTimeout = 5 * time.Second
+
+// Config describes mock servers to start
+type Config struct {
+	// Collection is a loaded config. If it's nil, the config is loaded from YAML
+	Collection *mockServer.ServerCollection
+	// YAML is a config in YAML or JSON, which is loaded with LoaderOptions
+	YAML          []byte
+	LoaderOptions mockServer.LoaderOptions
+	// ManagementPort is a port of the management server. The port 0 means a random free port
+	ManagementPort int
+	// ManagementBind is an address of the interface for the management server. All interfaces are used by default
+	ManagementBind string
+	// ManagementAuth protects the management server with a token or basic credentials
+	ManagementAuth management.Auth
+	// DisableStatistics turns off collecting statistics of requests
+	DisableStatistics bool
+	// StatisticsPersistence saves statistics, so they are restored after restarts. It's closed by Close
+	StatisticsPersistence management.StatisticsPersistence
+	// AccessLogger writes logs of requests. By default they are written in the text format by the standard logger
+	AccessLogger *management.AccessLogger
+	// EnableMetrics turns on serving Prometheus metrics on /metrics of the management server
+	EnableMetrics bool
+	// DrainTimeout limits the time of waiting for active requests on Close. DefaultDrainTimeout is used by default
+	DrainTimeout time.Duration
+	// SessionHeader separates statistics and overrides of sessions. management.DefaultSessionHeader is used by default
+	SessionHeader string
+}
+
+// Instance is a set of started mock servers with a management server, which collects statistics
+type Instance struct {
+	servers      []*mockServer.RunningServer
+	management   *management.Server
+	drainTimeout time.Duration
+	closeOnce    sync.Once
+	closeErr     error
+	closed       chan struct{}
+}
+
+// Start loads the config and starts all its servers. Servers with the port 0 are bound to random free ports.
+// The instance is closed when the context is done
+func Start(ctx context.Context, config Config) (*Instance, error) {
+	collection := config.Collection
+	if collection == nil {
+		var err error
+		options := config.LoaderOptions
+		options.Strict = true
+
+		collection, err = mockServer.NewLoader(options).LoadData(config.YAML)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	instance := &Instance{
+		management:   management.NewServer(config.ManagementPort, !config.DisableStatistics),
+		drainTimeout: config.DrainTimeout,
+		closed:       make(chan struct{}),
+	}
+	instance.management.Bind = config.ManagementBind
+	instance.management.SetAuth(config.ManagementAuth)
+	if config.EnableMetrics {
+		instance.management.EnableMetrics()
+	}
+	if config.AccessLogger != nil {
+		instance.management.SetAccessLogger(config.AccessLogger)
+	}
+	if config.StatisticsPersistence != nil {
+		instance.management.SetStatisticsPersistence(config.StatisticsPersistence)
+	}
+	if config.SessionHeader != "" {
+		instance.management.SetSessionHeader(config.SessionHeader)
+	}
+	instance.management.SetMockServers(collection.Servers)
+	if instance.drainTimeout == 0 {
+		instance.drainTimeout = DefaultDrainTimeout
+	}
+	if err := instance.management.Start(); err != nil {
+		return nil, err
+	}
+
+	addresses := []management.ServerAddress{}
+	for _, server := range collection.Servers {
+		running, err := server.WithOverrides(instance.management.FindOverride).Start(
+			instance.management.WriteRequestLog, instance.management.InFlightMiddleware(server.Name),
+		)
+		if err != nil {
+			instance.Close()
+			return nil, err
+		}
+		instance.servers = append(instance.servers, running)
+		addresses = append(addresses, management.ServerAddress{Name: running.Name, Address: running.Addr.String()})
+	}
+	instance.management.SetReady(addresses)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			instance.Close()
+		case <-instance.closed:
+		}
+	}()
+
+	return instance, nil
+}
+
+// Addr returns the address of the server with the name
+func (instance *Instance) Addr(serverName string) (net.Addr, error) {
+	for _, server := range instance.servers {
+		if server.Name == serverName {
+			return server.Addr, nil
+		}
+	}
+	return nil, fmt.Errorf("server %s is not found", serverName)
+}
+
+// URL returns the base URL of the server with the name like http://127.0.0.1:4573
+func (instance *Instance) URL(serverName string) (string, error) {
+	addr, err := instance.Addr(serverName)
+	if err != nil {
+		return "", err
+	}
+	return "http://" + localAddress(addr), nil
+}
+
+// ManagementURL returns the base URL of the management server
+func (instance *Instance) ManagementURL() string {
+	return "http://" + localAddress(instance.management.Addr)
+}
+
+// localAddress replaces unspecified IPs like [::] with the loopback address
+func localAddress(addr net.Addr) string {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok || (tcpAddr.IP != nil && !tcpAddr.IP.IsUnspecified()) {
+		return addr.String()
+	}
+	return fmt.Sprintf("127.0.0.1:%d", tcpAddr.Port)
+}
+
+// Statistics returns numbers of requests received by the servers, which match the filter
+func (instance *Instance) Statistics(filter management.RequestFilter) map[management.ReceivedRequest]int {
+	return instance.management.Statistics(filter)
+}
+
+// RequestsCount returns the total number of requests, which match the filter
+func (instance *Instance) RequestsCount(filter management.RequestFilter) int {
+	total := 0
+	for _, count := range instance.Statistics(filter) {
+		total += count
+	}
+	return total
+}
+
+// ResetStatistics deletes statistics of requests, which match the filter
+func (instance *Instance) ResetStatistics(filter management.RequestFilter) {
+	instance.management.ResetStatistics(filter)
+}
+
+// DroppedStatistics returns a number of requests, which were not saved by the statistics persistence, because they came too fast
+func (instance *Instance) DroppedStatistics() uint64 {
+	return instance.management.DroppedStatistics()
+}
+
+// State returns statistics, overrides and the level of access logs, so they can be restored by RestoreState
+func (instance *Instance) State() management.State {
+	return instance.management.State()
+}
+
+// RestoreState replaces statistics, overrides and the level of access logs with the saved ones
+func (instance *Instance) RestoreState(state management.State) error {
+	return instance.management.RestoreState(state)
+}
+
+// ResetState deletes statistics and overrides and restores the level of access logs passed on start
+func (instance *Instance) ResetState() {
+	instance.management.ResetState()
+}
+
+// Close stops all servers. Active requests are completed before, unless the drain timeout expires.
+// Concurrent calls wait until the instance is closed
+func (instance *Instance) Close() error {
+	instance.closeOnce.Do(func() {
+		close(instance.closed)
+		instance.management.SetStopping()
+
+		ctx, cancel := context.WithTimeout(context.Background(), instance.drainTimeout)
+		defer cancel()
+
+		var problems []string
+		for _, server := range instance.servers {
+			if err := server.Shutdown(ctx); err != nil {
+				problems = append(problems, fmt.Sprintf("[%s] %s", server.Name, err))
+			}
+		}
+		if err := instance.management.Shutdown(ctx); err != nil {
+			problems = append(problems, fmt.Sprintf("[Management] %s", err))
+		}
+
+		if len(problems) > 0 {
+			instance.closeErr = errors.New(strings.Join(problems, "\n"))
+		}
+	})
+
+	return instance.closeErr
+}
@@ -0,0 +1,318 @@
+package mimicro
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pokidovea/mimicro/management"
+	"github.com/pokidovea/mimicro/mockServer"
+	"github.com/stretchr/testify/assert"
+)
+
+const config = `
+servers:
+  - name: server_1
+    port: 0
+    endpoints:
+      - url: /users/{id}
+        GET:
+          template: "user {{.id}}"
+  - name: server_2
+    port: 0
+    endpoints: []
+`
+
+func get(t *testing.T, url string) (int, string) {
+	resp, err := http.Get(url)
+	if !assert.Nil(t, err) {
+		return 0, ""
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestStartAndClose(t *testing.T) {
+	instance, err := Start(context.Background(), Config{YAML: []byte(config)})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	url1, err := instance.URL("server_1")
+	assert.Nil(t, err)
+	url2, err := instance.URL("server_2")
+	assert.Nil(t, err)
+	assert.NotEqual(t, url1, url2)
+
+	_, err = instance.URL("server_3")
+	assert.Equal(t, "server server_3 is not found", err.Error())
+
+	status, body := get(t, url1+"/users/42")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "user 42", body)
+
+	status, _ = get(t, url2+"/users/42")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	assert.Equal(t, 1, instance.RequestsCount(management.RequestFilter{ServerName: "server_1", Method: "get"}))
+	assert.Equal(t, 1, instance.RequestsCount(management.RequestFilter{Endpoint: "/users/{id}"}))
+	assert.Equal(
+		t,
+		map[management.ReceivedRequest]int{
+			{ServerName: "server_2", URL: "/users/42", Method: "GET", StatusCode: 404, Unmatched: true}: 1,
+		},
+		instance.Statistics(management.RequestFilter{OnlyUnmatched: true}),
+	)
+
+	instance.ResetStatistics(management.RequestFilter{ServerName: "server_1"})
+	assert.Equal(t, 1, instance.RequestsCount(management.RequestFilter{}))
+
+	status, _ = get(t, instance.ManagementURL()+"/statistics/get")
+	assert.Equal(t, http.StatusOK, status)
+
+	addr1, _ := instance.Addr("server_1")
+	addr2, _ := instance.Addr("server_2")
+	status, body = get(t, instance.ManagementURL()+"/ready")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(
+		t,
+		`{"status":"ready","servers":[{"name":"server_1","address":"`+addr1.String()+
+			`"},{"name":"server_2","address":"`+addr2.String()+`"}]}`,
+		body,
+	)
+
+	addr, _ := instance.Addr("server_1")
+	assert.Nil(t, instance.Close())
+	assert.Nil(t, instance.Close())
+
+	_, err = net.Dial("tcp", addr.String())
+	assert.NotNil(t, err)
+}
+
+func TestStartWithCollection(t *testing.T) {
+	collection, err := mockServer.NewLoader(mockServer.LoaderOptions{}).LoadData([]byte(config))
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	instance, err := Start(ctx, Config{Collection: collection})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	addr, _ := instance.Addr("server_1")
+	conn, err := net.Dial("tcp", addr.String())
+	assert.Nil(t, err)
+	conn.Close()
+
+	cancel()
+
+	closed := false
+	for i := 0; i < 50 && !closed; i++ {
+		time.Sleep(10 * time.Millisecond)
+		if conn, err := net.Dial("tcp", addr.String()); err == nil {
+			conn.Close()
+		} else {
+			closed = true
+		}
+	}
+	assert.True(t, closed)
+}
+
+func TestStartWithMetrics(t *testing.T) {
+	instance, err := Start(context.Background(), Config{YAML: []byte(config), EnableMetrics: true})
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer instance.Close()
+
+	url, _ := instance.URL("server_1")
+	get(t, url+"/users/42")
+
+	status, body := get(t, instance.ManagementURL()+"/metrics")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `mimicro_requests_total{server="server_1",endpoint="/users/{id}",method="GET",status="200"} 1`)
+	assert.Contains(t, body, `mimicro_requests_in_flight{server="server_2"} 0`)
+}
+
+func TestOverrideEndpoint(t *testing.T) {
+	instance, err := Start(context.Background(), Config{YAML: []byte(config)})
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer instance.Close()
+
+	override := `{"server": "server_1", "endpoint": "/users/{id}", "status": 503, "body": "down"}`
+	req, _ := http.NewRequest("PUT", instance.ManagementURL()+"/api/v1/overrides", strings.NewReader(override))
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	url, _ := instance.URL("server_1")
+	status, body := get(t, url+"/users/42")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "down", body)
+	assert.Equal(t, 1, instance.RequestsCount(management.RequestFilter{Endpoint: "/users/{id}"}))
+
+	req, _ = http.NewRequest("DELETE", instance.ManagementURL()+"/api/v1/overrides", nil)
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+
+	status, body = get(t, url+"/users/42")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "user 42", body)
+}
+
+func TestBindAndAuth(t *testing.T) {
+	const config = `
+servers:
+  - name: server_1
+    port: 0
+    bind: 127.0.0.1
+    endpoints: []
+`
+	instance, err := Start(context.Background(), Config{
+		YAML:           []byte(config),
+		ManagementBind: "127.0.0.1",
+		ManagementAuth: management.Auth{Token: "secret"},
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer instance.Close()
+
+	addr, _ := instance.Addr("server_1")
+	assert.Equal(t, "127.0.0.1", addr.(*net.TCPAddr).IP.String())
+	assert.True(t, strings.HasPrefix(instance.ManagementURL(), "http://127.0.0.1:"))
+
+	status, _ := get(t, instance.ManagementURL()+"/health")
+	assert.Equal(t, http.StatusOK, status)
+
+	status, _ = get(t, instance.ManagementURL()+"/api/v1/servers")
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	req, _ := http.NewRequest("GET", instance.ManagementURL()+"/api/v1/servers", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+}
+
+func TestStatisticsPersistAfterRestart(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "mimicro")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "statistics")
+
+	for i := 1; i <= 2; i++ {
+		persistence, err := management.NewKeyValuePersistence(path)
+		assert.Nil(t, err)
+
+		instance, err := Start(context.Background(), Config{YAML: []byte(config), StatisticsPersistence: persistence})
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		url, _ := instance.URL("server_1")
+		get(t, url+"/users/42")
+		assert.Equal(t, i, instance.RequestsCount(management.RequestFilter{ServerName: "server_1"}))
+		assert.Nil(t, instance.Close())
+	}
+}
+
+func TestStartWithBusyPort(t *testing.T) {
+	listener, err := net.Listen("tcp", ":0")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer listener.Close()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	collection := &mockServer.ServerCollection{
+		Servers: []mockServer.MockServer{{Name: "server_1", Port: port}},
+	}
+
+	_, err = Start(context.Background(), Config{Collection: collection})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "[server_1] cannot bind port")
+}
+
+func TestStartWithInvalidConfig(t *testing.T) {
+	_, err := Start(context.Background(), Config{YAML: []byte("servers: [{name: server_1}]")})
+	assert.NotNil(t, err)
+}
+
+// slowFileSystem delays opening of files to keep requests active
+type slowFileSystem struct {
+	http.FileSystem
+	delay time.Duration
+}
+
+func (fs slowFileSystem) Open(name string) (http.File, error) {
+	if strings.HasSuffix(name, ".txt") {
+		time.Sleep(fs.delay)
+	}
+	return fs.FileSystem.Open(name)
+}
+
+func startWithSlowFile(t *testing.T, drainTimeout time.Duration) (*Instance, string, string) {
+	dir, err := ioutil.TempDir("", "mimicro")
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "slow.txt"), []byte("slow"), 0644))
+
+	collection, err := mockServer.NewLoader(mockServer.LoaderOptions{
+		FileSystem: slowFileSystem{http.Dir(dir), 300 * time.Millisecond},
+	}).LoadData([]byte(`
+servers:
+  - name: server_1
+    port: 0
+    endpoints:
+      - url: /slow
+        GET:
+          file: file://slow.txt
+`))
+	assert.Nil(t, err)
+
+	instance, err := Start(context.Background(), Config{Collection: collection, DrainTimeout: drainTimeout})
+	assert.Nil(t, err)
+
+	url, _ := instance.URL("server_1")
+	return instance, url + "/slow", dir
+}
+
+func TestCloseDrainsActiveRequests(t *testing.T) {
+	instance, url, dir := startWithSlowFile(t, time.Second)
+	defer os.RemoveAll(dir)
+
+	results := make(chan string, 1)
+	go func() {
+		_, body := get(t, url)
+		results <- body
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	assert.Nil(t, instance.Close())
+	assert.Equal(t, "slow", <-results)
+}
+
+func TestCloseWithExpiredDrainTimeout(t *testing.T) {
+	instance, url, dir := startWithSlowFile(t, 50*time.Millisecond)
+	defer os.RemoveAll(dir)
+
+	go http.Get(url)
+	time.Sleep(100 * time.Millisecond)
+
+	err := instance.Close()
+	assert.NotNil(t, err)
+	assert.Equal(t, "[server_1] context deadline exceeded", err.Error())
+}
@@ -0,0 +1,150 @@
+package mockServer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// sampleVarValue is passed to templates instead of real variables from URLs while checking responses
+const sampleVarValue = "1"
+
+// urlVars returns names of the variables of the URL template like /users/{id:[0-9]+}
+func urlVars(url string) []string {
+	var names []string
+
+	depth := 0
+	start := 0
+	for i, char := range url {
+		switch char {
+		case '{':
+			if depth == 0 {
+				start = i + 1
+			}
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				names = append(names, strings.SplitN(url[start:i], ":", 2)[0])
+			}
+		}
+	}
+
+	return names
+}
+
+// samplePath replaces variables of the URL template with sample values
+func samplePath(url string) string {
+	path := ""
+
+	depth := 0
+	for _, char := range url {
+		switch {
+		case char == '{':
+			if depth == 0 {
+				path += sampleVarValue
+			}
+			depth++
+		case char == '}':
+			depth--
+		case depth == 0:
+			path += string(char)
+		}
+	}
+
+	return path
+}
+
+// checkResponses renders every response of the server with sample variables
+// and validates it against the OpenAPI spec of the server and its own schema
+func (mockServer MockServer) checkResponses() []string {
+	var problems []string
+
+	if mockServer.DefaultResponse != nil {
+		for _, problem := range mockServer.DefaultResponse.check(nil, "", "", mockServer.params) {
+			problems = append(problems, fmt.Sprintf("%s default response: %s", mockServer.Name, problem))
+		}
+	}
+
+	for _, endpoint := range mockServer.Endpoints {
+		vars := make(map[string]string)
+		for name, value := range mockServer.params {
+			vars[name] = value
+		}
+		for _, name := range endpoint.varNames() {
+			vars[name] = sampleVarValue
+		}
+
+		// there is no way to make a sample path for a regex, so only schemas of responses are checked
+		spec := mockServer.OpenAPI
+		if endpoint.Regex != nil {
+			spec = nil
+		}
+
+		for _, method := range endpointMethods {
+			response := endpoint.getResponse(method)
+			if response == nil {
+				continue
+			}
+
+			for _, problem := range response.check(spec, method, endpoint.pathTemplate(), vars) {
+				problems = append(problems, fmt.Sprintf("%s %s %s: %s", mockServer.Name, method, endpoint.Pattern(), problem))
+			}
+		}
+	}
+
+	return problems
+}
+
+// dependsOnVars reports whether the template uses variables or functions
+func dependsOnVars(tmpl *template.Template) bool {
+	if tmpl.Tree == nil {
+		return false
+	}
+	for _, node := range tmpl.Tree.Root.Nodes {
+		if node.Type() != parse.NodeText {
+			return true
+		}
+	}
+	return false
+}
+
+func (response *Response) check(spec *OpenAPISpec, method, url string, vars map[string]string) []string {
+	// files can't be validated without a contract. Paths, which depend on variables, are skipped too,
+	// because sample values of variables don't point to real files
+	if response.file != nil && (dependsOnVars(response.file) || spec == nil && response.schema == nil) {
+		return nil
+	}
+
+	body, err := response.render(vars)
+	if err != nil {
+		return []string{fmt.Sprintf("cannot render the response: %s", err)}
+	}
+
+	var problems []string
+
+	if spec != nil {
+		problems = append(problems, spec.checkResponse(method, samplePath(url), response.StatusCode, response.Headers, body)...)
+	}
+
+	if response.schema != nil {
+		problems = append(problems, validateBody(response.schema, body, "response body")...)
+	}
+
+	return problems
+}
+
+func (serverCollection *ServerCollection) checkResponses() error {
+	var problems []string
+	for _, server := range serverCollection.Servers {
+		problems = append(problems, server.checkResponses()...)
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return errors.New(strings.Join(problems, "\n") + "\n")
+}
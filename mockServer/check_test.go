@@ -0,0 +1,107 @@
+package mockServer
+
+import (
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestURLVars(t *testing.T) {
+	assert.Nil(t, urlVars("/simple/url"))
+	assert.Equal(t, []string{"var", "id"}, urlVars("/{var}/in/{id:[0-9]{1,3}}"))
+	assert.Equal(t, "/1/in/1", samplePath("/{var}/in/{id:[0-9]{1,3}}"))
+}
+
+func TestCheckResponses(t *testing.T) {
+
+	config := `
+name: server_1
+port: 4573
+openapi: file://../examples/openapi.yaml
+endpoints:
+  - url: /pets/{id}
+    GET:
+      template: "{\"name\": \"pet {{.id}}\"}"
+      headers:
+        content-type: application/json
+        x-rate-limit: 10
+    DELETE:
+      template: "deleted"
+  - url: /pets
+    POST:
+      template: "{}"
+      status_code: 200
+  - url: /pets/{id}/owner
+    GET:
+      template: "{}"
+  - url: /owners/{id}
+    GET:
+      template: "{\"name\": {{.id}}}"
+      schema:
+        type: object
+        properties:
+          name:
+            type: string
+`
+	var server MockServer
+	err := yaml.Unmarshal([]byte(config), &server)
+	assert.Nil(t, err)
+
+	problems := server.checkResponses()
+
+	assert.Len(t, problems, 5)
+	assert.Equal(t, "server_1 DELETE /pets/{id}: method DELETE is not allowed for path /pets/1", problems[0])
+	assert.Equal(t, "server_1 POST /pets: status code 200 is not described in the OpenAPI spec", problems[1])
+	assert.Equal(t, "server_1 GET /pets/{id}/owner: path /pets/1/owner is not described in the OpenAPI spec", problems[2])
+	assert.Equal(t, "server_1 GET /owners/{id}: path /owners/1 is not described in the OpenAPI spec", problems[3])
+	assert.Contains(t, problems[4], "server_1 GET /owners/{id}: response body: name: Invalid type.")
+}
+
+func TestCheckResponseAgainstSpec(t *testing.T) {
+	spec := loadExampleSpec(t)
+
+	config := `
+template: "{\"tag\": \"{{.id}}\"}"
+headers:
+  content-type: application/json
+  x-rate-limit: many
+`
+	response := createResponseFromConfig(config)
+	problems := response.check(spec, "GET", "/pets/{id}", map[string]string{"id": "1"})
+
+	assert.Len(t, problems, 2)
+	assert.Contains(t, problems[0], `header "X-Rate-Limit": Invalid type.`)
+	assert.Equal(t, "response body: name is required", problems[1])
+
+	config = `
+template: "Not found"
+status_code: 404
+`
+	response = createResponseFromConfig(config)
+
+	assert.Nil(t, response.check(spec, "GET", "/pets/{id}", map[string]string{"id": "1"}))
+}
+
+func TestCheckSkipsFilesWithVariables(t *testing.T) {
+	config := `
+name: server_1
+port: 4573
+openapi: file://../examples/openapi.yaml
+endpoints:
+  - url: /pets/{id}
+    GET:
+      file: file://data/{{.id}}.json
+  - url: /pets
+    GET:
+      file: file://data/missing.json
+`
+	var server MockServer
+	err := yaml.Unmarshal([]byte(config), &server)
+	assert.Nil(t, err)
+
+	problems := server.checkResponses()
+
+	assert.Len(t, problems, 1)
+	assert.Contains(t, problems[0], "server_1 GET /pets: cannot render the response:")
+}
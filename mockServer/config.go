@@ -1,20 +1,113 @@
 package mockServer
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"path/filepath"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/ghodss/yaml"
 	"github.com/xeipuuv/gojsonschema"
 )
 
-var ConfigPath = ""
-
 // ServerCollection сontains a full configuration of servers
 type ServerCollection struct {
 	Servers []MockServer `json:"servers"`
+	Params  Params       `json:"params"`
+
+	// BaseDir is a directory of the main config file, against which its relative paths are resolved
+	BaseDir string `json:"-"`
+}
+
+// Params are values of the params section, which are passed to templates of responses
+type Params map[string]string
+
+// UnmarshalJSON accepts any scalar values and keeps them as strings
+func (params *Params) UnmarshalJSON(data []byte) error {
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+
+	*params = make(Params)
+	for key, value := range values {
+		(*params)[key] = fmt.Sprint(value)
+	}
+
+	return nil
+}
+
+// substitutionRegexp matches ${NAME}, ${NAME:-default} and escaped $${
+var substitutionRegexp = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// substitute replaces variables in the config with values returned by the lookup.
+// Variables, which are not set or empty, are replaced with their defaults, when they have them.
+// Comments are left as they are
+func substitute(data []byte, lookup func(name string) (string, bool)) ([]byte, error) {
+	var err error
+
+	replace := func(match []byte) []byte {
+		if string(match) == "$${" {
+			return []byte("${")
+		}
+
+		groups := substitutionRegexp.FindSubmatch(match)
+		name := string(groups[1])
+		hasDefault := len(groups[2]) > 0
+
+		if value, ok := lookup(name); ok && (value != "" || !hasDefault) {
+			return []byte(value)
+		}
+		if hasDefault {
+			return groups[3]
+		}
+
+		if err == nil {
+			err = fmt.Errorf("variable %s is not set", name)
+		}
+		return match
+	}
+
+	var result []byte
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		comment := commentStart(line)
+		result = append(result, substitutionRegexp.ReplaceAllFunc(line[:comment], replace)...)
+		result = append(result, line[comment:]...)
+	}
+
+	return result, err
+}
+
+// commentStart returns the position of the YAML comment in the line or the length of the line.
+// A comment starts with # after a whitespace outside of quoted strings
+func commentStart(line []byte) int {
+	var quote byte
+
+	for i := 0; i < len(line); i++ {
+		char := line[i]
+		switch {
+		case quote == '"' && char == '\\':
+			// skips the escaped char
+			i++
+		case quote != 0:
+			if char == quote {
+				quote = 0
+			}
+		case char == '"' || char == '\'':
+			// quotes start scalars only, so apostrophes in plain words are not quotes. '' is an escaped quote
+			if i == 0 || strings.IndexByte(" \t[{,:'", line[i-1]) >= 0 {
+				quote = char
+			}
+		case char == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return i
+		}
+	}
+
+	return len(line)
 }
 
 func validateSchema(data []byte) error {
@@ -55,31 +148,70 @@ func parseConfig(data []byte) (*ServerCollection, error) {
 	return &serverCollection, nil
 }
 
-// Load function loads the config from file into the ServerCollection structure
+// Load function loads the config from a file or a directory into the ServerCollection structure.
+// Included files are merged into the same collection
 // Returns ServerCollection structure
 func Load(configPath string) (*ServerCollection, error) {
-	data, err := ioutil.ReadFile(configPath)
+	return LoadWithParams(configPath, nil)
+}
 
-	if err != nil {
-		return nil, err
-	}
+// LoadWithParams loads the config like Load. Passed params override params of the config
+// and are substituted into the config instead of environment variables with the same names
+func LoadWithParams(configPath string, params map[string]string) (*ServerCollection, error) {
+	return NewLoader(LoaderOptions{Params: params}).Load(configPath)
+}
 
-	ConfigPath, err = filepath.Abs(configPath)
+// isWildcardBind checks if the bind address listens on all interfaces
+func isWildcardBind(bind string) bool {
+	return bind == "" || bind == "0.0.0.0" || bind == "::"
+}
 
-	if err != nil {
-		return nil, err
+// checkDuplicates returns an error if several servers have the same name or listen on the same address.
+// A server, which listens on all interfaces, conflicts with every server on the same port
+func (serverCollection *ServerCollection) checkDuplicates() error {
+	var problems []string
+	names := make(map[string]bool)
+	ports := make(map[int][]MockServer)
+
+	for _, server := range serverCollection.Servers {
+		if names[server.Name] {
+			problems = append(problems, fmt.Sprintf("server name %s is used more than once", server.Name))
+		}
+		names[server.Name] = true
+
+		// the port 0 means a random free port, so it can be shared
+		if server.Port == 0 {
+			continue
+		}
+		for _, other := range ports[server.Port] {
+			if server.Bind != other.Bind && !isWildcardBind(server.Bind) && !isWildcardBind(other.Bind) {
+				continue
+			}
+
+			address := fmt.Sprintf("port %d", server.Port)
+			if server.Bind != "" {
+				address = "address " + net.JoinHostPort(server.Bind, strconv.Itoa(server.Port))
+			}
+			problems = append(problems, fmt.Sprintf("%s is used by servers %s and %s", address, other.Name, server.Name))
+			break
+		}
+		ports[server.Port] = append(ports[server.Port], server)
 	}
 
-	return parseConfig(data)
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return errors.New(strings.Join(problems, "\n") + "\n")
 }
 
-// CheckConfig checks the json schema of pased config file
+// CheckConfig checks the json schema of pased config files and looks for duplicated servers.
+// Then it renders every response and validates it against the attached OpenAPI spec or JSON schema
 func CheckConfig(configPath string) error {
-	data, err := ioutil.ReadFile(configPath)
-
-	if err != nil {
-		return err
-	}
+	return CheckConfigWithParams(configPath, nil)
+}
 
-	return validateSchema(data)
+// CheckConfigWithParams checks the config like CheckConfig using passed params like LoadWithParams
+func CheckConfigWithParams(configPath string, params map[string]string) error {
+	return NewLoader(LoaderOptions{Params: params}).Check(configPath)
 }
@@ -1,8 +1,12 @@
 package mockServer
 
 import (
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"os"
 	"path"
+	"path/filepath"
 	"runtime"
 	"testing"
 
@@ -172,3 +176,302 @@ func TestLoadConfigFromFile(t *testing.T) {
 	assert.Nil(t, deleteResponse.file)
 	assert.Equal(t, http.StatusForbidden, deleteResponse.StatusCode)
 }
+
+func TestValidateGeneratedResponseConfig(t *testing.T) {
+	config := `
+servers:
+  - name: server_1
+    port: 4573
+    endpoints:
+      - url: /users/{id}
+        GET:
+          schema:
+            type: object
+            properties:
+              id:
+                type: integer
+          seed: 42
+          overrides:
+            id: "{{.id}}"
+          status_code: 201
+`
+	assert.Nil(t, validateSchema([]byte(config)))
+}
+
+func TestValidateEndpointWithURLAndRegex(t *testing.T) {
+	config := `
+servers:
+  - name: server_1
+    port: 4573
+    endpoints:
+      - regex: /users/(?P<id>[0-9]+)
+        host: "{tenant}.example.com"
+        schemes: [https]
+        priority: 10
+        GET:
+          template: "{}"
+      - url: /users/*
+        regex: /users/.*
+        GET:
+          template: "{}"
+`
+	err := validateSchema([]byte(config))
+
+	assert.NotNil(t, err)
+	assert.Equal(t, "servers.0.endpoints.1: Must validate one and only one schema (oneOf)\n", err.Error())
+}
+
+// writeConfigFiles creates files in a temporary directory and returns the path of the directory
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "mimicro")
+	assert.Nil(t, err)
+
+	for name, content := range files {
+		filePath := filepath.Join(dir, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(filePath), 0755))
+		assert.Nil(t, ioutil.WriteFile(filePath, []byte(content), 0644))
+	}
+
+	return dir
+}
+
+func TestLoadConfigWithIncludes(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": `
+include:
+  - teams/*.yaml
+  - config.yaml
+servers:
+  - name: server_1
+    port: 4573
+    endpoints:
+      - url: /url
+        GET:
+          template: file://response.txt
+`,
+		"response.txt": "main response",
+		"teams/billing.yaml": `
+servers:
+  - name: billing
+    port: 4574
+    endpoints:
+      - url: /invoices
+        GET:
+          template: file://responses/invoices.json
+`,
+		"teams/responses/invoices.json": "[]",
+		"teams/users.yaml":              "include: [../more]",
+		"more/users.yml": `
+servers:
+  - name: users
+    port: 4575
+    endpoints:
+      - url: /users
+        GET:
+          template: "[]"
+`,
+		"more/readme.txt": "not a config",
+	})
+	defer os.RemoveAll(dir)
+
+	serverCollection, err := Load(filepath.Join(dir, "config.yaml"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(serverCollection.Servers))
+	assert.Equal(t, "server_1", serverCollection.Servers[0].Name)
+	assert.Equal(t, "billing", serverCollection.Servers[1].Name)
+	assert.Equal(t, "users", serverCollection.Servers[2].Name)
+
+	body, err := serverCollection.Servers[0].Endpoints[0].GET.render(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "main response", string(body))
+
+	body, err = serverCollection.Servers[1].Endpoints[0].GET.render(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "[]", string(body))
+
+	assert.Nil(t, CheckConfig(filepath.Join(dir, "config.yaml")))
+}
+
+func TestLoadConfigDirectory(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"b.json": `{"servers": [{"name": "server_b", "port": 4574, "endpoints": []}]}`,
+		"a.yaml": `
+servers:
+  - name: server_a
+    port: 4573
+    endpoints: []
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	serverCollection, err := Load(dir)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(serverCollection.Servers))
+	assert.Equal(t, "server_a", serverCollection.Servers[0].Name)
+	assert.Equal(t, "server_b", serverCollection.Servers[1].Name)
+}
+
+func TestLoadConfigWithMissingInclude(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": "include: [teams/*.yaml]",
+	})
+	defer os.RemoveAll(dir)
+
+	_, err := Load(filepath.Join(dir, "config.yaml"))
+
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "teams/*.yaml matches no files")
+}
+
+func TestCheckConfigWithDuplicatedServers(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"a.yaml": `
+servers:
+  - name: server_1
+    port: 4573
+    endpoints: []
+  - name: server_2
+    port: 4574
+    endpoints: []
+`,
+		"b.yaml": `
+servers:
+  - name: server_1
+    port: 4574
+    endpoints: []
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	err := CheckConfig(dir)
+
+	assert.NotNil(t, err)
+	assert.Equal(
+		t,
+		"server name server_1 is used more than once\nport 4574 is used by servers server_2 and server_1\n",
+		err.Error(),
+	)
+}
+
+func TestCheckConfigWithServersOnDifferentAddresses(t *testing.T) {
+	collection := ServerCollection{Servers: []MockServer{
+		{Name: "server_1", Port: 4573, Bind: "127.0.0.1"},
+		{Name: "server_2", Port: 4573, Bind: "127.0.0.2"},
+	}}
+	assert.Nil(t, collection.checkDuplicates())
+
+	collection.Servers = append(
+		collection.Servers,
+		MockServer{Name: "server_3", Port: 4573, Bind: "127.0.0.2"},
+		MockServer{Name: "server_4", Port: 4573},
+		MockServer{Name: "server_5", Port: 4574, Bind: "0.0.0.0"},
+		MockServer{Name: "server_6", Port: 4574, Bind: "::1"},
+	)
+	err := collection.checkDuplicates()
+	assert.NotNil(t, err)
+	assert.Equal(
+		t,
+		"address 127.0.0.2:4573 is used by servers server_2 and server_3\n"+
+			"port 4573 is used by servers server_1 and server_4\n"+
+			"address [::1]:4574 is used by servers server_5 and server_6\n",
+		err.Error(),
+	)
+}
+
+func TestCheckConfigWithInvalidIncludedFile(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": "include: [wrong.yaml]",
+		"wrong.yaml":  "servers: [{name: server_1}]",
+	})
+	defer os.RemoveAll(dir)
+
+	err := CheckConfig(filepath.Join(dir, "config.yaml"))
+
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), filepath.Join(dir, "wrong.yaml")+":\n")
+}
+
+func TestSubstitute(t *testing.T) {
+	lookup := func(name string) (string, bool) {
+		values := map[string]string{"PORT": "5000", "EMPTY": ""}
+		value, ok := values[name]
+		return value, ok
+	}
+
+	result, err := substitute([]byte("port: ${PORT:-4573}\nhost: ${HOST:-localhost}\nempty: ${EMPTY:-default}\nraw: $${PORT}"), lookup)
+	assert.Nil(t, err)
+	assert.Equal(t, "port: 5000\nhost: localhost\nempty: default\nraw: ${PORT}", string(result))
+
+	_, err = substitute([]byte("port: ${PORT}\nhost: ${HOST}"), lookup)
+	assert.NotNil(t, err)
+	assert.Equal(t, "variable HOST is not set", err.Error())
+
+	// empty variables are set, defaults are fallbacks for them
+	result, err = substitute([]byte("empty: ${EMPTY}"), lookup)
+	assert.Nil(t, err)
+	assert.Equal(t, "empty: ", string(result))
+}
+
+func TestSubstituteSkipsComments(t *testing.T) {
+	lookup := func(name string) (string, bool) {
+		return map[string]string{"PORT": "5000"}[name], name == "PORT"
+	}
+
+	config := `# run with ${HOST}
+port: ${PORT} # or ${HOST}
+  # indented ${HOST}
+url: "/#${PORT}" # ${HOST}
+body: 'it''s # ${PORT}'
+template: it's ${PORT}#1 # ${HOST}
+`
+	result, err := substitute([]byte(config), lookup)
+	assert.Nil(t, err)
+	assert.Equal(t, `# run with ${HOST}
+port: 5000 # or ${HOST}
+  # indented ${HOST}
+url: "/#5000" # ${HOST}
+body: 'it''s # 5000'
+template: it's 5000#1 # ${HOST}
+`, string(result))
+}
+
+func TestLoadConfigWithParams(t *testing.T) {
+	os.Setenv("MIMICRO_TEST_PORT", "5000")
+	defer os.Unsetenv("MIMICRO_TEST_PORT")
+
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": `
+params:
+  host: localhost
+  version: 1
+servers:
+  - name: server_1
+    port: ${MIMICRO_TEST_PORT:-4573}
+    endpoints:
+      - url: /users/{id}
+        GET:
+          template: "{{.host}} {{.version}} {{.env}} {{.id}}"
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	configPath := filepath.Join(dir, "config.yaml")
+	assert.Nil(t, CheckConfigWithParams(configPath, map[string]string{"env": "ci"}))
+
+	serverCollection, err := LoadWithParams(configPath, map[string]string{"env": "ci", "host": "mocks.local"})
+	assert.Nil(t, err)
+
+	server := serverCollection.Servers[0]
+	assert.Equal(t, 5000, server.Port)
+	assert.Equal(t, Params{"host": "mocks.local", "version": "1", "env": "ci"}, serverCollection.Params)
+
+	w := httptest.NewRecorder()
+	server.createRouter(func(RequestLog) {}).ServeHTTP(w, httptest.NewRequest("GET", "/users/42", nil))
+	assert.Equal(t, "mocks.local 1 ci 42", w.Body.String())
+
+	serverCollection, err = LoadWithParams(configPath, map[string]string{"MIMICRO_TEST_PORT": "6000"})
+	assert.Nil(t, err)
+	assert.Equal(t, 6000, serverCollection.Servers[0].Port)
+}
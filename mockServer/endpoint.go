@@ -6,38 +6,75 @@ import (
 
 type httpHandler = func(w http.ResponseWriter, req *http.Request)
 
+var endpointMethods = [...]string{"GET", "POST", "PATCH", "PUT", "DELETE"}
+
 // Endpoint represents an URL, wich accepts one or several types of requests
 type Endpoint struct {
-	URL    string    `json:"url"`
-	GET    *Response `json:"GET"`
-	POST   *Response `json:"POST"`
-	PATCH  *Response `json:"PATCH"`
-	PUT    *Response `json:"PUT"`
-	DELETE *Response `json:"DELETE"`
+	URL      string           `json:"url"`
+	Regex    *URLRegexp       `json:"regex"`
+	Host     *HostPattern     `json:"host"`
+	Schemes  []string         `json:"schemes"`
+	Priority int              `json:"priority"`
+	GET      *Response        `json:"GET"`
+	POST     *Response        `json:"POST"`
+	PATCH    *Response        `json:"PATCH"`
+	PUT      *Response        `json:"PUT"`
+	DELETE   *Response        `json:"DELETE"`
+	GraphQL  *GraphQLEndpoint `json:"graphql"`
+}
+
+// getResponse returns a response configured for the method or nil
+func (endpoint Endpoint) getResponse(method string) *Response {
+	switch method {
+	case "GET":
+		return endpoint.GET
+	case "POST":
+		return endpoint.POST
+	case "PATCH":
+		return endpoint.PATCH
+	case "PUT":
+		return endpoint.PUT
+	case "DELETE":
+		return endpoint.DELETE
+	}
+
+	return nil
+}
+
+// Methods returns methods of requests, which the endpoint responds to
+func (endpoint Endpoint) Methods() []string {
+	if endpoint.GraphQL != nil {
+		return []string{"GET", "POST"}
+	}
+
+	var methods []string
+	for _, method := range endpointMethods {
+		if endpoint.getResponse(method) != nil {
+			methods = append(methods, method)
+		}
+	}
+	return methods
 }
 
 // GetHandler returns a function to register it as a http handler
 func (endpoint Endpoint) GetHandler(logWriter RequestLogWriter, serverName string) httpHandler {
+	if endpoint.GraphQL != nil {
+		return endpoint.GraphQL.getHandler(logWriter, serverName)
+	}
+
 	return func(w http.ResponseWriter, req *http.Request) {
-		var response *Response
-
-		if req.Method == "GET" && endpoint.GET != nil {
-			response = endpoint.GET
-		} else if req.Method == "POST" && endpoint.POST != nil {
-			response = endpoint.POST
-		} else if req.Method == "PATCH" && endpoint.PATCH != nil {
-			response = endpoint.PATCH
-		} else if req.Method == "PUT" && endpoint.PUT != nil {
-			response = endpoint.PUT
-		} else if req.Method == "DELETE" && endpoint.DELETE != nil {
-			response = endpoint.DELETE
-		}
+		response := endpoint.getResponse(req.Method)
+
+		requestLog := RequestLog{ServerName: serverName, URL: req.URL.String(), Method: req.Method}
 
 		if response != nil {
-			logWriter(serverName, req.URL.String(), req.Method, response.StatusCode)
+			requestLog.StatusCode = response.StatusCode
+			logRequest(req, logWriter, requestLog)
 			response.WriteResponse(w, req)
 		} else {
-			logWriter(serverName, req.URL.String(), req.Method, http.StatusNotFound)
+			requestLog.StatusCode = http.StatusNotFound
+			requestLog.Unmatched = true
+			logRequest(req, logWriter, requestLog)
 			http.NotFound(w, req)
 		}
 	}
@@ -11,15 +11,11 @@ import (
 )
 
 type responseLogMessage struct {
-	ServerName, URL, Method string
-	StatusCode              int
+	RequestLog
 }
 
-func (msg *responseLogMessage) writeResponseLog(serverName, URL, method string, statusCode int) {
-	msg.ServerName = serverName
-	msg.URL = URL
-	msg.Method = method
-	msg.StatusCode = statusCode
+func (msg *responseLogMessage) writeResponseLog(requestLog RequestLog) {
+	msg.RequestLog = requestLog
 }
 
 func TestHandleResponse(t *testing.T) {
@@ -0,0 +1,507 @@
+package mockServer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const letters = "abcdefghijklmnopqrstuvwxyz"
+
+// optionalDepth is a depth of nesting, after which optional properties and items aren't generated,
+// so recursive schemas produce finite documents
+const optionalDepth = 8
+
+// maxDepth is a depth of nesting, after which the generation fails. Only required recursive fields reach it
+const maxDepth = 32
+
+// bodyGenerator generates random JSON documents, which are valid against the schema
+type bodyGenerator struct {
+	schema    interface{}
+	seed      *int64
+	overrides map[string]*template.Template
+}
+
+// generation holds the state of a single generation of a document
+type generation struct {
+	generator *bodyGenerator
+	random    *rand.Rand
+	vars      map[string]string
+	err       error
+	// depth is a nesting level of the value being generated
+	depth int
+}
+
+func newBodyGenerator(schema interface{}, seed interface{}, overrides interface{}) (*bodyGenerator, error) {
+	generator := &bodyGenerator{schema: schema, overrides: make(map[string]*template.Template)}
+
+	if seed != nil {
+		value := int64(seed.(float64))
+		generator.seed = &value
+	}
+
+	if overrides != nil {
+		for fieldPath, value := range overrides.(map[string]interface{}) {
+			templateInstance := template.New(fieldPath)
+			if _, err := templateInstance.Parse(fmt.Sprint(value)); err != nil {
+				return nil, err
+			}
+			generator.overrides[fieldPath] = templateInstance
+		}
+	}
+
+	return generator, nil
+}
+
+// generate returns a new document. When the seed is set, the same URL path always gets the same document
+func (generator *bodyGenerator) generate(vars map[string]string, urlPath string) ([]byte, error) {
+	var source rand.Source
+	if generator.seed != nil {
+		hash := fnv.New64a()
+		hash.Write([]byte(urlPath))
+		source = rand.NewSource(*generator.seed ^ int64(hash.Sum64()))
+	} else {
+		source = rand.NewSource(time.Now().UnixNano())
+	}
+
+	state := &generation{generator: generator, random: rand.New(source), vars: vars}
+	value := state.value(generator.schema, "")
+	if state.err != nil {
+		return nil, state.err
+	}
+
+	return json.Marshal(value)
+}
+
+// resolve follows local references like #/definitions/user
+func (state *generation) resolve(schema map[string]interface{}) map[string]interface{} {
+	for i := 0; i < 32; i++ {
+		ref, ok := schema["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#") {
+			return schema
+		}
+
+		var current interface{} = state.generator.schema
+		for _, token := range strings.Split(strings.TrimPrefix(ref[1:], "/"), "/") {
+			if token == "" {
+				continue
+			}
+			token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+			container, _ := current.(map[string]interface{})
+			current = container[token]
+		}
+
+		resolved, ok := current.(map[string]interface{})
+		if !ok {
+			return map[string]interface{}{}
+		}
+		schema = resolved
+	}
+
+	return schema
+}
+
+func (state *generation) value(rawSchema interface{}, fieldPath string) interface{} {
+	if state.err != nil {
+		return nil
+	}
+
+	state.depth++
+	defer func() { state.depth-- }()
+	if state.depth > maxDepth {
+		name := "the body"
+		if fieldPath != "" {
+			name = "field " + fieldPath
+		}
+		state.err = fmt.Errorf("cannot generate %s: the schema is recursive or nested deeper than %d levels", name, maxDepth)
+		return nil
+	}
+
+	schema, _ := rawSchema.(map[string]interface{})
+	schema = state.resolve(schema)
+
+	if override, ok := state.override(fieldPath, schema); ok {
+		return override
+	}
+
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		schema = state.merge(schema, allOf)
+	}
+	for _, keyword := range []string{"oneOf", "anyOf"} {
+		if variants, ok := schema[keyword].([]interface{}); ok && len(variants) > 0 {
+			return state.value(variants[state.random.Intn(len(variants))], fieldPath)
+		}
+	}
+
+	if value, ok := schema["const"]; ok {
+		return value
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[state.random.Intn(len(enum))]
+	}
+
+	switch schemaType(schema) {
+	case "object":
+		return state.object(schema, fieldPath)
+	case "array":
+		return state.array(schema, fieldPath)
+	case "integer":
+		return state.integer(schema)
+	case "number":
+		return state.number(schema)
+	case "boolean":
+		return state.random.Intn(2) == 1
+	case "null":
+		return nil
+	}
+
+	return state.string(schema)
+}
+
+// override renders a template, configured for the field, if any
+func (state *generation) override(fieldPath string, schema map[string]interface{}) (interface{}, bool) {
+	templateInstance, ok := state.generator.overrides[fieldPath]
+	if !ok {
+		return nil, false
+	}
+
+	buffer := bytes.NewBufferString("")
+	if err := templateInstance.Execute(buffer, state.vars); err != nil {
+		state.err = err
+		return nil, true
+	}
+
+	// a value for a string field is taken as is, otherwise it's parsed as JSON if possible
+	if schemaType, ok := schema["type"].(string); ok && schemaType == "string" {
+		return buffer.String(), true
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(buffer.Bytes(), &value); err != nil {
+		return buffer.String(), true
+	}
+	return value, true
+}
+
+// merge combines subschemas of allOf into one schema
+func (state *generation) merge(schema map[string]interface{}, allOf []interface{}) map[string]interface{} {
+	merged := make(map[string]interface{})
+	properties := make(map[string]interface{})
+	var required []interface{}
+
+	for _, rawSubschema := range append([]interface{}{schema}, allOf...) {
+		subschema, _ := rawSubschema.(map[string]interface{})
+		subschema = state.resolve(subschema)
+
+		for key, value := range subschema {
+			switch key {
+			case "allOf", "$ref":
+			case "properties":
+				for name, property := range value.(map[string]interface{}) {
+					properties[name] = property
+				}
+			case "required":
+				required = append(required, value.([]interface{})...)
+			default:
+				merged[key] = value
+			}
+		}
+	}
+
+	if len(properties) > 0 {
+		merged["properties"] = properties
+	}
+	if len(required) > 0 {
+		merged["required"] = required
+	}
+	return merged
+}
+
+func schemaType(schema map[string]interface{}) string {
+	switch value := schema["type"].(type) {
+	case string:
+		return value
+	case []interface{}:
+		for _, item := range value {
+			if item != "null" {
+				return item.(string)
+			}
+		}
+		if len(value) > 0 {
+			return "null"
+		}
+	}
+
+	if _, ok := schema["properties"]; ok {
+		return "object"
+	}
+	if _, ok := schema["items"]; ok {
+		return "array"
+	}
+	return "string"
+}
+
+// hasOverrides checks if the field or any of its nested fields is overridden
+func (generator *bodyGenerator) hasOverrides(fieldPath string) bool {
+	for overridePath := range generator.overrides {
+		if overridePath == fieldPath || strings.HasPrefix(overridePath, fieldPath+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(fieldPath, name string) string {
+	if fieldPath == "" {
+		return name
+	}
+	return fieldPath + "." + name
+}
+
+func (state *generation) object(schema map[string]interface{}, fieldPath string) interface{} {
+	result := make(map[string]interface{})
+
+	required := make(map[string]bool)
+	if list, ok := schema["required"].([]interface{}); ok {
+		for _, name := range list {
+			required[name.(string)] = true
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	// keeps the document the same for the same seed
+	sort.Strings(names)
+
+	for _, name := range names {
+		propertyPath := joinPath(fieldPath, name)
+
+		optional := state.depth < optionalDepth && state.random.Intn(2) == 1
+		if required[name] || state.generator.hasOverrides(propertyPath) || optional {
+			result[name] = state.value(properties[name], propertyPath)
+		}
+	}
+
+	return result
+}
+
+func (state *generation) array(schema map[string]interface{}, fieldPath string) interface{} {
+	minItems := intKeyword(schema, "minItems", 1)
+	maxItems := intKeyword(schema, "maxItems", minItems+2)
+	if maxItems < minItems {
+		minItems = maxItems
+	}
+
+	length := minItems + state.random.Intn(maxItems-minItems+1)
+	if state.depth >= optionalDepth {
+		length = intKeyword(schema, "minItems", 0)
+	}
+	result := make([]interface{}, length)
+
+	for i := range result {
+		itemPath := joinPath(fieldPath, strconv.Itoa(i))
+		if !state.generator.hasOverrides(itemPath) {
+			itemPath = joinPath(fieldPath, "*")
+		}
+
+		switch items := schema["items"].(type) {
+		case []interface{}:
+			if i < len(items) {
+				result[i] = state.value(items[i], itemPath)
+			} else {
+				result[i] = state.value(schema["additionalItems"], itemPath)
+			}
+		default:
+			result[i] = state.value(items, itemPath)
+		}
+	}
+
+	return result
+}
+
+func intKeyword(schema map[string]interface{}, keyword string, defaultValue int) int {
+	if value, ok := schema[keyword].(float64); ok {
+		return int(value)
+	}
+	return defaultValue
+}
+
+// bounds returns minimum and maximum of a number with their exclusiveness
+// in both draft 4 (boolean) and newer (numeric) forms
+func bounds(schema map[string]interface{}) (min, max float64, exclusiveMin, exclusiveMax bool) {
+	min, hasMin := schema["minimum"].(float64)
+	max, hasMax := schema["maximum"].(float64)
+
+	switch value := schema["exclusiveMinimum"].(type) {
+	case bool:
+		exclusiveMin = value
+	case float64:
+		min, hasMin, exclusiveMin = value, true, true
+	}
+	switch value := schema["exclusiveMaximum"].(type) {
+	case bool:
+		exclusiveMax = value
+	case float64:
+		max, hasMax, exclusiveMax = value, true, true
+	}
+
+	switch {
+	case !hasMin && !hasMax:
+		min, max = 0, 1000
+	case !hasMin:
+		min = max - 1000
+	case !hasMax:
+		max = min + 1000
+	}
+
+	return min, max, exclusiveMin, exclusiveMax
+}
+
+// clampInt64 converts the float to the nearest int64
+func clampInt64(value float64) int64 {
+	switch {
+	case value >= math.MaxInt64:
+		return math.MaxInt64
+	case value <= math.MinInt64:
+		return math.MinInt64
+	}
+	return int64(value)
+}
+
+func (state *generation) integer(schema map[string]interface{}) interface{} {
+	min, max, exclusiveMin, exclusiveMax := bounds(schema)
+
+	low := clampInt64(math.Ceil(min))
+	if exclusiveMin && float64(low) == min && low < math.MaxInt64 {
+		low++
+	}
+	high := clampInt64(math.Floor(max))
+	if exclusiveMax && float64(high) == max && high > math.MinInt64 {
+		high--
+	}
+
+	step := uint64(1)
+	if multipleOf, ok := schema["multipleOf"].(float64); ok && multipleOf >= 1 {
+		// a step, which can't be represented, leaves a single multiple in the range at most
+		if multipleOf >= math.MaxInt64 {
+			if low <= 0 && high >= 0 {
+				return int64(0)
+			}
+			return low
+		}
+		step = uint64(multipleOf)
+		low = clampInt64(math.Ceil(float64(low)/float64(step)) * float64(step))
+	}
+
+	if high < low {
+		return low
+	}
+
+	// the span is computed in uint64, so the full range of int64 doesn't overflow
+	count := (uint64(high)-uint64(low))/step + 1
+	var offset uint64
+	switch {
+	case count == 0:
+		offset = state.random.Uint64()
+	case count <= math.MaxInt64:
+		offset = uint64(state.random.Int63n(int64(count)))
+	default:
+		offset = state.random.Uint64() % count
+	}
+	return int64(uint64(low) + offset*step)
+}
+
+func (state *generation) number(schema map[string]interface{}) interface{} {
+	min, max, exclusiveMin, exclusiveMax := bounds(schema)
+
+	if multipleOf, ok := schema["multipleOf"].(float64); ok && multipleOf > 0 {
+		low := math.Ceil(min / multipleOf)
+		if exclusiveMin && low*multipleOf == min {
+			low++
+		}
+		high := math.Floor(max / multipleOf)
+		if exclusiveMax && high*multipleOf == max {
+			high--
+		}
+		if high < low {
+			return low * multipleOf
+		}
+		// a number of multiples, which doesn't fit into int64, is chosen as a float
+		if high-low < math.MaxInt64 {
+			return (low + float64(state.random.Int63n(int64(high-low)+1))) * multipleOf
+		}
+		return (low + math.Floor(state.random.Float64()*(high-low))) * multipleOf
+	}
+
+	value := min + state.random.Float64()*(max-min)
+	value = math.Floor(value*100+0.5) / 100
+	if value < min || (exclusiveMin && value == min) || value > max || (exclusiveMax && value == max) {
+		return (min + max) / 2
+	}
+	return value
+}
+
+func (state *generation) letters(length int) string {
+	result := make([]byte, length)
+	for i := range result {
+		result[i] = letters[state.random.Intn(len(letters))]
+	}
+	return string(result)
+}
+
+func (state *generation) string(schema map[string]interface{}) interface{} {
+	random := state.random
+
+	switch schema["format"] {
+	case "date-time":
+		return state.time().Format(time.RFC3339)
+	case "date":
+		return state.time().Format("2006-01-02")
+	case "time":
+		return state.time().Format("15:04:05")
+	case "email":
+		return state.letters(8) + "@example.com"
+	case "hostname":
+		return state.letters(8) + ".example.com"
+	case "uri", "url":
+		return "https://example.com/" + state.letters(8)
+	case "ipv4":
+		return fmt.Sprintf("%d.%d.%d.%d", random.Intn(256), random.Intn(256), random.Intn(256), random.Intn(256))
+	case "ipv6":
+		groups := make([]string, 8)
+		for i := range groups {
+			groups[i] = strconv.FormatInt(int64(random.Intn(0x10000)), 16)
+		}
+		return strings.Join(groups, ":")
+	case "uuid":
+		return fmt.Sprintf(
+			"%08x-%04x-4%03x-%04x-%012x",
+			random.Uint32(), random.Intn(0x10000), random.Intn(0x1000), 0x8000|random.Intn(0x4000), random.Int63n(1<<48),
+		)
+	}
+
+	minLength := intKeyword(schema, "minLength", 5)
+	maxLength := intKeyword(schema, "maxLength", minLength+5)
+	if maxLength < minLength {
+		minLength = maxLength
+	}
+
+	return state.letters(minLength + random.Intn(maxLength-minLength+1))
+}
+
+func (state *generation) time() time.Time {
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	end := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	return time.Unix(start+state.random.Int63n(end-start), 0).UTC()
+}
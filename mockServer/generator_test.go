@@ -0,0 +1,250 @@
+package mockServer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+const userSchema = `
+type: object
+required: [id, name, email, roles, created_at, address]
+definitions:
+  role:
+    type: string
+    enum: [admin, editor, viewer]
+properties:
+  id:
+    type: integer
+    minimum: 1
+    maximum: 100
+    multipleOf: 5
+  name:
+    type: string
+    minLength: 3
+    maxLength: 8
+  email:
+    type: string
+    format: email
+  created_at:
+    type: string
+    format: date-time
+  uuid:
+    type: string
+    format: uuid
+  rating:
+    type: number
+    minimum: 0
+    exclusiveMaximum: 5
+  roles:
+    type: array
+    minItems: 1
+    maxItems: 3
+    items:
+      $ref: "#/definitions/role"
+  address:
+    allOf:
+      - type: object
+        required: [city]
+        properties:
+          city:
+            type: string
+      - required: [zip]
+        properties:
+          zip:
+            type: string
+            minLength: 5
+            maxLength: 5
+  nickname:
+    type: [string, "null"]
+`
+
+func parseSchema(t *testing.T, schemaString string) interface{} {
+	var schema interface{}
+	err := yaml.Unmarshal([]byte(schemaString), &schema)
+	assert.Nil(t, err)
+	return schema
+}
+
+func TestGeneratedBodiesAreValid(t *testing.T) {
+	schema := parseSchema(t, userSchema)
+	compiledSchema, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(schema))
+	assert.Nil(t, err)
+
+	generator, err := newBodyGenerator(schema, nil, nil)
+	assert.Nil(t, err)
+
+	for i := 0; i < 100; i++ {
+		body, err := generator.generate(nil, "/users")
+		assert.Nil(t, err)
+
+		assert.Nil(t, validateBody(compiledSchema, body, "body"), string(body))
+	}
+}
+
+func TestGenerationWithSeed(t *testing.T) {
+	schema := parseSchema(t, userSchema)
+
+	generator, err := newBodyGenerator(schema, float64(42), nil)
+	assert.Nil(t, err)
+
+	first, _ := generator.generate(nil, "/users/1")
+	second, _ := generator.generate(nil, "/users/1")
+	another, _ := generator.generate(nil, "/users/2")
+
+	assert.Equal(t, string(first), string(second))
+	assert.NotEqual(t, string(first), string(another))
+}
+
+func TestGenerationWithOverrides(t *testing.T) {
+	schema := parseSchema(t, userSchema)
+	overrides := map[string]interface{}{
+		"id":           "{{.id}}",
+		"name":         "user {{.id}}",
+		"roles.*":      "admin",
+		"address.city": "Berlin",
+		"nickname":     "null",
+	}
+
+	generator, err := newBodyGenerator(schema, nil, overrides)
+	assert.Nil(t, err)
+
+	body, err := generator.generate(map[string]string{"id": "15"}, "/users/15")
+	assert.Nil(t, err)
+
+	var user map[string]interface{}
+	json.Unmarshal(body, &user)
+
+	assert.Equal(t, float64(15), user["id"])
+	assert.Equal(t, "user 15", user["name"])
+	assert.Equal(t, "Berlin", user["address"].(map[string]interface{})["city"])
+	assert.Nil(t, user["nickname"])
+	for _, role := range user["roles"].([]interface{}) {
+		assert.Equal(t, "admin", role)
+	}
+}
+
+func TestWriteGeneratedResponse(t *testing.T) {
+	config := `
+schema:
+  type: object
+  required: [id]
+  properties:
+    id:
+      type: integer
+overrides:
+  id: "{{.var}}"
+status_code: 201
+`
+	response := createResponseFromConfig(config)
+
+	assert.Nil(t, response.template)
+	assert.Nil(t, response.file)
+	assert.NotNil(t, response.generator)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/simple_url/{var}", response.WriteResponse)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/simple_url/7", nil)
+
+	router.ServeHTTP(w, req)
+
+	resp := w.Result()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, `{"id":7}`, string(body))
+}
+
+func TestGenerationOfRecursiveSchema(t *testing.T) {
+	schema := parseSchema(t, `
+type: object
+required: [name]
+properties:
+  name:
+    type: string
+  parent:
+    $ref: "#"
+  children:
+    type: array
+    items:
+      $ref: "#/definitions/node"
+definitions:
+  node:
+    $ref: "#"
+`)
+	compiledSchema, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(schema))
+	assert.Nil(t, err)
+
+	generator, err := newBodyGenerator(schema, nil, nil)
+	assert.Nil(t, err)
+
+	for i := 0; i < 20; i++ {
+		body, err := generator.generate(nil, "/nodes")
+		assert.Nil(t, err)
+		assert.Nil(t, validateBody(compiledSchema, body, "body"), string(body))
+	}
+
+	schema = parseSchema(t, `
+type: object
+required: [parent]
+properties:
+  parent:
+    $ref: "#"
+`)
+	generator, err = newBodyGenerator(schema, nil, nil)
+	assert.Nil(t, err)
+
+	_, err = generator.generate(nil, "/nodes")
+	assert.EqualError(
+		t, err,
+		"cannot generate field "+strings.Repeat("parent.", 31)+"parent: the schema is recursive or nested deeper than 32 levels",
+	)
+}
+
+func TestGenerationOfNumbersInHugeRanges(t *testing.T) {
+	for _, schemaString := range []string{
+		`{"type": "integer", "minimum": -9e18, "maximum": 9e18}`,
+		`{"type": "integer", "format": "int64", "minimum": -9223372036854775808, "maximum": 9223372036854775807}`,
+		`{"type": "integer", "minimum": 1e30}`,
+		`{"type": "integer", "minimum": -1e20, "maximum": 1e20, "multipleOf": 1e19}`,
+		`{"type": "integer", "minimum": 1, "maximum": 100, "multipleOf": 1e20}`,
+		`{"type": "number", "minimum": 0, "maximum": 1e20, "multipleOf": 1}`,
+		`{"type": "number", "minimum": -1e300, "maximum": 1e300, "multipleOf": 0.5}`,
+	} {
+		schema := parseSchema(t, schemaString)
+		generator, err := newBodyGenerator(schema, nil, nil)
+		assert.Nil(t, err)
+
+		for i := 0; i < 20; i++ {
+			var body []byte
+			assert.NotPanics(t, func() { body, err = generator.generate(nil, "/numbers") }, schemaString)
+			assert.Nil(t, err, schemaString)
+
+			var value float64
+			assert.Nil(t, json.Unmarshal(body, &value), schemaString)
+		}
+	}
+
+	// values stay multiples within bounds
+	schema := parseSchema(t, `{"type": "integer", "minimum": -9e18, "maximum": 9e18, "multipleOf": 3}`)
+	generator, _ := newBodyGenerator(schema, nil, nil)
+	for i := 0; i < 20; i++ {
+		body, err := generator.generate(nil, "/numbers")
+		assert.Nil(t, err)
+		var value int64
+		assert.Nil(t, json.Unmarshal(body, &value))
+		assert.Equal(t, int64(0), value%3, string(body))
+		assert.True(t, value >= -9e18 && value <= 9e18, string(body))
+	}
+}
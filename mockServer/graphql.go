@@ -0,0 +1,758 @@
+package mockServer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// GraphQLEndpoint mocks a GraphQL API, described by the SDL schema.
+// Responses are shaped to the selection sets of queries and filled by generated values
+// unless a resolver template is configured for a field
+type GraphQLEndpoint struct {
+	schema    *graphQLSchema
+	resolvers map[string]*template.Template
+	seed      *int64
+}
+
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+type graphQLResponse struct {
+	Data   interface{}     `json:"data,omitempty"`
+	Errors []*graphQLError `json:"errors,omitempty"`
+}
+
+// UnmarshalJSON used by json lib. Loads the SDL schema and parses resolvers
+func (endpoint *GraphQLEndpoint) UnmarshalJSON(data []byte) error {
+	var config struct {
+		Schema    string            `json:"schema"`
+		Seed      *int64            `json:"seed"`
+		Resolvers map[string]string `json:"resolvers"`
+	}
+	err := json.Unmarshal(data, &config)
+	if err != nil {
+		return err
+	}
+
+	// loaders pass the content of the schema file instead of its path
+	source := config.Schema
+	if strings.HasPrefix(source, "file://") {
+		data, err := readReference(source)
+		if err != nil {
+			return err
+		}
+		source = string(data)
+	}
+
+	endpoint.schema, err = parseGraphQLSchema(source)
+	if err != nil {
+		return fmt.Errorf("Cannot load GraphQL schema: %s", err)
+	}
+
+	endpoint.seed = config.Seed
+	endpoint.resolvers = make(map[string]*template.Template)
+
+	for fieldName, templateString := range config.Resolvers {
+		parts := strings.SplitN(fieldName, ".", 2)
+		definedType, ok := endpoint.schema.types[parts[0]]
+		if !ok || len(parts) != 2 || definedType.fields[parts[1]] == nil {
+			return fmt.Errorf("Cannot set a resolver for %s: field is not defined in the schema", fieldName)
+		}
+
+		templateInstance := template.New(fieldName)
+		if _, err = templateInstance.Parse(templateString); err != nil {
+			return err
+		}
+		endpoint.resolvers[fieldName] = templateInstance
+	}
+
+	return nil
+}
+
+func writeGraphQLResponse(w http.ResponseWriter, statusCode int, response graphQLResponse) {
+	payload, err := json.Marshal(response)
+	if err != nil {
+		statusCode = http.StatusInternalServerError
+		payload, _ = json.Marshal(graphQLResponse{Errors: []*graphQLError{{Message: err.Error()}}})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(payload)
+}
+
+func readGraphQLRequest(req *http.Request) (*graphQLRequest, error) {
+	request := new(graphQLRequest)
+
+	if req.Method == "GET" {
+		query := req.URL.Query()
+		request.Query = query.Get("query")
+		request.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+				return nil, fmt.Errorf("Variables are invalid JSON: %s", err)
+			}
+		}
+		return request, nil
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if mediaType == "application/graphql" {
+		request.Query = string(body)
+		return request, nil
+	}
+
+	if err = json.Unmarshal(body, request); err != nil {
+		return nil, fmt.Errorf("POST body sent invalid JSON: %s", err)
+	}
+	return request, nil
+}
+
+func (endpoint *GraphQLEndpoint) getHandler(logWriter RequestLogWriter, serverName string) httpHandler {
+	return func(w http.ResponseWriter, req *http.Request) {
+		requestLog := RequestLog{ServerName: serverName, URL: req.URL.String(), Method: req.Method}
+
+		if req.Method != "GET" && req.Method != "POST" {
+			requestLog.StatusCode = http.StatusMethodNotAllowed
+			logRequest(req, logWriter, requestLog)
+			writeGraphQLResponse(w, http.StatusMethodNotAllowed, graphQLResponse{
+				Errors: []*graphQLError{{Message: "GraphQL only supports GET and POST requests."}},
+			})
+			return
+		}
+
+		request, err := readGraphQLRequest(req)
+		if err != nil {
+			requestLog.StatusCode = http.StatusBadRequest
+			logRequest(req, logWriter, requestLog)
+			writeGraphQLResponse(w, http.StatusBadRequest, graphQLResponse{Errors: []*graphQLError{{Message: err.Error()}}})
+			return
+		}
+
+		operation, response := endpoint.execute(request, requestVars(req))
+
+		requestLog.StatusCode = http.StatusOK
+		if operation != nil {
+			requestLog.Operation = operation.name
+
+			if operation.kind != "query" && req.Method == "GET" {
+				requestLog.StatusCode = http.StatusMethodNotAllowed
+				response = graphQLResponse{Errors: []*graphQLError{{
+					Message: fmt.Sprintf("Can only perform a %s operation from a POST request.", operation.kind),
+				}}}
+			}
+		}
+
+		logRequest(req, logWriter, requestLog)
+		writeGraphQLResponse(w, requestLog.StatusCode, response)
+	}
+}
+
+// graphQLExecution holds the state of a single request execution
+type graphQLExecution struct {
+	endpoint  *GraphQLEndpoint
+	document  *graphQLDocument
+	operation *graphQLOperation
+	variables map[string]interface{}
+	vars      map[string]string
+	random    *generation
+	errors    []*graphQLError
+}
+
+func (execution *graphQLExecution) addError(line, column int, format string, args ...interface{}) {
+	execution.errors = append(execution.errors, newGraphQLError(line, column, format, args...))
+}
+
+// execute parses, validates and executes the query. The returned operation is nil if it cannot be determined
+func (endpoint *GraphQLEndpoint) execute(request *graphQLRequest, vars map[string]string) (*graphQLOperation, graphQLResponse) {
+	if strings.TrimSpace(request.Query) == "" {
+		return nil, graphQLResponse{Errors: []*graphQLError{{Message: "Must provide query string."}}}
+	}
+
+	document, err := parseGraphQLQuery(request.Query)
+	if err != nil {
+		queryError, ok := err.(*graphQLError)
+		if !ok {
+			queryError = &graphQLError{Message: err.Error()}
+		}
+		return nil, graphQLResponse{Errors: []*graphQLError{queryError}}
+	}
+
+	operation, err := document.findOperation(request.OperationName)
+	if err != nil {
+		return nil, graphQLResponse{Errors: []*graphQLError{{Message: err.Error()}}}
+	}
+
+	var source rand.Source
+	if endpoint.seed != nil {
+		source = rand.NewSource(*endpoint.seed)
+	} else {
+		source = rand.NewSource(time.Now().UnixNano())
+	}
+
+	execution := &graphQLExecution{
+		endpoint:  endpoint,
+		document:  document,
+		operation: operation,
+		vars:      vars,
+		random:    &generation{generator: &bodyGenerator{}, random: rand.New(source)},
+	}
+
+	rootType := execution.rootType(operation)
+	if rootType == nil {
+		return operation, graphQLResponse{Errors: execution.errors}
+	}
+
+	execution.coerceVariables(request.Variables)
+	execution.validateSelections(rootType, operation.selections, make(map[string]bool))
+	execution.checkConflicts(rootType, operation.selections)
+	if len(execution.errors) > 0 {
+		return operation, graphQLResponse{Errors: execution.errors}
+	}
+
+	data := execution.selectionSet(rootType, operation.selections, nil, nil)
+	return operation, graphQLResponse{Data: data, Errors: execution.errors}
+}
+
+func (document *graphQLDocument) findOperation(operationName string) (*graphQLOperation, error) {
+	if operationName == "" {
+		if len(document.operations) > 1 {
+			return nil, errors.New("Must provide operation name if query contains multiple operations.")
+		}
+		return document.operations[0], nil
+	}
+
+	for _, operation := range document.operations {
+		if operation.name == operationName {
+			return operation, nil
+		}
+	}
+	return nil, fmt.Errorf("Unknown operation named %q.", operationName)
+}
+
+func (execution *graphQLExecution) rootType(operation *graphQLOperation) *graphQLType {
+	schema := execution.endpoint.schema
+
+	switch operation.kind {
+	case "query":
+		return schema.types[schema.queryType]
+	case "mutation":
+		if rootType, ok := schema.types[schema.mutationType]; ok {
+			return rootType
+		}
+		execution.addError(operation.line, operation.column, "Schema is not configured for mutations.")
+	default:
+		execution.addError(operation.line, operation.column, "Schema is not configured for %ss.", operation.kind)
+	}
+
+	return nil
+}
+
+func (execution *graphQLExecution) coerceVariables(passed map[string]interface{}) {
+	execution.variables = make(map[string]interface{})
+
+	for _, variable := range execution.operation.variables {
+		if value, ok := passed[variable.name]; ok {
+			execution.variables[variable.name] = value
+		} else if variable.value != nil {
+			execution.variables[variable.name] = variable.value.resolve(nil)
+		} else if variable.typeRef.nonNull {
+			execution.addError(
+				execution.operation.line, execution.operation.column,
+				"Variable \"$%s\" of required type \"%s\" was not provided.", variable.name, variable.typeRef,
+			)
+		}
+	}
+}
+
+func (execution *graphQLExecution) checkVariables(selection *graphQLSelection, value graphQLValue) {
+	switch value.kind {
+	case valueVariable:
+		for _, variable := range execution.operation.variables {
+			if variable.name == value.raw {
+				return
+			}
+		}
+		execution.addError(selection.line, selection.column, "Variable \"$%s\" is not defined.", value.raw)
+	case valueList:
+		for _, item := range value.list {
+			execution.checkVariables(selection, item)
+		}
+	case valueObject:
+		for _, field := range value.fields {
+			execution.checkVariables(selection, field)
+		}
+	}
+}
+
+// validateSelections checks that all the selected fields exist and have proper subselections
+func (execution *graphQLExecution) validateSelections(parentType *graphQLType, selections []*graphQLSelection, visited map[string]bool) {
+	schema := execution.endpoint.schema
+
+	for _, selection := range selections {
+		for _, directive := range selection.directives {
+			if _, ok := directive.arguments["if"]; !ok && (directive.name == "skip" || directive.name == "include") {
+				execution.addError(
+					selection.line, selection.column,
+					"Directive \"@%s\" argument \"if\" of type \"Boolean!\" is required, but it was not provided.", directive.name,
+				)
+			}
+			for _, argument := range directive.arguments {
+				execution.checkVariables(selection, argument)
+			}
+		}
+
+		switch {
+		case selection.fragmentName != "":
+			fragment, ok := execution.document.fragments[selection.fragmentName]
+			if !ok {
+				execution.addError(selection.line, selection.column, "Unknown fragment %q.", selection.fragmentName)
+				continue
+			}
+			if visited[fragment.name] {
+				execution.addError(selection.line, selection.column, "Cannot spread fragment %q within itself.", fragment.name)
+				continue
+			}
+
+			fragmentType, ok := schema.types[fragment.typeCondition]
+			if !ok {
+				execution.addError(fragment.line, fragment.column, "Unknown type %q.", fragment.typeCondition)
+				continue
+			}
+			if !execution.canApply(parentType, fragmentType) {
+				execution.addError(
+					selection.line, selection.column,
+					"Fragment %q cannot be spread here as objects of type %q can never be of type %q.",
+					fragment.name, parentType.name, fragmentType.name,
+				)
+				continue
+			}
+
+			visited[fragment.name] = true
+			execution.validateSelections(fragmentType, fragment.selections, visited)
+			delete(visited, fragment.name)
+		case selection.inline:
+			fragmentType := parentType
+			if selection.typeCondition != "" {
+				var ok bool
+				if fragmentType, ok = schema.types[selection.typeCondition]; !ok {
+					execution.addError(selection.line, selection.column, "Unknown type %q.", selection.typeCondition)
+					continue
+				}
+				if !execution.canApply(parentType, fragmentType) {
+					execution.addError(
+						selection.line, selection.column,
+						"Fragment cannot be spread here as objects of type %q can never be of type %q.",
+						parentType.name, fragmentType.name,
+					)
+					continue
+				}
+			}
+			execution.validateSelections(fragmentType, selection.selections, visited)
+		case selection.name == "__typename":
+			if len(selection.selections) > 0 {
+				execution.addError(
+					selection.line, selection.column,
+					"Field \"__typename\" must not have a selection since type \"String!\" has no subfields.",
+				)
+			}
+		default:
+			execution.validateField(parentType, selection, visited)
+		}
+	}
+}
+
+func (execution *graphQLExecution) validateField(parentType *graphQLType, selection *graphQLSelection, visited map[string]bool) {
+	field, ok := parentType.fields[selection.name]
+	if !ok || parentType.kind == "INPUT_OBJECT" {
+		execution.addError(
+			selection.line, selection.column, "Cannot query field %q on type %q.", selection.name, parentType.name,
+		)
+		return
+	}
+
+	for name, value := range selection.arguments {
+		if _, ok := field.arguments[name]; !ok {
+			execution.addError(
+				selection.line, selection.column,
+				"Unknown argument %q on field \"%s.%s\".", name, parentType.name, field.name,
+			)
+		}
+		execution.checkVariables(selection, value)
+	}
+	for name, typeRef := range field.arguments {
+		if _, ok := selection.arguments[name]; !ok && typeRef.nonNull {
+			execution.addError(
+				selection.line, selection.column,
+				"Field %q argument %q of type \"%s\" is required, but it was not provided.", field.name, name, typeRef,
+			)
+		}
+	}
+
+	fieldType := execution.endpoint.schema.types[field.typeRef.namedType()]
+	isLeaf := fieldType.kind == "SCALAR" || fieldType.kind == "ENUM"
+
+	if isLeaf && len(selection.selections) > 0 {
+		execution.addError(
+			selection.line, selection.column,
+			"Field %q must not have a selection since type \"%s\" has no subfields.", selection.name, field.typeRef,
+		)
+	} else if !isLeaf && len(selection.selections) == 0 {
+		execution.addError(
+			selection.line, selection.column,
+			"Field %q of type \"%s\" must have a selection of subfields. Did you mean \"%s { ... }\"?",
+			selection.name, field.typeRef, selection.name,
+		)
+	} else if !isLeaf {
+		execution.validateSelections(fieldType, selection.selections, visited)
+		execution.checkConflicts(fieldType, selection.selections)
+	}
+}
+
+// objectTypes returns names of object types, which values of the type can have
+func objectTypes(definedType *graphQLType) []string {
+	if definedType.kind == "INTERFACE" || definedType.kind == "UNION" {
+		return definedType.possibleTypes
+	}
+	return []string{definedType.name}
+}
+
+// canApply checks if a fragment of the type can be spread into a selection of the parent type
+func (execution *graphQLExecution) canApply(parentType *graphQLType, fragmentType *graphQLType) bool {
+	for _, parentName := range objectTypes(parentType) {
+		for _, fragmentName := range objectTypes(fragmentType) {
+			if parentName == fragmentName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// selectedField is a field selected directly or through fragments with the type it's selected on
+type selectedField struct {
+	parentType *graphQLType
+	selection  *graphQLSelection
+}
+
+// collectSelectedFields groups fields of the selection set and its fragments by their response keys
+func (execution *graphQLExecution) collectSelectedFields(parentType *graphQLType, selections []*graphQLSelection, fields *orderedMap, visited map[string]bool) {
+	schema := execution.endpoint.schema
+
+	for _, selection := range selections {
+		switch {
+		case selection.fragmentName != "":
+			fragment, ok := execution.document.fragments[selection.fragmentName]
+			if !ok || visited[fragment.name] {
+				continue
+			}
+			if fragmentType, ok := schema.types[fragment.typeCondition]; ok {
+				visited[fragment.name] = true
+				execution.collectSelectedFields(fragmentType, fragment.selections, fields, visited)
+			}
+		case selection.inline:
+			fragmentType, ok := parentType, true
+			if selection.typeCondition != "" {
+				fragmentType, ok = schema.types[selection.typeCondition]
+			}
+			if ok {
+				execution.collectSelectedFields(fragmentType, selection.selections, fields, visited)
+			}
+		default:
+			grouped, _ := fields.values[selection.responseKey()].([]selectedField)
+			fields.set(selection.responseKey(), append(grouped, selectedField{parentType: parentType, selection: selection}))
+		}
+	}
+}
+
+// checkConflicts checks that fields with the same response key select the same field with the same arguments.
+// Fields selected on different object types never conflict, because only one of them is executed
+func (execution *graphQLExecution) checkConflicts(parentType *graphQLType, selections []*graphQLSelection) {
+	fields := &orderedMap{values: make(map[string]interface{})}
+	execution.collectSelectedFields(parentType, selections, fields, make(map[string]bool))
+
+	for _, key := range fields.keys {
+		grouped := fields.values[key].([]selectedField)
+		if message, first, second := conflict(grouped); message != "" {
+			queryError := newGraphQLError(
+				first.line, first.column,
+				"Fields %q conflict because %s. Use different aliases on the fields to fetch both if this was intentional.", key, message,
+			)
+			queryError.Locations = append(queryError.Locations, graphQLLocation{Line: second.line, Column: second.column})
+			execution.errors = append(execution.errors, queryError)
+		}
+	}
+}
+
+// conflict returns the reason, why two of fields with the same response key can't be merged, and these fields.
+// The reason is empty, when there are no conflicts
+func conflict(grouped []selectedField) (string, *graphQLSelection, *graphQLSelection) {
+	for i, first := range grouped {
+		for _, second := range grouped[i+1:] {
+			if first.parentType != second.parentType && first.parentType.kind == "OBJECT" && second.parentType.kind == "OBJECT" {
+				continue
+			}
+			if first.selection.name != second.selection.name {
+				message := fmt.Sprintf("%q and %q are different fields", first.selection.name, second.selection.name)
+				return message, first.selection, second.selection
+			}
+			if !reflect.DeepEqual(first.selection.arguments, second.selection.arguments) {
+				return "they have differing arguments", first.selection, second.selection
+			}
+		}
+	}
+	return "", nil, nil
+}
+
+// orderedMap keeps fields of the result in the order of the selection set
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (result *orderedMap) set(key string, value interface{}) {
+	if _, ok := result.values[key]; !ok {
+		result.keys = append(result.keys, key)
+	}
+	result.values[key] = value
+}
+
+func (result *orderedMap) MarshalJSON() ([]byte, error) {
+	buffer := bytes.NewBufferString("{")
+	for i, key := range result.keys {
+		if i > 0 {
+			buffer.WriteString(",")
+		}
+
+		keyData, _ := json.Marshal(key)
+		valueData, err := json.Marshal(result.values[key])
+		if err != nil {
+			return nil, err
+		}
+
+		buffer.Write(keyData)
+		buffer.WriteString(":")
+		buffer.Write(valueData)
+	}
+	buffer.WriteString("}")
+	return buffer.Bytes(), nil
+}
+
+func (execution *graphQLExecution) isIncluded(selection *graphQLSelection) bool {
+	for _, directive := range selection.directives {
+		condition, _ := directive.arguments["if"].resolve(execution.variables).(bool)
+		if directive.name == "skip" && condition {
+			return false
+		}
+		if directive.name == "include" && !condition {
+			return false
+		}
+	}
+	return true
+}
+
+// doesFragmentApply checks if the type condition of a fragment matches the object type
+func (execution *graphQLExecution) doesFragmentApply(objectType *graphQLType, typeCondition string) bool {
+	if typeCondition == "" || typeCondition == objectType.name {
+		return true
+	}
+
+	conditionType := execution.endpoint.schema.types[typeCondition]
+	for _, possibleType := range conditionType.possibleTypes {
+		if possibleType == objectType.name {
+			return true
+		}
+	}
+	return false
+}
+
+// collectFields flattens fragments and groups selections by their response keys
+func (execution *graphQLExecution) collectFields(objectType *graphQLType, selections []*graphQLSelection, fields *orderedMap) {
+	for _, selection := range selections {
+		if !execution.isIncluded(selection) {
+			continue
+		}
+
+		switch {
+		case selection.fragmentName != "":
+			fragment := execution.document.fragments[selection.fragmentName]
+			if execution.doesFragmentApply(objectType, fragment.typeCondition) {
+				execution.collectFields(objectType, fragment.selections, fields)
+			}
+		case selection.inline:
+			if execution.doesFragmentApply(objectType, selection.typeCondition) {
+				execution.collectFields(objectType, selection.selections, fields)
+			}
+		default:
+			grouped, _ := fields.values[selection.responseKey()].([]*graphQLSelection)
+			fields.set(selection.responseKey(), append(grouped, selection))
+		}
+	}
+}
+
+// appendPath returns a new path to the field, so paths of sibling fields don't share memory
+func appendPath(path []interface{}, key interface{}) []interface{} {
+	result := make([]interface{}, len(path), len(path)+1)
+	copy(result, path)
+	return append(result, key)
+}
+
+func (execution *graphQLExecution) selectionSet(objectType *graphQLType, selections []*graphQLSelection, source map[string]interface{}, path []interface{}) *orderedMap {
+	fields := &orderedMap{values: make(map[string]interface{})}
+	execution.collectFields(objectType, selections, fields)
+
+	result := &orderedMap{values: make(map[string]interface{})}
+	for _, key := range fields.keys {
+		grouped := fields.values[key].([]*graphQLSelection)
+		result.set(key, execution.field(objectType, grouped, source, appendPath(path, key)))
+	}
+	return result
+}
+
+func (execution *graphQLExecution) field(objectType *graphQLType, grouped []*graphQLSelection, source map[string]interface{}, path []interface{}) interface{} {
+	selection := grouped[0]
+	if selection.name == "__typename" {
+		return objectType.name
+	}
+
+	var subselections []*graphQLSelection
+	for _, item := range grouped {
+		subselections = append(subselections, item.selections...)
+	}
+
+	field, ok := objectType.fields[selection.name]
+	if !ok {
+		execution.addError(selection.line, selection.column, "Cannot query field %q on type %q.", selection.name, objectType.name)
+		return nil
+	}
+
+	value, hasValue := source[selection.name]
+	if resolver, ok := execution.endpoint.resolvers[objectType.name+"."+field.name]; ok && !hasValue {
+		arguments := make(map[string]interface{})
+		for name, argument := range selection.arguments {
+			arguments[name] = argument.resolve(execution.variables)
+		}
+
+		buffer := bytes.NewBufferString("")
+		err := resolver.Execute(buffer, map[string]interface{}{"args": arguments, "vars": execution.vars})
+		if err == nil {
+			err = json.Unmarshal(buffer.Bytes(), &value)
+		}
+		if err != nil {
+			execution.errors = append(execution.errors, &graphQLError{
+				Message:   fmt.Sprintf("Resolver of %s.%s failed: %s", objectType.name, field.name, err),
+				Locations: []graphQLLocation{{Line: selection.line, Column: selection.column}},
+				Path:      path,
+			})
+			return nil
+		}
+		hasValue = true
+	}
+
+	return execution.complete(field.typeRef, value, hasValue, subselections, path)
+}
+
+// complete shapes the configured value or generates a new one according to the type
+func (execution *graphQLExecution) complete(typeRef *graphQLTypeRef, value interface{}, hasValue bool, selections []*graphQLSelection, path []interface{}) interface{} {
+	if hasValue && value == nil {
+		return nil
+	}
+
+	if typeRef.ofType != nil {
+		var items []interface{}
+		if hasValue {
+			var ok bool
+			if items, ok = value.([]interface{}); !ok {
+				items = []interface{}{value}
+			}
+		} else {
+			items = make([]interface{}, 1+execution.random.random.Intn(3))
+		}
+
+		result := make([]interface{}, len(items))
+		for i, item := range items {
+			result[i] = execution.complete(typeRef.ofType, item, hasValue, selections, appendPath(path, i))
+		}
+		return result
+	}
+
+	namedType := execution.endpoint.schema.types[typeRef.name]
+
+	switch namedType.kind {
+	case "SCALAR":
+		if hasValue {
+			return value
+		}
+		return execution.scalar(namedType.name)
+	case "ENUM":
+		if hasValue || len(namedType.enumValues) == 0 {
+			return value
+		}
+		return namedType.enumValues[execution.random.random.Intn(len(namedType.enumValues))]
+	}
+
+	source, _ := value.(map[string]interface{})
+	objectType := namedType
+	if namedType.kind != "OBJECT" {
+		if objectType = execution.concreteType(namedType, source); objectType == nil {
+			execution.errors = append(execution.errors, &graphQLError{
+				Message: fmt.Sprintf("Abstract type %q has no implementations.", namedType.name),
+				Path:    path,
+			})
+			return nil
+		}
+	}
+
+	return execution.selectionSet(objectType, selections, source, path)
+}
+
+// concreteType selects an implementation of an interface or a member of a union
+func (execution *graphQLExecution) concreteType(abstractType *graphQLType, source map[string]interface{}) *graphQLType {
+	types := execution.endpoint.schema.types
+
+	if typeName, ok := source["__typename"].(string); ok {
+		for _, possibleType := range abstractType.possibleTypes {
+			if possibleType == typeName {
+				return types[typeName]
+			}
+		}
+	}
+
+	if len(abstractType.possibleTypes) == 0 {
+		return nil
+	}
+	return types[abstractType.possibleTypes[execution.random.random.Intn(len(abstractType.possibleTypes))]]
+}
+
+func (execution *graphQLExecution) scalar(name string) interface{} {
+	switch name {
+	case "Int":
+		return execution.random.integer(map[string]interface{}{})
+	case "Float":
+		return execution.random.number(map[string]interface{}{})
+	case "Boolean":
+		return execution.random.random.Intn(2) == 1
+	case "ID":
+		return strconv.Itoa(1 + execution.random.random.Intn(1000))
+	}
+	return execution.random.string(map[string]interface{}{})
+}
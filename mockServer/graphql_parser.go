@@ -0,0 +1,1139 @@
+package mockServer
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	tokenEOF = iota
+	tokenPunctuator
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+var tokenKindNames = map[int]string{
+	tokenEOF:        "<EOF>",
+	tokenPunctuator: "Punctuator",
+	tokenName:       "Name",
+	tokenInt:        "Int",
+	tokenFloat:      "Float",
+	tokenString:     "String",
+}
+
+type graphQLToken struct {
+	kind   int
+	value  string
+	line   int
+	column int
+}
+
+func (token graphQLToken) String() string {
+	if token.kind == tokenEOF {
+		return tokenKindNames[tokenEOF]
+	}
+	if token.kind == tokenString {
+		return strconv.Quote(token.value)
+	}
+	return fmt.Sprintf("%q", token.value)
+}
+
+// graphQLError is an error with a location in a GraphQL document
+type graphQLError struct {
+	Message   string            `json:"message"`
+	Locations []graphQLLocation `json:"locations,omitempty"`
+	Path      []interface{}     `json:"path,omitempty"`
+}
+
+type graphQLLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (err *graphQLError) Error() string {
+	if len(err.Locations) == 0 {
+		return err.Message
+	}
+	return fmt.Sprintf("%s (%d:%d)", err.Message, err.Locations[0].Line, err.Locations[0].Column)
+}
+
+func newGraphQLError(line, column int, format string, args ...interface{}) *graphQLError {
+	return &graphQLError{
+		Message:   fmt.Sprintf(format, args...),
+		Locations: []graphQLLocation{{Line: line, Column: column}},
+	}
+}
+
+// graphQLLexer splits a GraphQL document into tokens
+type graphQLLexer struct {
+	source []rune
+	offset int
+	line   int
+	column int
+}
+
+func (lexer *graphQLLexer) skipIgnored() {
+	for lexer.offset < len(lexer.source) {
+		char := lexer.source[lexer.offset]
+		switch {
+		case char == '\n':
+			lexer.offset++
+			lexer.line++
+			lexer.column = 1
+		case char == ' ' || char == '\t' || char == '\r' || char == ',' || char == '\uFEFF':
+			lexer.offset++
+			lexer.column++
+		case char == '#':
+			for lexer.offset < len(lexer.source) && lexer.source[lexer.offset] != '\n' {
+				lexer.offset++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (lexer *graphQLLexer) advance(count int) string {
+	value := string(lexer.source[lexer.offset : lexer.offset+count])
+	for _, char := range value {
+		if char == '\n' {
+			lexer.line++
+			lexer.column = 1
+		} else {
+			lexer.column++
+		}
+	}
+	lexer.offset += count
+	return value
+}
+
+func (lexer *graphQLLexer) next() (graphQLToken, error) {
+	lexer.skipIgnored()
+
+	token := graphQLToken{line: lexer.line, column: lexer.column}
+	if lexer.offset >= len(lexer.source) {
+		token.kind = tokenEOF
+		return token, nil
+	}
+
+	rest := string(lexer.source[lexer.offset:])
+	char := lexer.source[lexer.offset]
+
+	switch {
+	case strings.HasPrefix(rest, "..."):
+		token.kind = tokenPunctuator
+		token.value = lexer.advance(3)
+	case strings.ContainsRune("!$&():=@[]{|}", char):
+		token.kind = tokenPunctuator
+		token.value = lexer.advance(1)
+	case char == '_' || (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z'):
+		length := 0
+		for _, c := range lexer.source[lexer.offset:] {
+			if c != '_' && !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') {
+				break
+			}
+			length++
+		}
+		token.kind = tokenName
+		token.value = lexer.advance(length)
+	case char == '-' || (char >= '0' && char <= '9'):
+		length, isFloat := scanNumber(lexer.source[lexer.offset:])
+		token.kind = tokenInt
+		if isFloat {
+			token.kind = tokenFloat
+		}
+		token.value = lexer.advance(length)
+		if _, err := strconv.ParseFloat(token.value, 64); err != nil {
+			return token, newGraphQLError(token.line, token.column, "Syntax Error: Invalid number %s", token.value)
+		}
+	case strings.HasPrefix(rest, `"""`):
+		end := strings.Index(rest[3:], `"""`)
+		if end < 0 {
+			return token, newGraphQLError(token.line, token.column, "Syntax Error: Unterminated string")
+		}
+		token.kind = tokenString
+		token.value = strings.TrimSpace(lexer.advance(len([]rune(rest[:end+6])))[3 : 3+end])
+	case char == '"':
+		token.kind = tokenString
+		value, length, err := unquoteGraphQLString(rest)
+		if err != nil {
+			return token, newGraphQLError(token.line, token.column, "Syntax Error: %s", err)
+		}
+		lexer.advance(length)
+		token.value = value
+	default:
+		return token, newGraphQLError(token.line, token.column, "Syntax Error: Unexpected character %q", char)
+	}
+
+	return token, nil
+}
+
+// scanNumber returns the length of a number at the beginning of the source
+func scanNumber(source []rune) (int, bool) {
+	isFloat := false
+	for i, char := range source {
+		switch {
+		case char >= '0' && char <= '9':
+		case char == '-' && i == 0:
+		case (char == '-' || char == '+') && (source[i-1] == 'e' || source[i-1] == 'E'):
+		case char == '.' || char == 'e' || char == 'E':
+			isFloat = true
+		default:
+			return i, isFloat
+		}
+	}
+	return len(source), isFloat
+}
+
+// unquoteGraphQLString parses a quoted string at the beginning of the source and returns its value and length in runes
+func unquoteGraphQLString(source string) (string, int, error) {
+	escaped := false
+	for i, char := range source[1:] {
+		if char == '\n' {
+			break
+		}
+		if escaped {
+			escaped = false
+			continue
+		}
+		if char == '\\' {
+			escaped = true
+			continue
+		}
+		if char == '"' {
+			quoted := source[:i+2]
+			value, err := strconv.Unquote(quoted)
+			if err != nil {
+				return "", 0, fmt.Errorf("Invalid string %s", quoted)
+			}
+			return value, len([]rune(quoted)), nil
+		}
+	}
+
+	return "", 0, fmt.Errorf("Unterminated string")
+}
+
+// graphQLParser is a recursive descent parser of both SDL and executable documents
+type graphQLParser struct {
+	lexer   *graphQLLexer
+	current graphQLToken
+}
+
+func newGraphQLParser(source string) (*graphQLParser, error) {
+	parser := &graphQLParser{lexer: &graphQLLexer{source: []rune(source), line: 1, column: 1}}
+	return parser, parser.advance()
+}
+
+func (parser *graphQLParser) advance() error {
+	token, err := parser.lexer.next()
+	if err != nil {
+		return err
+	}
+	parser.current = token
+	return nil
+}
+
+func (parser *graphQLParser) peek(kind int, value string) bool {
+	return parser.current.kind == kind && (value == "" || parser.current.value == value)
+}
+
+func (parser *graphQLParser) unexpected(expected string) error {
+	return newGraphQLError(
+		parser.current.line, parser.current.column,
+		"Syntax Error: Expected %s, found %s", expected, parser.current,
+	)
+}
+
+// skip consumes the token if it matches
+func (parser *graphQLParser) skip(kind int, value string) (bool, error) {
+	if !parser.peek(kind, value) {
+		return false, nil
+	}
+	return true, parser.advance()
+}
+
+func (parser *graphQLParser) expect(kind int, value string) (graphQLToken, error) {
+	token := parser.current
+	if !parser.peek(kind, value) {
+		expected := value
+		if expected == "" {
+			expected = tokenKindNames[kind]
+		}
+		return token, parser.unexpected(expected)
+	}
+	return token, parser.advance()
+}
+
+func (parser *graphQLParser) name() (string, error) {
+	token, err := parser.expect(tokenName, "")
+	return token.value, err
+}
+
+// graphQLTypeRef is a reference to a named, list or non-null type
+type graphQLTypeRef struct {
+	name    string
+	ofType  *graphQLTypeRef
+	nonNull bool
+}
+
+func (typeRef *graphQLTypeRef) String() string {
+	var result string
+	if typeRef.ofType != nil {
+		result = "[" + typeRef.ofType.String() + "]"
+	} else {
+		result = typeRef.name
+	}
+	if typeRef.nonNull {
+		result += "!"
+	}
+	return result
+}
+
+// namedType returns the name of the innermost type
+func (typeRef *graphQLTypeRef) namedType() string {
+	for typeRef.ofType != nil {
+		typeRef = typeRef.ofType
+	}
+	return typeRef.name
+}
+
+func (parser *graphQLParser) typeRef() (*graphQLTypeRef, error) {
+	typeRef := new(graphQLTypeRef)
+
+	if ok, err := parser.skip(tokenPunctuator, "["); err != nil {
+		return nil, err
+	} else if ok {
+		if typeRef.ofType, err = parser.typeRef(); err != nil {
+			return nil, err
+		}
+		if _, err = parser.expect(tokenPunctuator, "]"); err != nil {
+			return nil, err
+		}
+	} else if typeRef.name, err = parser.name(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	typeRef.nonNull, err = parser.skip(tokenPunctuator, "!")
+	return typeRef, err
+}
+
+const (
+	valueVariable = iota
+	valueInt
+	valueFloat
+	valueString
+	valueBoolean
+	valueNull
+	valueEnum
+	valueList
+	valueObject
+)
+
+// graphQLValue is a literal value or a variable in a document
+type graphQLValue struct {
+	kind   int
+	raw    string
+	list   []graphQLValue
+	fields map[string]graphQLValue
+}
+
+func (parser *graphQLParser) value(constant bool) (graphQLValue, error) {
+	token := parser.current
+	var value graphQLValue
+
+	switch {
+	case parser.peek(tokenPunctuator, "$") && !constant:
+		if err := parser.advance(); err != nil {
+			return value, err
+		}
+		name, err := parser.name()
+		return graphQLValue{kind: valueVariable, raw: name}, err
+	case parser.peek(tokenPunctuator, "["):
+		value.kind = valueList
+		if err := parser.advance(); err != nil {
+			return value, err
+		}
+		for !parser.peek(tokenPunctuator, "]") {
+			item, err := parser.value(constant)
+			if err != nil {
+				return value, err
+			}
+			value.list = append(value.list, item)
+		}
+		return value, parser.advance()
+	case parser.peek(tokenPunctuator, "{"):
+		value.kind = valueObject
+		value.fields = make(map[string]graphQLValue)
+		if err := parser.advance(); err != nil {
+			return value, err
+		}
+		for !parser.peek(tokenPunctuator, "}") {
+			name, err := parser.name()
+			if err != nil {
+				return value, err
+			}
+			if _, err = parser.expect(tokenPunctuator, ":"); err != nil {
+				return value, err
+			}
+			if value.fields[name], err = parser.value(constant); err != nil {
+				return value, err
+			}
+		}
+		return value, parser.advance()
+	case token.kind == tokenInt:
+		value = graphQLValue{kind: valueInt, raw: token.value}
+	case token.kind == tokenFloat:
+		value = graphQLValue{kind: valueFloat, raw: token.value}
+	case token.kind == tokenString:
+		value = graphQLValue{kind: valueString, raw: token.value}
+	case token.kind == tokenName && (token.value == "true" || token.value == "false"):
+		value = graphQLValue{kind: valueBoolean, raw: token.value}
+	case token.kind == tokenName && token.value == "null":
+		value = graphQLValue{kind: valueNull}
+	case token.kind == tokenName:
+		value = graphQLValue{kind: valueEnum, raw: token.value}
+	default:
+		return value, parser.unexpected("Value")
+	}
+
+	return value, parser.advance()
+}
+
+// resolve converts the value into a Go value, substituting variables
+func (value graphQLValue) resolve(variables map[string]interface{}) interface{} {
+	switch value.kind {
+	case valueVariable:
+		return variables[value.raw]
+	case valueInt:
+		result, _ := strconv.ParseInt(value.raw, 10, 64)
+		return result
+	case valueFloat:
+		result, _ := strconv.ParseFloat(value.raw, 64)
+		return result
+	case valueBoolean:
+		return value.raw == "true"
+	case valueNull:
+		return nil
+	case valueList:
+		result := make([]interface{}, len(value.list))
+		for i, item := range value.list {
+			result[i] = item.resolve(variables)
+		}
+		return result
+	case valueObject:
+		result := make(map[string]interface{})
+		for name, field := range value.fields {
+			result[name] = field.resolve(variables)
+		}
+		return result
+	}
+
+	return value.raw
+}
+
+type graphQLDirective struct {
+	name      string
+	arguments map[string]graphQLValue
+}
+
+func (parser *graphQLParser) arguments(constant bool) (map[string]graphQLValue, error) {
+	arguments := make(map[string]graphQLValue)
+
+	if ok, err := parser.skip(tokenPunctuator, "("); err != nil || !ok {
+		return arguments, err
+	}
+
+	for !parser.peek(tokenPunctuator, ")") {
+		name, err := parser.name()
+		if err != nil {
+			return nil, err
+		}
+		if _, err = parser.expect(tokenPunctuator, ":"); err != nil {
+			return nil, err
+		}
+		if arguments[name], err = parser.value(constant); err != nil {
+			return nil, err
+		}
+	}
+
+	return arguments, parser.advance()
+}
+
+func (parser *graphQLParser) directives(constant bool) ([]graphQLDirective, error) {
+	var directives []graphQLDirective
+
+	for parser.peek(tokenPunctuator, "@") {
+		if err := parser.advance(); err != nil {
+			return nil, err
+		}
+
+		var directive graphQLDirective
+		var err error
+		if directive.name, err = parser.name(); err != nil {
+			return nil, err
+		}
+		if directive.arguments, err = parser.arguments(constant); err != nil {
+			return nil, err
+		}
+		directives = append(directives, directive)
+	}
+
+	return directives, nil
+}
+
+// SDL
+
+// graphQLType is a named type, defined in the schema
+type graphQLType struct {
+	kind          string
+	name          string
+	fields        map[string]*graphQLField
+	fieldOrder    []string
+	interfaces    []string
+	possibleTypes []string
+	enumValues    []string
+}
+
+type graphQLField struct {
+	name      string
+	typeRef   *graphQLTypeRef
+	arguments map[string]*graphQLTypeRef
+}
+
+// graphQLSchema is a set of types, parsed from SDL
+type graphQLSchema struct {
+	types        map[string]*graphQLType
+	queryType    string
+	mutationType string
+}
+
+func parseGraphQLSchema(source string) (*graphQLSchema, error) {
+	parser, err := newGraphQLParser(source)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := &graphQLSchema{types: make(map[string]*graphQLType)}
+	for _, name := range []string{"Int", "Float", "String", "Boolean", "ID"} {
+		schema.types[name] = &graphQLType{kind: "SCALAR", name: name}
+	}
+
+	for !parser.peek(tokenEOF, "") {
+		if err = parser.definition(schema); err != nil {
+			return nil, err
+		}
+	}
+
+	if schema.queryType == "" {
+		schema.queryType = "Query"
+	}
+	if schema.mutationType == "" {
+		schema.mutationType = "Mutation"
+	}
+	if _, ok := schema.types[schema.queryType]; !ok {
+		return nil, fmt.Errorf("Query root type %s is not defined", schema.queryType)
+	}
+
+	// interfaces know their implementations
+	for _, definedType := range schema.types {
+		for _, name := range definedType.interfaces {
+			iface, ok := schema.types[name]
+			if !ok || iface.kind != "INTERFACE" {
+				return nil, fmt.Errorf("Type %s implements %s, which is not a defined interface", definedType.name, name)
+			}
+			iface.possibleTypes = append(iface.possibleTypes, definedType.name)
+		}
+	}
+	for _, definedType := range schema.types {
+		if definedType.kind == "INTERFACE" {
+			sort.Strings(definedType.possibleTypes)
+		}
+	}
+
+	for _, definedType := range schema.types {
+		if err := schema.checkImplementation(definedType); err != nil {
+			return nil, err
+		}
+		if definedType.kind == "UNION" {
+			for _, member := range definedType.possibleTypes {
+				if memberType, ok := schema.types[member]; !ok || memberType.kind != "OBJECT" {
+					return nil, fmt.Errorf("Member %s of union %s is not a defined object type", member, definedType.name)
+				}
+			}
+		}
+		for _, field := range definedType.fields {
+			if _, ok := schema.types[field.typeRef.namedType()]; !ok {
+				return nil, fmt.Errorf("Unknown type %s of field %s.%s", field.typeRef.namedType(), definedType.name, field.name)
+			}
+			for name, argument := range field.arguments {
+				if _, ok := schema.types[argument.namedType()]; !ok {
+					return nil, fmt.Errorf(
+						"Unknown type %s of argument %s of field %s.%s", argument.namedType(), name, definedType.name, field.name,
+					)
+				}
+			}
+		}
+	}
+
+	return schema, nil
+}
+
+// checkImplementation checks that the type declares all fields of its interfaces with compatible types
+func (schema *graphQLSchema) checkImplementation(definedType *graphQLType) error {
+	for _, name := range definedType.interfaces {
+		iface := schema.types[name]
+		for _, fieldName := range iface.fieldOrder {
+			ifaceField := iface.fields[fieldName]
+			field, ok := definedType.fields[fieldName]
+			if !ok {
+				return fmt.Errorf("Interface field %s.%s expected but %s does not provide it", iface.name, fieldName, definedType.name)
+			}
+			if !schema.isSubtype(field.typeRef, ifaceField.typeRef) {
+				return fmt.Errorf(
+					"Interface field %s.%s expects type %s but %s.%s is type %s",
+					iface.name, fieldName, ifaceField.typeRef, definedType.name, fieldName, field.typeRef,
+				)
+			}
+		}
+	}
+	return nil
+}
+
+// isSubtype checks if a value of the type can be returned for the other type
+func (schema *graphQLSchema) isSubtype(typeRef *graphQLTypeRef, superRef *graphQLTypeRef) bool {
+	if superRef.nonNull && !typeRef.nonNull {
+		return false
+	}
+	if typeRef.ofType != nil || superRef.ofType != nil {
+		return typeRef.ofType != nil && superRef.ofType != nil && schema.isSubtype(typeRef.ofType, superRef.ofType)
+	}
+	if typeRef.name == superRef.name {
+		return true
+	}
+
+	if superType, ok := schema.types[superRef.name]; ok {
+		for _, possibleType := range superType.possibleTypes {
+			if possibleType == typeRef.name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (parser *graphQLParser) definition(schema *graphQLSchema) error {
+	// descriptions are ignored
+	if _, err := parser.skip(tokenString, ""); err != nil {
+		return err
+	}
+
+	extend, err := parser.skip(tokenName, "extend")
+	if err != nil {
+		return err
+	}
+
+	keyword, err := parser.name()
+	if err != nil {
+		return err
+	}
+
+	if keyword == "schema" {
+		return parser.schemaDefinition(schema)
+	}
+	if keyword == "directive" {
+		return parser.directiveDefinition()
+	}
+
+	kinds := map[string]string{
+		"scalar": "SCALAR", "type": "OBJECT", "interface": "INTERFACE",
+		"union": "UNION", "enum": "ENUM", "input": "INPUT_OBJECT",
+	}
+	kind, ok := kinds[keyword]
+	if !ok {
+		return newGraphQLError(parser.current.line, parser.current.column, "Syntax Error: Unexpected %q", keyword)
+	}
+
+	name, err := parser.name()
+	if err != nil {
+		return err
+	}
+
+	definedType, exists := schema.types[name]
+	if !exists {
+		definedType = &graphQLType{kind: kind, name: name, fields: make(map[string]*graphQLField)}
+		schema.types[name] = definedType
+	} else if !extend && definedType.fields != nil {
+		return fmt.Errorf("Type %s is defined more than once", name)
+	}
+	if definedType.fields == nil {
+		definedType.fields = make(map[string]*graphQLField)
+	}
+
+	if ok, err := parser.skip(tokenName, "implements"); err != nil {
+		return err
+	} else if ok {
+		if _, err = parser.skip(tokenPunctuator, "&"); err != nil {
+			return err
+		}
+		for parser.peek(tokenName, "") {
+			iface, _ := parser.name()
+			definedType.interfaces = append(definedType.interfaces, iface)
+			if _, err = parser.skip(tokenPunctuator, "&"); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err = parser.directives(true); err != nil {
+		return err
+	}
+
+	switch kind {
+	case "UNION":
+		if ok, err := parser.skip(tokenPunctuator, "="); err != nil || !ok {
+			return err
+		}
+		if _, err = parser.skip(tokenPunctuator, "|"); err != nil {
+			return err
+		}
+		for {
+			member, err := parser.name()
+			if err != nil {
+				return err
+			}
+			definedType.possibleTypes = append(definedType.possibleTypes, member)
+			if ok, err := parser.skip(tokenPunctuator, "|"); err != nil || !ok {
+				return err
+			}
+		}
+	case "ENUM":
+		if ok, err := parser.skip(tokenPunctuator, "{"); err != nil || !ok {
+			return err
+		}
+		for !parser.peek(tokenPunctuator, "}") {
+			if _, err = parser.skip(tokenString, ""); err != nil {
+				return err
+			}
+			value, err := parser.name()
+			if err != nil {
+				return err
+			}
+			definedType.enumValues = append(definedType.enumValues, value)
+			if _, err = parser.directives(true); err != nil {
+				return err
+			}
+		}
+		return parser.advance()
+	case "OBJECT", "INTERFACE", "INPUT_OBJECT":
+		if ok, err := parser.skip(tokenPunctuator, "{"); err != nil || !ok {
+			return err
+		}
+		for !parser.peek(tokenPunctuator, "}") {
+			field, err := parser.fieldDefinition()
+			if err != nil {
+				return err
+			}
+			if _, ok := definedType.fields[field.name]; !ok {
+				definedType.fieldOrder = append(definedType.fieldOrder, field.name)
+			}
+			definedType.fields[field.name] = field
+		}
+		return parser.advance()
+	}
+
+	return nil
+}
+
+func (parser *graphQLParser) fieldDefinition() (*graphQLField, error) {
+	if _, err := parser.skip(tokenString, ""); err != nil {
+		return nil, err
+	}
+
+	field := &graphQLField{arguments: make(map[string]*graphQLTypeRef)}
+	var err error
+	if field.name, err = parser.name(); err != nil {
+		return nil, err
+	}
+
+	if ok, err := parser.skip(tokenPunctuator, "("); err != nil {
+		return nil, err
+	} else if ok {
+		for !parser.peek(tokenPunctuator, ")") {
+			argument, err := parser.inputValueDefinition()
+			if err != nil {
+				return nil, err
+			}
+			field.arguments[argument.name] = argument.typeRef
+		}
+		if err = parser.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	return field, parser.fieldType(field)
+}
+
+// inputValueDefinition parses arguments and fields of input types
+func (parser *graphQLParser) inputValueDefinition() (*graphQLField, error) {
+	if _, err := parser.skip(tokenString, ""); err != nil {
+		return nil, err
+	}
+
+	field := new(graphQLField)
+	var err error
+	if field.name, err = parser.name(); err != nil {
+		return nil, err
+	}
+
+	return field, parser.fieldType(field)
+}
+
+func (parser *graphQLParser) fieldType(field *graphQLField) error {
+	if _, err := parser.expect(tokenPunctuator, ":"); err != nil {
+		return err
+	}
+
+	var err error
+	if field.typeRef, err = parser.typeRef(); err != nil {
+		return err
+	}
+
+	// default values are ignored
+	if ok, err := parser.skip(tokenPunctuator, "="); err != nil {
+		return err
+	} else if ok {
+		if _, err = parser.value(true); err != nil {
+			return err
+		}
+	}
+
+	_, err = parser.directives(true)
+	return err
+}
+
+func (parser *graphQLParser) schemaDefinition(schema *graphQLSchema) error {
+	if _, err := parser.directives(true); err != nil {
+		return err
+	}
+	if _, err := parser.expect(tokenPunctuator, "{"); err != nil {
+		return err
+	}
+
+	for !parser.peek(tokenPunctuator, "}") {
+		operation, err := parser.name()
+		if err != nil {
+			return err
+		}
+		if _, err = parser.expect(tokenPunctuator, ":"); err != nil {
+			return err
+		}
+		typeName, err := parser.name()
+		if err != nil {
+			return err
+		}
+
+		switch operation {
+		case "query":
+			schema.queryType = typeName
+		case "mutation":
+			schema.mutationType = typeName
+		}
+	}
+
+	return parser.advance()
+}
+
+// directiveDefinition skips definitions of directives, they don't affect mocked data
+func (parser *graphQLParser) directiveDefinition() error {
+	if _, err := parser.expect(tokenPunctuator, "@"); err != nil {
+		return err
+	}
+	if _, err := parser.name(); err != nil {
+		return err
+	}
+
+	if ok, err := parser.skip(tokenPunctuator, "("); err != nil {
+		return err
+	} else if ok {
+		for !parser.peek(tokenPunctuator, ")") {
+			if _, err := parser.inputValueDefinition(); err != nil {
+				return err
+			}
+		}
+		if err = parser.advance(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := parser.skip(tokenName, "repeatable"); err != nil {
+		return err
+	}
+	if _, err := parser.expect(tokenName, "on"); err != nil {
+		return err
+	}
+	if _, err := parser.skip(tokenPunctuator, "|"); err != nil {
+		return err
+	}
+	for {
+		if _, err := parser.name(); err != nil {
+			return err
+		}
+		if ok, err := parser.skip(tokenPunctuator, "|"); err != nil || !ok {
+			return err
+		}
+	}
+}
+
+// Executable documents
+
+type graphQLSelection struct {
+	alias         string
+	name          string
+	arguments     map[string]graphQLValue
+	directives    []graphQLDirective
+	selections    []*graphQLSelection
+	fragmentName  string
+	typeCondition string
+	inline        bool
+	line          int
+	column        int
+}
+
+func (selection *graphQLSelection) responseKey() string {
+	if selection.alias != "" {
+		return selection.alias
+	}
+	return selection.name
+}
+
+type graphQLVariable struct {
+	name    string
+	typeRef *graphQLTypeRef
+	value   *graphQLValue
+}
+
+type graphQLOperation struct {
+	kind       string
+	name       string
+	variables  []graphQLVariable
+	selections []*graphQLSelection
+	line       int
+	column     int
+}
+
+type graphQLFragment struct {
+	name          string
+	typeCondition string
+	selections    []*graphQLSelection
+	line          int
+	column        int
+}
+
+type graphQLDocument struct {
+	operations []*graphQLOperation
+	fragments  map[string]*graphQLFragment
+}
+
+func parseGraphQLQuery(source string) (*graphQLDocument, error) {
+	parser, err := newGraphQLParser(source)
+	if err != nil {
+		return nil, err
+	}
+
+	document := &graphQLDocument{fragments: make(map[string]*graphQLFragment)}
+
+	for !parser.peek(tokenEOF, "") {
+		token := parser.current
+
+		switch {
+		case parser.peek(tokenPunctuator, "{"):
+			operation := &graphQLOperation{kind: "query", line: token.line, column: token.column}
+			if operation.selections, err = parser.selectionSet(); err != nil {
+				return nil, err
+			}
+			document.operations = append(document.operations, operation)
+		case parser.peek(tokenName, "query") || parser.peek(tokenName, "mutation") || parser.peek(tokenName, "subscription"):
+			operation, err := parser.operation()
+			if err != nil {
+				return nil, err
+			}
+			document.operations = append(document.operations, operation)
+		case parser.peek(tokenName, "fragment"):
+			fragment, err := parser.fragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := document.fragments[fragment.name]; ok {
+				return nil, newGraphQLError(token.line, token.column, "There can be only one fragment named %q.", fragment.name)
+			}
+			document.fragments[fragment.name] = fragment
+		default:
+			return nil, parser.unexpected("Definition")
+		}
+	}
+
+	if len(document.operations) == 0 {
+		return nil, &graphQLError{Message: "Document does not contain any operation"}
+	}
+
+	return document, nil
+}
+
+func (parser *graphQLParser) operation() (*graphQLOperation, error) {
+	operation := &graphQLOperation{kind: parser.current.value, line: parser.current.line, column: parser.current.column}
+	if err := parser.advance(); err != nil {
+		return nil, err
+	}
+
+	if parser.peek(tokenName, "") {
+		operation.name, _ = parser.name()
+	}
+
+	if ok, err := parser.skip(tokenPunctuator, "("); err != nil {
+		return nil, err
+	} else if ok {
+		for !parser.peek(tokenPunctuator, ")") {
+			var variable graphQLVariable
+			if _, err = parser.expect(tokenPunctuator, "$"); err != nil {
+				return nil, err
+			}
+			if variable.name, err = parser.name(); err != nil {
+				return nil, err
+			}
+			if _, err = parser.expect(tokenPunctuator, ":"); err != nil {
+				return nil, err
+			}
+			if variable.typeRef, err = parser.typeRef(); err != nil {
+				return nil, err
+			}
+			if ok, err := parser.skip(tokenPunctuator, "="); err != nil {
+				return nil, err
+			} else if ok {
+				value, err := parser.value(true)
+				if err != nil {
+					return nil, err
+				}
+				variable.value = &value
+			}
+			if _, err = parser.directives(true); err != nil {
+				return nil, err
+			}
+			operation.variables = append(operation.variables, variable)
+		}
+		if err = parser.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := parser.directives(false); err != nil {
+		return nil, err
+	}
+
+	var err error
+	operation.selections, err = parser.selectionSet()
+	return operation, err
+}
+
+func (parser *graphQLParser) fragment() (*graphQLFragment, error) {
+	fragment := &graphQLFragment{line: parser.current.line, column: parser.current.column}
+	if err := parser.advance(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if fragment.name, err = parser.name(); err != nil {
+		return nil, err
+	}
+	if _, err = parser.expect(tokenName, "on"); err != nil {
+		return nil, err
+	}
+	if fragment.typeCondition, err = parser.name(); err != nil {
+		return nil, err
+	}
+	if _, err = parser.directives(false); err != nil {
+		return nil, err
+	}
+
+	fragment.selections, err = parser.selectionSet()
+	return fragment, err
+}
+
+func (parser *graphQLParser) selectionSet() ([]*graphQLSelection, error) {
+	if _, err := parser.expect(tokenPunctuator, "{"); err != nil {
+		return nil, err
+	}
+
+	var selections []*graphQLSelection
+	for !parser.peek(tokenPunctuator, "}") {
+		selection, err := parser.selection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, selection)
+	}
+
+	if len(selections) == 0 {
+		return nil, parser.unexpected("Name")
+	}
+
+	return selections, parser.advance()
+}
+
+func (parser *graphQLParser) selection() (*graphQLSelection, error) {
+	selection := &graphQLSelection{line: parser.current.line, column: parser.current.column}
+	var err error
+
+	if ok, err := parser.skip(tokenPunctuator, "..."); err != nil {
+		return nil, err
+	} else if ok {
+		if parser.peek(tokenName, "") && !parser.peek(tokenName, "on") {
+			selection.fragmentName, _ = parser.name()
+			selection.directives, err = parser.directives(false)
+			return selection, err
+		}
+
+		selection.inline = true
+		if ok, err := parser.skip(tokenName, "on"); err != nil {
+			return nil, err
+		} else if ok {
+			if selection.typeCondition, err = parser.name(); err != nil {
+				return nil, err
+			}
+		}
+		if selection.directives, err = parser.directives(false); err != nil {
+			return nil, err
+		}
+		selection.selections, err = parser.selectionSet()
+		return selection, err
+	}
+
+	if selection.name, err = parser.name(); err != nil {
+		return nil, err
+	}
+	if ok, err := parser.skip(tokenPunctuator, ":"); err != nil {
+		return nil, err
+	} else if ok {
+		selection.alias = selection.name
+		if selection.name, err = parser.name(); err != nil {
+			return nil, err
+		}
+	}
+
+	if selection.arguments, err = parser.arguments(false); err != nil {
+		return nil, err
+	}
+	if selection.directives, err = parser.directives(false); err != nil {
+		return nil, err
+	}
+
+	if parser.peek(tokenPunctuator, "{") {
+		selection.selections, err = parser.selectionSet()
+	}
+
+	return selection, err
+}
@@ -0,0 +1,246 @@
+package mockServer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func loadGraphQLEndpoint(t *testing.T, resolvers string) *GraphQLEndpoint {
+
+	config := "schema: file://../examples/schema.graphql\nseed: 42\n" + resolvers
+
+	var endpoint GraphQLEndpoint
+	err := yaml.Unmarshal([]byte(config), &endpoint)
+	assert.Nil(t, err)
+
+	return &endpoint
+}
+
+func executeGraphQL(endpoint *GraphQLEndpoint, query string, variables map[string]interface{}) (*graphQLOperation, string) {
+	operation, response := endpoint.execute(&graphQLRequest{Query: query, Variables: variables}, nil)
+	payload, _ := json.Marshal(response)
+	return operation, string(payload)
+}
+
+func TestParseGraphQLSchema(t *testing.T) {
+	endpoint := loadGraphQLEndpoint(t, "")
+	schema := endpoint.schema
+
+	assert.Equal(t, "Query", schema.queryType)
+	assert.Equal(t, "Mutation", schema.mutationType)
+	assert.Equal(t, "OBJECT", schema.types["User"].kind)
+	assert.Equal(t, []string{"id", "name", "age", "rating", "active", "role", "createdAt", "posts"}, schema.types["User"].fieldOrder)
+	assert.Equal(t, "[Post!]!", schema.types["User"].fields["posts"].typeRef.String())
+	assert.Equal(t, []string{"ADMIN", "EDITOR", "VIEWER"}, schema.types["Role"].enumValues)
+	assert.Equal(t, []string{"Post", "User"}, schema.types["Node"].possibleTypes)
+	assert.Equal(t, []string{"User", "Post"}, schema.types["SearchResult"].possibleTypes)
+	assert.Equal(t, "SCALAR", schema.types["DateTime"].kind)
+}
+
+func TestParseInvalidGraphQLSchema(t *testing.T) {
+	_, err := parseGraphQLSchema("type Query { user: User }")
+	assert.NotNil(t, err)
+	assert.Equal(t, "Unknown type User of field Query.user", err.Error())
+
+	_, err = parseGraphQLSchema("type Query { user: }")
+	assert.NotNil(t, err)
+	assert.Equal(t, `Syntax Error: Expected Name, found "}" (1:20)`, err.Error())
+
+	_, err = parseGraphQLSchema("type Query { result: U } union U = Missing")
+	assert.NotNil(t, err)
+	assert.Equal(t, "Member Missing of union U is not a defined object type", err.Error())
+
+	_, err = parseGraphQLSchema("type Query { node: Node } interface Node { id: ID } type User implements Node & Missing { id: ID }")
+	assert.NotNil(t, err)
+	assert.Equal(t, "Type User implements Missing, which is not a defined interface", err.Error())
+
+	_, err = parseGraphQLSchema("type Query { n: Node } interface Node { id: ID! } type A implements Node { name: String }")
+	assert.NotNil(t, err)
+	assert.Equal(t, "Interface field Node.id expected but A does not provide it", err.Error())
+
+	_, err = parseGraphQLSchema("type Query { n: Node } interface Node { id: ID! } type A implements Node { id: String! }")
+	assert.NotNil(t, err)
+	assert.Equal(t, "Interface field Node.id expects type ID! but A.id is type String!", err.Error())
+
+	_, err = parseGraphQLSchema(
+		"type Query { n: Node } interface Node { id: ID children: [Node] } type A implements Node { id: ID! children: [A!]! }",
+	)
+	assert.Nil(t, err)
+
+	_, err = parseGraphQLSchema("type Query { a(x: Missing): Int }")
+	assert.NotNil(t, err)
+	assert.Equal(t, "Unknown type Missing of argument x of field Query.a", err.Error())
+}
+
+func TestExecuteGraphQLQueryShapedToSelectionSet(t *testing.T) {
+	endpoint := loadGraphQLEndpoint(t, "")
+
+	query := `
+query GetUser($id: ID!) {
+  user(id: $id) {
+    __typename
+    userId: id
+    name
+    ...Details
+    posts(first: 2) { title }
+  }
+}
+
+fragment Details on User {
+  age
+  role
+  active @skip(if: true)
+}`
+	operation, payload := executeGraphQL(endpoint, query, map[string]interface{}{"id": "1"})
+
+	assert.Equal(t, "GetUser", operation.name)
+
+	var response struct {
+		Data struct {
+			User map[string]interface{} `json:"user"`
+		} `json:"data"`
+		Errors []interface{} `json:"errors"`
+	}
+	assert.Nil(t, json.Unmarshal([]byte(payload), &response))
+	assert.Nil(t, response.Errors)
+
+	user := response.Data.User
+	assert.Len(t, user, 6)
+	assert.Equal(t, "User", user["__typename"])
+	assert.IsType(t, "", user["userId"])
+	assert.IsType(t, "", user["name"])
+	assert.IsType(t, float64(0), user["age"])
+	assert.Contains(t, []interface{}{"ADMIN", "EDITOR", "VIEWER"}, user["role"])
+	for _, post := range user["posts"].([]interface{}) {
+		assert.Len(t, post, 1)
+		assert.IsType(t, "", post.(map[string]interface{})["title"])
+	}
+
+	// keys follow the selection set
+	assert.True(t, strings.Index(payload, `"__typename"`) < strings.Index(payload, `"userId"`))
+	assert.True(t, strings.Index(payload, `"name"`) < strings.Index(payload, `"age"`))
+}
+
+func TestExecuteGraphQLWithResolvers(t *testing.T) {
+	resolvers := `
+resolvers:
+  Query.user: '{"id": "{{.args.id}}", "name": "Alice", "posts": [{"title": "Hello"}]}'
+  Query.search: '[{"__typename": "Post", "title": "{{.args.text}}"}]'
+`
+	endpoint := loadGraphQLEndpoint(t, resolvers)
+
+	_, payload := executeGraphQL(endpoint, `{ user(id: 7) { id name posts { title } } }`, nil)
+	assert.Equal(t, `{"data":{"user":{"id":"7","name":"Alice","posts":[{"title":"Hello"}]}}}`, payload)
+
+	query := `{ search(text: "news") { ... on Post { title } ... on User { name } } }`
+	_, payload = executeGraphQL(endpoint, query, nil)
+	assert.Equal(t, `{"data":{"search":[{"title":"news"}]}}`, payload)
+}
+
+func TestExecuteInvalidGraphQLQueries(t *testing.T) {
+	endpoint := loadGraphQLEndpoint(t, "")
+
+	cases := []struct{ query, expected string }{
+		{
+			`{ user(id: 1) { email } }`,
+			`{"errors":[{"message":"Cannot query field \"email\" on type \"User\".","locations":[{"line":1,"column":17}]}]}`,
+		},
+		{
+			`{ user { name } }`,
+			`{"errors":[{"message":"Field \"user\" argument \"id\" of type \"ID!\" is required, but it was not provided.","locations":[{"line":1,"column":3}]}]}`,
+		},
+		{
+			`{ users }`,
+			`{"errors":[{"message":"Field \"users\" of type \"[User!]!\" must have a selection of subfields. Did you mean \"users { ... }\"?","locations":[{"line":1,"column":3}]}]}`,
+		},
+		{
+			`{ users { name { first } } }`,
+			`{"errors":[{"message":"Field \"name\" must not have a selection since type \"String!\" has no subfields.","locations":[{"line":1,"column":11}]}]}`,
+		},
+		{
+			`{ users { ...Missing } }`,
+			`{"errors":[{"message":"Unknown fragment \"Missing\".","locations":[{"line":1,"column":11}]}]}`,
+		},
+		{
+			`{ user(id: $id) { name } }`,
+			`{"errors":[{"message":"Variable \"$id\" is not defined.","locations":[{"line":1,"column":3}]}]}`,
+		},
+		{
+			`{ users { name }`,
+			`{"errors":[{"message":"Syntax Error: Expected Name, found \u003cEOF\u003e","locations":[{"line":1,"column":17}]}]}`,
+		},
+		{
+			`{ a: user(id: 1) { id } a: users { name } }`,
+			`{"errors":[{"message":"Fields \"a\" conflict because \"user\" and \"users\" are different fields. ` +
+				`Use different aliases on the fields to fetch both if this was intentional.","locations":[{"line":1,"column":3},{"line":1,"column":25}]}]}`,
+		},
+		{
+			`{ user(id: 1) { id } user(id: 2) { name } }`,
+			`{"errors":[{"message":"Fields \"user\" conflict because they have differing arguments. ` +
+				`Use different aliases on the fields to fetch both if this was intentional.","locations":[{"line":1,"column":3},{"line":1,"column":22}]}]}`,
+		},
+		{
+			`{ users { name @skip } }`,
+			`{"errors":[{"message":"Directive \"@skip\" argument \"if\" of type \"Boolean!\" is required, but it was not provided.","locations":[{"line":1,"column":11}]}]}`,
+		},
+		{
+			`{ users { ... on Post { title } } }`,
+			`{"errors":[{"message":"Fragment cannot be spread here as objects of type \"User\" can never be of type \"Post\".","locations":[{"line":1,"column":11}]}]}`,
+		},
+		{
+			`{ users { ...PostFields } } fragment PostFields on Post { title }`,
+			`{"errors":[{"message":"Fragment \"PostFields\" cannot be spread here as objects of type \"User\" can never be of type \"Post\".","locations":[{"line":1,"column":11}]}]}`,
+		},
+		{
+			`query A { users { name } } query B { users { name } }`,
+			`{"errors":[{"message":"Must provide operation name if query contains multiple operations."}]}`,
+		},
+	}
+
+	for _, testCase := range cases {
+		_, payload := executeGraphQL(endpoint, testCase.query, nil)
+		assert.Equal(t, testCase.expected, payload)
+	}
+}
+
+func TestHandleGraphQLRequest(t *testing.T) {
+	endpoint := loadGraphQLEndpoint(t, "resolvers:\n  Mutation.createUser: '{\"name\": \"{{.args.input.name}}\"}'\n")
+	logMessage := new(responseLogMessage)
+	handler := Endpoint{URL: "/graphql", GraphQL: endpoint}.GetHandler(logMessage.writeResponseLog, "server_name")
+
+	body := `{"query": "mutation Create($name: String!) { createUser(input: {name: $name}) { name } }", "variables": {"name": "Bob"}}`
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	handler(w, req)
+
+	resp := w.Result()
+	payload, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.Equal(t, `{"data":{"createUser":{"name":"Bob"}}}`, string(payload))
+	assert.Equal(t, "Create", logMessage.Operation)
+	assert.Equal(t, http.StatusOK, logMessage.StatusCode)
+
+	w = httptest.NewRecorder()
+	query := url.QueryEscape(`mutation Create { createUser(input: {name: "Bob"}) { name } }`)
+	handler(w, httptest.NewRequest("GET", "/graphql?query="+query, nil))
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Result().StatusCode)
+	assert.Equal(t, "Create", logMessage.Operation)
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest("POST", "/graphql", strings.NewReader("{")))
+
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	assert.Equal(t, "", logMessage.Operation)
+}
@@ -0,0 +1,531 @@
+package mockServer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// configExtensions are extensions of files, which are loaded from config directories
+var configExtensions = map[string]bool{".yaml": true, ".yml": true, ".json": true}
+
+// osFileSystem opens files by their paths in the file system of the OS
+type osFileSystem struct{}
+
+func (osFileSystem) Open(name string) (http.File, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// readFile reads the whole file from the file system
+func readFile(fileSystem http.FileSystem, name string) ([]byte, error) {
+	file, err := fileSystem.Open(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("File does not exist %s", name)
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	return ioutil.ReadAll(file)
+}
+
+// LoaderOptions configure the Loader
+type LoaderOptions struct {
+	// BaseDir is a directory, against which relative paths passed to the loader are resolved.
+	// It's the working directory for the OS file system and the root for others
+	BaseDir string
+	// FileSystem is used to read configs and files referenced by them. The OS file system is used by default
+	FileSystem http.FileSystem
+	// Strict makes the loader validate every file against the schema and reject duplicated servers
+	Strict bool
+	// Params override params of configs and environment variables with the same names in substitutions
+	Params map[string]string
+}
+
+// Loader loads configs into independent server collections.
+// Paths in each config file are resolved against the directory of this file
+type Loader struct {
+	options LoaderOptions
+}
+
+// NewLoader creates a loader with passed options
+func NewLoader(options LoaderOptions) *Loader {
+	if options.FileSystem == nil {
+		options.FileSystem = osFileSystem{}
+		if options.BaseDir == "" {
+			options.BaseDir, _ = os.Getwd()
+		}
+	}
+	if options.BaseDir == "" {
+		options.BaseDir = "/"
+	}
+
+	return &Loader{options: options}
+}
+
+// Load loads the config from a file or a directory with all included files
+func (loader *Loader) Load(configPath string) (*ServerCollection, error) {
+	load := loader.newLoading()
+	rootPath := load.absPath(loader.options.BaseDir, configPath)
+
+	files, err := load.readConfigFiles(rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	baseDir := rootPath
+	if info, err := load.stat(rootPath); err == nil && !info.IsDir() {
+		baseDir = path.Dir(rootPath)
+	}
+
+	return load.collect(files, baseDir)
+}
+
+// LoadData loads the config from YAML or JSON data like a file in the base directory
+func (loader *Loader) LoadData(data []byte) (*ServerCollection, error) {
+	load := loader.newLoading()
+
+	files, err := load.readConfigData(path.Join(loader.options.BaseDir, "<data>"), data)
+	if err != nil {
+		return nil, err
+	}
+
+	return load.collect(files, loader.options.BaseDir)
+}
+
+// collect validates files in the strict mode and merges them into a collection
+func (load *loading) collect(files []configFile, baseDir string) (*ServerCollection, error) {
+	if load.options.Strict {
+		if err := validateFiles(files); err != nil {
+			return nil, err
+		}
+	}
+
+	serverCollection, err := load.decodeFiles(files)
+	if err != nil {
+		return nil, err
+	}
+	serverCollection.BaseDir = baseDir
+
+	if load.options.Strict {
+		if err = serverCollection.checkDuplicates(); err != nil {
+			return nil, err
+		}
+	}
+
+	return serverCollection, nil
+}
+
+// Check loads the config in the strict mode.
+// Then it renders every response and validates it against the attached OpenAPI spec or JSON schema
+func (loader *Loader) Check(configPath string) error {
+	options := loader.options
+	options.Strict = true
+
+	serverCollection, err := NewLoader(options).Load(configPath)
+	if err != nil {
+		return err
+	}
+
+	return serverCollection.checkResponses()
+}
+
+// configFile is a single file of the config with its absolute path
+type configFile struct {
+	path string
+	data []byte
+}
+
+// validateFiles validates every file against the schema of the config
+func validateFiles(files []configFile) error {
+	for _, file := range files {
+		err := validateSchema(file.data)
+		if err == nil {
+			continue
+		}
+		// files are named only when there are several of them
+		if len(files) == 1 {
+			return err
+		}
+		return fmt.Errorf("%s:\n%s", file.path, err)
+	}
+
+	return nil
+}
+
+// loading is a state of a single call of the loader
+type loading struct {
+	*Loader
+	visited map[string]bool
+}
+
+func (loader *Loader) newLoading() *loading {
+	return &loading{Loader: loader, visited: make(map[string]bool)}
+}
+
+func (load *loading) lookup(name string) (string, bool) {
+	if value, ok := load.options.Params[name]; ok {
+		return value, true
+	}
+	return os.LookupEnv(name)
+}
+
+func (load *loading) absPath(dir, name string) string {
+	if path.IsAbs(name) {
+		return path.Clean(name)
+	}
+	return path.Join(dir, name)
+}
+
+// readDir returns sorted names of files in the directory
+func (load *loading) readDir(dir string) ([]os.FileInfo, error) {
+	file, err := load.options.FileSystem.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries, err := file.Readdir(-1)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+func (load *loading) stat(name string) (os.FileInfo, error) {
+	file, err := load.options.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return file.Stat()
+}
+
+// glob returns names of files matching the absolute pattern like filepath.Glob does in the OS
+func (load *loading) glob(pattern string) ([]string, error) {
+	matches := []string{"/"}
+
+	for _, segment := range strings.Split(strings.TrimPrefix(path.Clean(pattern), "/"), "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return nil, err
+		}
+
+		var next []string
+		for _, dir := range matches {
+			if !strings.ContainsAny(segment, `*?[\`) {
+				next = append(next, path.Join(dir, segment))
+				continue
+			}
+
+			entries, err := load.readDir(dir)
+			if err != nil {
+				continue
+			}
+			for _, entry := range entries {
+				if matched, _ := path.Match(segment, entry.Name()); matched {
+					next = append(next, path.Join(dir, entry.Name()))
+				}
+			}
+		}
+		matches = next
+	}
+
+	var existing []string
+	for _, match := range matches {
+		if _, err := load.stat(match); err == nil {
+			existing = append(existing, match)
+		}
+	}
+
+	return existing, nil
+}
+
+// includes returns absolute paths of the files and directories included by the config file
+func (load *loading) includes(file configFile) ([]string, error) {
+	var config struct {
+		Include []string `json:"include"`
+	}
+	if err := yaml.Unmarshal(file.data, &config); err != nil {
+		return nil, fmt.Errorf("%s: %s", file.path, err)
+	}
+
+	var paths []string
+	for _, pattern := range config.Include {
+		pattern = load.absPath(path.Dir(file.path), pattern)
+
+		matches, err := load.glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid include %s: %s", file.path, pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("%s: include %s matches no files", file.path, pattern)
+		}
+		paths = append(paths, matches...)
+	}
+
+	return paths, nil
+}
+
+// readConfigFiles reads the config file or all config files of the directory with all their includes.
+// Files, which were already read, are skipped, so includes can't make a cycle
+func (load *loading) readConfigFiles(absPath string) ([]configFile, error) {
+	if load.visited[absPath] {
+		return nil, nil
+	}
+	load.visited[absPath] = true
+
+	info, err := load.stat(absPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		entries, err := load.readDir(absPath)
+		if err != nil {
+			return nil, err
+		}
+
+		var files []configFile
+		for _, entry := range entries {
+			if entry.IsDir() || !configExtensions[strings.ToLower(path.Ext(entry.Name()))] {
+				continue
+			}
+
+			dirFiles, err := load.readConfigFiles(path.Join(absPath, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, dirFiles...)
+		}
+		return files, nil
+	}
+
+	data, err := readFile(load.options.FileSystem, absPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return load.readConfigData(absPath, data)
+}
+
+// readConfigData substitutes variables into the data of the config file and reads its includes
+func (load *loading) readConfigData(absPath string, data []byte) ([]configFile, error) {
+	data, err := substitute(data, load.lookup)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", absPath, err)
+	}
+
+	file := configFile{path: absPath, data: data}
+	files := []configFile{file}
+
+	includes, err := load.includes(file)
+	if err != nil {
+		return nil, err
+	}
+	for _, include := range includes {
+		includedFiles, err := load.readConfigFiles(include)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, includedFiles...)
+	}
+
+	return files, nil
+}
+
+// decodeFiles merges servers and params of all files into one collection
+func (load *loading) decodeFiles(files []configFile) (*ServerCollection, error) {
+	serverCollection := ServerCollection{Params: make(Params)}
+
+	for _, file := range files {
+		fileCollection, err := load.decodeFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", file.path, err)
+		}
+		serverCollection.Servers = append(serverCollection.Servers, fileCollection.Servers...)
+		for key, value := range fileCollection.Params {
+			serverCollection.Params[key] = value
+		}
+	}
+
+	for key, value := range load.options.Params {
+		serverCollection.Params[key] = value
+	}
+	for i := range serverCollection.Servers {
+		serverCollection.Servers[i].params = serverCollection.Params
+		serverCollection.Servers[i].setFileSystem(load.options.FileSystem)
+	}
+
+	return &serverCollection, nil
+}
+
+// decodeFile resolves references to files in the config file before parsing it,
+// so relative paths are resolved against this file and all files are read from the file system of the loader
+func (load *loading) decodeFile(file configFile) (*ServerCollection, error) {
+	jsonData, err := yaml.YAMLToJSON(file.data)
+	if err != nil {
+		return nil, err
+	}
+
+	var document interface{}
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.UseNumber()
+	if err = decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+
+	if err = load.resolveDocument(document, path.Dir(file.path)); err != nil {
+		return nil, err
+	}
+
+	jsonData, err = json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseConfig(jsonData)
+}
+
+func (load *loading) resolveDocument(document interface{}, dir string) error {
+	root, _ := document.(map[string]interface{})
+	servers, _ := root["servers"].([]interface{})
+
+	for _, server := range servers {
+		server, ok := server.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if filePath, ok := server["openapi"].(string); ok {
+			spec, err := load.readDocument(dir, filePath)
+			if err != nil {
+				return err
+			}
+			server["openapi"] = spec
+		}
+
+		if err := load.resolveResponse(server["default_response"], dir); err != nil {
+			return err
+		}
+
+		endpoints, _ := server["endpoints"].([]interface{})
+		for _, endpoint := range endpoints {
+			endpoint, ok := endpoint.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			for _, method := range endpointMethods {
+				if err := load.resolveResponse(endpoint[method], dir); err != nil {
+					return err
+				}
+			}
+
+			if graphQL, ok := endpoint["graphql"].(map[string]interface{}); ok {
+				if filePath, ok := graphQL["schema"].(string); ok && strings.HasPrefix(filePath, "file://") {
+					source, err := readFile(load.options.FileSystem, load.filePath(dir, filePath))
+					if err != nil {
+						return err
+					}
+					graphQL["schema"] = string(source)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// resolveResponse inlines templates and schemas from files and makes paths of files absolute
+func (load *loading) resolveResponse(response interface{}, dir string) error {
+	fields, ok := response.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	if templateString, ok := fields["template"].(string); ok {
+		if matched, _ := regexp.MatchString(filePathRegexp, templateString); matched {
+			source, err := readFile(load.options.FileSystem, load.filePath(dir, templateString))
+			if err != nil {
+				return err
+			}
+			fields["template"] = string(source)
+		}
+	}
+
+	if filePath, ok := fields["schema"].(string); ok {
+		schema, err := load.readDocument(dir, filePath)
+		if err != nil {
+			return err
+		}
+		fields["schema"] = schema
+	}
+
+	if filePath, ok := fields["file"].(string); ok {
+		fields["file"] = "file://" + load.filePath(dir, filePath)
+	}
+
+	return nil
+}
+
+// readDocument reads a YAML or JSON file
+func (load *loading) readDocument(dir, filePath string) (interface{}, error) {
+	data, err := readFile(load.options.FileSystem, load.filePath(dir, filePath))
+	if err != nil {
+		return nil, err
+	}
+
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var document interface{}
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.UseNumber()
+	err = decoder.Decode(&document)
+
+	return document, err
+}
+
+// filePath converts the file:// reference into an absolute path
+func (load *loading) filePath(dir, reference string) string {
+	return load.absPath(dir, strings.TrimPrefix(reference, "file://"))
+}
+
+// defaultLoading resolves references of configs, which are decoded without a loader,
+// like the default loader does: in the file system of the OS against the working directory
+func defaultLoading() *loading {
+	return NewLoader(LoaderOptions{}).newLoading()
+}
+
+// referencePath converts the file:// reference of the config decoded without a loader into an absolute path
+func referencePath(reference string) string {
+	load := defaultLoading()
+	return load.filePath(load.options.BaseDir, reference)
+}
+
+// readReference reads the file:// reference of the config decoded without a loader.
+// Loaders pass contents of files instead of references, so they are read from the file system of the loader
+func readReference(reference string) ([]byte, error) {
+	return readFile(defaultLoading().options.FileSystem, referencePath(reference))
+}
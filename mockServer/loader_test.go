@@ -0,0 +1,198 @@
+package mockServer
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadIndependentCollections(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"first/config.yaml": `
+servers:
+  - name: server_1
+    port: 4573
+    endpoints:
+      - url: /url
+        GET:
+          template: file://response.txt
+`,
+		"first/response.txt": "first",
+		"second/config.yaml": `
+servers:
+  - name: server_1
+    port: 4573
+    endpoints:
+      - url: /url
+        GET:
+          template: file://response.txt
+`,
+		"second/response.txt": "second",
+	})
+	defer os.RemoveAll(dir)
+
+	loader := NewLoader(LoaderOptions{BaseDir: dir})
+	collections := make([]*ServerCollection, 2)
+
+	var wg sync.WaitGroup
+	for i, name := range []string{"first", "second"} {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			collection, err := loader.Load(filepath.Join(name, "config.yaml"))
+			assert.Nil(t, err)
+			collections[i] = collection
+		}(i, name)
+	}
+	wg.Wait()
+
+	for i, name := range []string{"first", "second"} {
+		assert.Equal(t, filepath.Join(dir, name), collections[i].BaseDir)
+
+		body, err := collections[i].Servers[0].Endpoints[0].GET.render(nil)
+		assert.Nil(t, err)
+		assert.Equal(t, name, string(body))
+	}
+}
+
+func TestLoadFromFileSystem(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"mocks/config.yaml": `
+include: [servers/*.yaml]
+`,
+		"mocks/servers/pictures.yaml": `
+servers:
+  - name: pictures
+    port: 4573
+    endpoints:
+      - url: /{name}
+        GET:
+          file: file://../pictures/{{.name}}.txt
+`,
+		"mocks/pictures/cat.txt": "meow",
+	})
+	defer os.RemoveAll(dir)
+
+	loader := NewLoader(LoaderOptions{FileSystem: http.Dir(dir), BaseDir: "/mocks"})
+	serverCollection, err := loader.Load("config.yaml")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/mocks", serverCollection.BaseDir)
+
+	body, err := serverCollection.Servers[0].Endpoints[0].GET.render(map[string]string{"name": "cat"})
+	assert.Nil(t, err)
+	assert.Equal(t, "meow", string(body))
+
+	router := serverCollection.Servers[0].createRouter(func(RequestLog) {})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/cat", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "meow", w.Body.String())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/dog", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestStrictLoad(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": `
+servers:
+  - name: server_1
+    port: 4573
+    endpoints: []
+  - name: server_1
+    port: 4574
+    unknown: value
+    endpoints: []
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	configPath := filepath.Join(dir, "config.yaml")
+
+	serverCollection, err := NewLoader(LoaderOptions{}).Load(configPath)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(serverCollection.Servers))
+
+	_, err = NewLoader(LoaderOptions{Strict: true}).Load(configPath)
+	assert.NotNil(t, err)
+	assert.Equal(t, "servers.1: Additional property unknown is not allowed\n", err.Error())
+}
+
+// memoryFileSystem keeps files in memory by their absolute paths
+type memoryFileSystem map[string]string
+
+type memoryFile struct {
+	*bytes.Reader
+	name string
+}
+
+func (fs memoryFileSystem) Open(name string) (http.File, error) {
+	data, ok := fs[path.Clean(name)]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return memoryFile{bytes.NewReader([]byte(data)), name}, nil
+}
+
+func (file memoryFile) Close() error                             { return nil }
+func (file memoryFile) Readdir(count int) ([]os.FileInfo, error) { return nil, nil }
+func (file memoryFile) Stat() (os.FileInfo, error)               { return file, nil }
+func (file memoryFile) Name() string                             { return path.Base(file.name) }
+func (file memoryFile) Mode() os.FileMode                        { return 0444 }
+func (file memoryFile) ModTime() time.Time                       { return time.Time{} }
+func (file memoryFile) IsDir() bool                              { return false }
+func (file memoryFile) Sys() interface{}                         { return nil }
+
+func TestLoadFromMemoryFileSystem(t *testing.T) {
+	fs := memoryFileSystem{
+		"/mocks/config.yaml": `
+servers:
+  - name: server_1
+    port: 4573
+    endpoints:
+      - url: /template
+        GET:
+          template: file://response.txt
+      - url: /generated
+        GET:
+          schema: file://schema.yaml
+      - url: /graphql
+        graphql:
+          schema: file://schema.graphql
+`,
+		"/mocks/response.txt":   "from memory",
+		"/mocks/schema.yaml":    "type: string\nenum: [generated]",
+		"/mocks/schema.graphql": "type Query { hello: String }",
+	}
+
+	serverCollection, err := NewLoader(LoaderOptions{FileSystem: fs, BaseDir: "/mocks"}).Load("config.yaml")
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	endpoints := serverCollection.Servers[0].Endpoints
+
+	body, err := endpoints[0].GET.render(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "from memory", string(body))
+
+	body, err = endpoints[1].GET.render(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, `"generated"`, string(body))
+
+	assert.NotNil(t, endpoints[2].GraphQL.schema.types["Query"].fields["hello"])
+
+	_, err = NewLoader(LoaderOptions{FileSystem: fs, BaseDir: "/"}).Load("config.yaml")
+	assert.True(t, os.IsNotExist(err))
+}
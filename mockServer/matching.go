@@ -0,0 +1,267 @@
+package mockServer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// wildcardVar is a name of the variable, which holds the rest of the path matched by a trailing wildcard
+const wildcardVar = "wildcard"
+
+// defaultHostVarPattern is used for host variables without an explicit pattern like {subdomain}
+const defaultHostVarPattern = "[^.]+"
+
+type varsContextKey struct{}
+
+type paramsContextKey struct{}
+
+// URLRegexp is a regular expression, which should match the whole path of a request
+type URLRegexp struct {
+	source string
+	regexp *regexp.Regexp
+}
+
+// UnmarshalJSON compiles the regular expression
+func (urlRegexp *URLRegexp) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &urlRegexp.source); err != nil {
+		return err
+	}
+
+	compiled, err := regexp.Compile("^(?:" + urlRegexp.source + ")$")
+	if err != nil {
+		return fmt.Errorf("invalid regex %q: %s", urlRegexp.source, err)
+	}
+	urlRegexp.regexp = compiled
+
+	return nil
+}
+
+func (urlRegexp URLRegexp) String() string {
+	return urlRegexp.source
+}
+
+// HostPattern matches a host of a request. It accepts {name} and {name:pattern} variables like URLs
+type HostPattern struct {
+	source string
+	regexp *regexp.Regexp
+}
+
+// UnmarshalJSON converts the host template into a regular expression
+func (hostPattern *HostPattern) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &hostPattern.source); err != nil {
+		return err
+	}
+
+	expression := ""
+	depth := 0
+	start := 0
+	for i, char := range hostPattern.source {
+		switch {
+		case char == '{':
+			if depth == 0 {
+				start = i + 1
+			}
+			depth++
+		case char == '}':
+			depth--
+			if depth == 0 {
+				parts := strings.SplitN(hostPattern.source[start:i], ":", 2)
+				pattern := defaultHostVarPattern
+				if len(parts) == 2 {
+					pattern = parts[1]
+				}
+				expression += fmt.Sprintf("(?P<%s>%s)", parts[0], pattern)
+			}
+		case depth == 0:
+			expression += regexp.QuoteMeta(string(char))
+		}
+	}
+
+	if depth != 0 {
+		return fmt.Errorf("unbalanced braces in host %q", hostPattern.source)
+	}
+
+	compiled, err := regexp.Compile("^(?i:" + expression + ")$")
+	if err != nil {
+		return fmt.Errorf("invalid host %q: %s", hostPattern.source, err)
+	}
+	hostPattern.regexp = compiled
+
+	return nil
+}
+
+func (hostPattern HostPattern) String() string {
+	return hostPattern.source
+}
+
+// requestHost returns a host of the request without a port
+func requestHost(req *http.Request) string {
+	host := req.Host
+	if req.URL.IsAbs() {
+		host = req.URL.Host
+	}
+
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		return hostname
+	}
+	return host
+}
+
+// requestScheme returns a scheme of the request. Requests coming through proxies
+// are recognized by the X-Forwarded-Proto header
+func requestScheme(req *http.Request) string {
+	if proto := req.Header.Get("X-Forwarded-Proto"); proto != "" {
+		return strings.ToLower(proto)
+	}
+	if req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// submatchVars puts named groups of the regexp matched in the string into vars
+func submatchVars(re *regexp.Regexp, value string, vars map[string]string) bool {
+	match := re.FindStringSubmatch(value)
+	if match == nil {
+		return false
+	}
+
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			vars[name] = match[i]
+		}
+	}
+
+	return true
+}
+
+// requestVars returns params of the config and variables of the request extracted from its path and host.
+// Variables of the request take precedence over params with the same names
+func requestVars(req *http.Request) map[string]string {
+	vars := make(map[string]string)
+	if params, ok := req.Context().Value(paramsContextKey{}).(map[string]string); ok {
+		for name, value := range params {
+			vars[name] = value
+		}
+	}
+	for name, value := range mux.Vars(req) {
+		vars[name] = value
+	}
+	if extracted, ok := req.Context().Value(varsContextKey{}).(map[string]string); ok {
+		for name, value := range extracted {
+			vars[name] = value
+		}
+	}
+
+	return vars
+}
+
+// pathTemplate returns a gorilla template for the URL of the endpoint.
+// A trailing wildcard matches the rest of the path
+func (endpoint Endpoint) pathTemplate() string {
+	if strings.HasSuffix(endpoint.URL, "*") {
+		return strings.TrimSuffix(endpoint.URL, "*") + "{" + wildcardVar + ":.*}"
+	}
+	return endpoint.URL
+}
+
+// varNames returns names of all variables, which the endpoint passes to templates
+func (endpoint Endpoint) varNames() []string {
+	var names []string
+	if endpoint.Host != nil {
+		names = append(names, endpoint.Host.regexp.SubexpNames()...)
+	}
+	if endpoint.Regex != nil {
+		names = append(names, endpoint.Regex.regexp.SubexpNames()...)
+	} else {
+		names = append(names, urlVars(endpoint.pathTemplate())...)
+	}
+
+	var result []string
+	for _, name := range names {
+		if name != "" {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// Pattern describes the endpoint in messages
+func (endpoint Endpoint) Pattern() string {
+	pattern := endpoint.URL
+	if endpoint.Regex != nil {
+		pattern = endpoint.Regex.String()
+	}
+	if endpoint.Host != nil {
+		pattern = endpoint.Host.String() + pattern
+	}
+	return pattern
+}
+
+// matches checks the parts of the request, which gorilla can't check by itself
+func (endpoint Endpoint) matches(req *http.Request, match *mux.RouteMatch) bool {
+	if endpoint.Regex != nil && !endpoint.Regex.regexp.MatchString(req.URL.Path) {
+		return false
+	}
+	if endpoint.Host != nil && !endpoint.Host.regexp.MatchString(requestHost(req)) {
+		return false
+	}
+	if len(endpoint.Schemes) > 0 {
+		scheme := requestScheme(req)
+		for _, allowed := range endpoint.Schemes {
+			if strings.ToLower(allowed) == scheme {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// withVars passes variables from the host and the regex of the endpoint to the handler
+func (endpoint Endpoint) withVars(handler httpHandler) httpHandler {
+	if endpoint.Regex == nil && endpoint.Host == nil {
+		return handler
+	}
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := make(map[string]string)
+		if endpoint.Host != nil {
+			submatchVars(endpoint.Host.regexp, requestHost(req), vars)
+		}
+		if endpoint.Regex != nil {
+			submatchVars(endpoint.Regex.regexp, req.URL.Path, vars)
+		}
+
+		handler(w, req.WithContext(context.WithValue(req.Context(), varsContextKey{}, vars)))
+	}
+}
+
+// register adds a route of the endpoint to the router
+func (endpoint Endpoint) register(router *mux.Router, handler httpHandler) {
+	route := router.NewRoute()
+	if endpoint.Regex == nil {
+		route = route.Path(endpoint.pathTemplate())
+	}
+	route.MatcherFunc(endpoint.matches).HandlerFunc(endpoint.withVars(handler))
+}
+
+// sortByPriority orders endpoints so the ones with higher priority are matched first.
+// Endpoints with equal priorities keep the order of the config
+func sortByPriority(endpoints []Endpoint) []Endpoint {
+	sorted := make([]Endpoint, len(endpoints))
+	copy(sorted, endpoints)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority > sorted[j].Priority
+	})
+	return sorted
+}
@@ -0,0 +1,144 @@
+package mockServer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func serveRequest(t *testing.T, config string, req *http.Request) *httptest.ResponseRecorder {
+	var mockServer MockServer
+	err := yaml.Unmarshal([]byte(config), &mockServer)
+	assert.Nil(t, err)
+
+	w := httptest.NewRecorder()
+	mockServer.createRouter(func(RequestLog) {}).ServeHTTP(w, req)
+	return w
+}
+
+func TestRegexEndpoint(t *testing.T) {
+	config := `
+name: server_1
+port: 4573
+endpoints:
+  - regex: /users/(?P<id>[0-9]+)(/(?P<tab>posts|comments))?
+    GET:
+      template: "user {{.id}} {{.tab}}"
+`
+	w := serveRequest(t, config, httptest.NewRequest("GET", "/users/42/posts", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "user 42 posts", w.Body.String())
+
+	w = serveRequest(t, config, httptest.NewRequest("GET", "/users/42", nil))
+	assert.Equal(t, "user 42 ", w.Body.String())
+
+	w = serveRequest(t, config, httptest.NewRequest("GET", "/users/abc", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serveRequest(t, config, httptest.NewRequest("GET", "/prefix/users/42", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestWildcardEndpoint(t *testing.T) {
+	config := `
+name: server_1
+port: 4573
+endpoints:
+  - url: /static/{version}/*
+    GET:
+      template: "{{.version}}: {{.wildcard}}"
+`
+	w := serveRequest(t, config, httptest.NewRequest("GET", "/static/v1/css/main.css", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "v1: css/main.css", w.Body.String())
+
+	w = serveRequest(t, config, httptest.NewRequest("GET", "/static/v1/", nil))
+	assert.Equal(t, "v1: ", w.Body.String())
+}
+
+func TestHostEndpoints(t *testing.T) {
+	config := `
+name: server_1
+port: 4573
+endpoints:
+  - url: /users
+    host: api.example.com
+    GET:
+      template: "api"
+  - url: /users
+    host: "{tenant}.example.com"
+    GET:
+      template: "tenant {{.tenant}}"
+`
+	req := httptest.NewRequest("GET", "/users", nil)
+	req.Host = "api.example.com:4573"
+	w := serveRequest(t, config, req)
+	assert.Equal(t, "api", w.Body.String())
+
+	req = httptest.NewRequest("GET", "/users", nil)
+	req.Host = "Acme.example.com"
+	w = serveRequest(t, config, req)
+	assert.Equal(t, "tenant Acme", w.Body.String())
+
+	req = httptest.NewRequest("GET", "/users", nil)
+	req.Host = "localhost:4573"
+	w = serveRequest(t, config, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestSchemeEndpoints(t *testing.T) {
+	config := `
+name: server_1
+port: 4573
+endpoints:
+  - url: /login
+    schemes: [https]
+    GET:
+      template: "secure"
+  - url: /login
+    GET:
+      template: "insecure"
+`
+	w := serveRequest(t, config, httptest.NewRequest("GET", "/login", nil))
+	assert.Equal(t, "insecure", w.Body.String())
+
+	req := httptest.NewRequest("GET", "/login", nil)
+	req.Header.Set("X-Forwarded-Proto", "HTTPS")
+	w = serveRequest(t, config, req)
+	assert.Equal(t, "secure", w.Body.String())
+}
+
+func TestEndpointsPriority(t *testing.T) {
+	config := `
+name: server_1
+port: 4573
+endpoints:
+  - url: /users/*
+    GET:
+      template: "any user"
+  - url: /users/{id:[0-9]+}
+    priority: 1
+    GET:
+      template: "user {{.id}}"
+  - regex: /users/(?P<id>[0-9]+)
+    priority: 1
+    GET:
+      template: "shadowed"
+`
+	w := serveRequest(t, config, httptest.NewRequest("GET", "/users/42", nil))
+	assert.Equal(t, "user 42", w.Body.String())
+
+	w = serveRequest(t, config, httptest.NewRequest("GET", "/users/me", nil))
+	assert.Equal(t, "any user", w.Body.String())
+}
+
+func TestInvalidRegexEndpoint(t *testing.T) {
+	var endpoint Endpoint
+	err := yaml.Unmarshal([]byte("regex: /users/(?P<id>[0-9]+"), &endpoint)
+
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `invalid regex "/users/(?P<id>[0-9]+"`)
+}
@@ -2,71 +2,284 @@ package mockServer
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
-	"os"
-	"os/signal"
 	"strconv"
-	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
 )
 
+// RequestLog contains the information about a handled request, which is passed to the RequestLogWriter
+type RequestLog struct {
+	ServerName       string
+	URL              string
+	Method           string
+	StatusCode       int
+	ValidationErrors []string
+	Operation        string
+	Unmatched        bool
+	// Endpoint is the pattern of the matched endpoint. It's empty for unmatched requests
+	Endpoint string
+	// Duration is the time spent from receiving the request to sending the response
+	Duration time.Duration
+
+	// fields below are set for requests handled by mock servers
+	Time       time.Time
+	RemoteAddr string
+	Proto      string
+	UserAgent  string
+	Referer    string
+	Header     http.Header
+	// Bytes is the size of the response body
+	Bytes          int64
+	ResponseHeader http.Header
+	// ResponseBody is the beginning of the response body up to ResponseBodyLogLimit bytes
+	ResponseBody []byte
+}
+
+// ResponseBodyLogLimit is a number of first bytes of response bodies kept in request logs
+const ResponseBodyLogLimit = 4096
+
 // RequestLogWriter is signature of method, wich should be passed to the mock server to write requests log
-type RequestLogWriter func(serverName, URL, method string, statusCode int)
+type RequestLogWriter func(requestLog RequestLog)
+
+// Middleware wraps the handler of a mock server
+type Middleware func(handler http.Handler) http.Handler
+
+type requestRecordKey struct{}
+
+// requestRecord holds the log of the request until its response is sent
+type requestRecord struct {
+	start time.Time
+	log   *RequestLog
+}
+
+// logRequest writes the log of the request. Requests handled by mock servers are logged after their responses are sent
+func logRequest(req *http.Request, logWriter RequestLogWriter, requestLog RequestLog) {
+	if record, ok := req.Context().Value(requestRecordKey{}).(*requestRecord); ok {
+		record.log = &requestLog
+		return
+	}
+	logWriter(requestLog)
+}
+
+// countingResponseWriter counts bytes of the response body and keeps its beginning
+type countingResponseWriter struct {
+	http.ResponseWriter
+	bytes int64
+	body  []byte
+}
+
+func (w *countingResponseWriter) Write(data []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(data)
+	w.bytes += int64(n)
+	if rest := ResponseBodyLogLimit - len(w.body); rest > 0 {
+		if rest > n {
+			rest = n
+		}
+		w.body = append(w.body, data[:rest]...)
+	}
+	return n, err
+}
+
+// withRequestLog measures handling of requests by the endpoint and writes their logs
+func withRequestLog(handler httpHandler, logWriter RequestLogWriter, pattern string) httpHandler {
+	return func(w http.ResponseWriter, req *http.Request) {
+		record := &requestRecord{start: time.Now()}
+		counter := &countingResponseWriter{ResponseWriter: w}
+		handler(counter, req.WithContext(context.WithValue(req.Context(), requestRecordKey{}, record)))
 
-// MockServer represents a standalone mock server with its name, port and collection of endpoints
+		if record.log != nil {
+			record.log.Endpoint = pattern
+			record.log.Duration = time.Since(record.start)
+			record.log.Time = record.start
+			record.log.RemoteAddr = req.RemoteAddr
+			record.log.Proto = req.Proto
+			record.log.UserAgent = req.UserAgent()
+			record.log.Referer = req.Referer()
+			record.log.Header = req.Header
+			record.log.Bytes = counter.bytes
+			record.log.ResponseHeader = w.Header()
+			record.log.ResponseBody = counter.body
+			logWriter(*record.log)
+		}
+	}
+}
+
+// Override is a response, which replaces the configured response of an endpoint
+type Override struct {
+	StatusCode int
+	Headers    http.Header
+	Body       []byte
+}
+
+// OverrideFinder returns the override of the endpoint of the server for the request or nil
+type OverrideFinder func(req *http.Request, serverName string, endpoint string) *Override
+
+// MockServer represents a standalone mock server with its name, port and collection of endpoints.
+// It listens on the Bind address or on all interfaces, if Bind is empty
 type MockServer struct {
-	Name      string     `json:"name"`
-	Port      int        `json:"port"`
-	Endpoints []Endpoint `json:"endpoints"`
+	Name            string       `json:"name"`
+	Port            int          `json:"port"`
+	Bind            string       `json:"bind"`
+	OpenAPI         *OpenAPISpec `json:"openapi"`
+	DefaultResponse *Response    `json:"default_response"`
+	Endpoints       []Endpoint   `json:"endpoints"`
+
+	params    map[string]string
+	overrides OverrideFinder
 }
 
-func (mockServer MockServer) startHTTPServer(logWriter RequestLogWriter) *http.Server {
-	router := mux.NewRouter()
+// WithOverrides returns a copy of the server, which looks for overrides of its endpoints before responding
+func (mockServer MockServer) WithOverrides(finder OverrideFinder) MockServer {
+	mockServer.overrides = finder
+	return mockServer
+}
+
+// withOverride writes the override of the endpoint instead of the configured response, if there is one
+func (mockServer MockServer) withOverride(handler httpHandler, logWriter RequestLogWriter, pattern string) httpHandler {
+	if mockServer.overrides == nil {
+		return handler
+	}
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		override := mockServer.overrides(req, mockServer.Name, pattern)
+		if override == nil {
+			handler(w, req)
+			return
+		}
+
+		logRequest(req, logWriter, RequestLog{
+			ServerName: mockServer.Name,
+			URL:        req.URL.String(),
+			Method:     req.Method,
+			StatusCode: override.StatusCode,
+		})
+
+		for header, values := range override.Headers {
+			w.Header()[header] = values
+		}
+		w.WriteHeader(override.StatusCode)
+		w.Write(override.Body)
+	}
+}
 
+// setFileSystem makes responses read files from the file system of the loader
+func (mockServer MockServer) setFileSystem(fs http.FileSystem) {
+	responses := []*Response{mockServer.DefaultResponse}
 	for _, endpoint := range mockServer.Endpoints {
-		router.HandleFunc(endpoint.URL, endpoint.GetHandler(logWriter, mockServer.Name))
+		for _, method := range endpointMethods {
+			responses = append(responses, endpoint.getResponse(method))
+		}
 	}
 
-	srv := &http.Server{
-		Addr:           ":" + strconv.Itoa(mockServer.Port),
-		Handler:        router,
-		ReadTimeout:    10 * time.Second,
-		WriteTimeout:   10 * time.Second,
-		MaxHeaderBytes: 1 << 20,
+	for _, response := range responses {
+		if response != nil {
+			response.fs = fs
+		}
 	}
+}
 
-	go func() {
-		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
-			// cannot panic, because this probably is an intentional close
-			log.Printf("Httpserver: ListenAndServe() error: %s", err)
+// withParams passes params of the config to the handler
+func (mockServer MockServer) withParams(handler httpHandler) httpHandler {
+	if len(mockServer.params) == 0 {
+		return handler
+	}
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		handler(w, req.WithContext(context.WithValue(req.Context(), paramsContextKey{}, mockServer.params)))
+	}
+}
+
+// notFoundHandler handles requests, which don't match any endpoint
+func (mockServer MockServer) notFoundHandler(logWriter RequestLogWriter) httpHandler {
+	return func(w http.ResponseWriter, req *http.Request) {
+		requestLog := RequestLog{ServerName: mockServer.Name, URL: req.URL.String(), Method: req.Method, Unmatched: true}
+
+		if mockServer.DefaultResponse != nil {
+			requestLog.StatusCode = mockServer.DefaultResponse.StatusCode
+			logRequest(req, logWriter, requestLog)
+			mockServer.DefaultResponse.WriteResponse(w, req)
+		} else {
+			requestLog.StatusCode = http.StatusNotFound
+			logRequest(req, logWriter, requestLog)
+			http.NotFound(w, req)
 		}
-	}()
-	return srv
+	}
 }
 
-// Serve method starts the server and does some operations after it stops
-func (mockServer MockServer) Serve(logWriter RequestLogWriter, wg *sync.WaitGroup) {
-	log.Printf("[%s] Starting...", mockServer.Name)
-	interrupt := make(chan os.Signal, 1)
-	signal.Notify(interrupt, os.Interrupt)
-	defer close(interrupt)
-	defer signal.Stop(interrupt)
+func (mockServer MockServer) createRouter(logWriter RequestLogWriter) *mux.Router {
+	router := mux.NewRouter()
 
-	srv := mockServer.startHTTPServer(logWriter)
-	<-interrupt
+	for _, endpoint := range sortByPriority(mockServer.Endpoints) {
+		handler := mockServer.withOverride(endpoint.GetHandler(logWriter, mockServer.Name), logWriter, endpoint.Pattern())
+		if mockServer.OpenAPI != nil {
+			handler = mockServer.OpenAPI.wrapHandler(handler, logWriter, mockServer.Name)
+		}
+		handler = withRequestLog(handler, logWriter, endpoint.Pattern())
+		endpoint.register(router, mockServer.withParams(handler))
+	}
 
-	log.Printf("[%s] Stopping...", mockServer.Name)
+	notFoundHandler := withRequestLog(mockServer.notFoundHandler(logWriter), logWriter, "")
+	router.NotFoundHandler = http.HandlerFunc(mockServer.withParams(notFoundHandler))
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Printf("[%s] Shutdown error: %s", mockServer.Name, err)
+	return router
+}
+
+// RunningServer is a started mock server
+type RunningServer struct {
+	Name string
+	// Addr is an address the server is bound to. It contains the actual port if the port 0 was configured
+	Addr net.Addr
+	srv  *http.Server
+}
+
+// Start binds the port of the server and serves requests in background until Shutdown is called.
+// Middlewares are applied in the passed order, so the first one is the outermost
+func (mockServer MockServer) Start(logWriter RequestLogWriter, middlewares ...Middleware) (*RunningServer, error) {
+	listener, err := net.Listen("tcp", net.JoinHostPort(mockServer.Bind, strconv.Itoa(mockServer.Port)))
+	if err != nil {
+		return nil, fmt.Errorf("[%s] cannot bind port %d: %s", mockServer.Name, mockServer.Port, err)
 	}
 
-	log.Printf("[%s] Stopped", mockServer.Name)
+	var handler http.Handler = mockServer.createRouter(logWriter)
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	running := &RunningServer{
+		Name: mockServer.Name,
+		Addr: listener.Addr(),
+		srv: &http.Server{
+			Handler:        handler,
+			ReadTimeout:    10 * time.Second,
+			WriteTimeout:   10 * time.Second,
+			MaxHeaderBytes: 1 << 20,
+		},
+	}
+
+	log.Printf("[%s] Listening on %s", mockServer.Name, running.Addr)
+
+	go func() {
+		if err := running.srv.Serve(listener); err != http.ErrServerClosed {
+			// cannot panic, because this probably is an intentional close
+			log.Printf("[%s] Serve error: %s", mockServer.Name, err)
+		}
+	}()
+
+	return running, nil
+}
+
+// Shutdown stops the server gracefully, waiting for active requests until the context is done
+func (running *RunningServer) Shutdown(ctx context.Context) error {
+	log.Printf("[%s] Stopping...", running.Name)
+	if err := running.srv.Shutdown(ctx); err != nil {
+		return err
+	}
 
-	wg.Done()
+	log.Printf("[%s] Stopped", running.Name)
+	return nil
 }
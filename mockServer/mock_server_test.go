@@ -0,0 +1,191 @@
+package mockServer
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnmatchedRequestWithoutDefaultResponse(t *testing.T) {
+	config := `
+name: server_1
+port: 4573
+endpoints:
+  - url: /simple/url
+    GET:
+      template: "ok"
+`
+	var mockServer MockServer
+	err := yaml.Unmarshal([]byte(config), &mockServer)
+	assert.Nil(t, err)
+
+	var logs []RequestLog
+	router := mockServer.createRouter(func(requestLog RequestLog) {
+		assert.True(t, requestLog.Duration > 0)
+		assert.False(t, requestLog.Time.IsZero())
+		requestLog.Duration = 0
+		requestLog.Time = time.Time{}
+		logs = append(logs, requestLog)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/unknown/url?a=1", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, []RequestLog{{
+		ServerName: "server_1",
+		URL:        "/unknown/url?a=1",
+		Method:     "GET",
+		StatusCode: http.StatusNotFound,
+		Unmatched:  true,
+		RemoteAddr: "192.0.2.1:1234",
+		Proto:      "HTTP/1.1",
+		Header:     http.Header{},
+		Bytes:      int64(len("404 page not found\n")),
+
+		ResponseHeader: w.Header(),
+		ResponseBody:   []byte("404 page not found\n"),
+	}}, logs)
+}
+
+func TestUnmatchedRequestWithDefaultResponse(t *testing.T) {
+	config := `
+name: server_1
+port: 4573
+default_response:
+  template: "{\"error\": \"not mocked\"}"
+  status_code: 501
+  headers:
+    content-type: application/json
+endpoints:
+  - url: /simple/url
+    GET:
+      template: "ok"
+`
+	var mockServer MockServer
+	err := yaml.Unmarshal([]byte(config), &mockServer)
+	assert.Nil(t, err)
+
+	var logs []RequestLog
+	router := mockServer.createRouter(func(requestLog RequestLog) {
+		assert.True(t, requestLog.Duration > 0)
+		assert.False(t, requestLog.Time.IsZero())
+		requestLog.Duration = 0
+		requestLog.Time = time.Time{}
+		logs = append(logs, requestLog)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/unknown/url", nil))
+
+	resp := w.Result()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, 501, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.Equal(t, `{"error": "not mocked"}`, string(body))
+	assert.Equal(t, []RequestLog{{
+		ServerName: "server_1",
+		URL:        "/unknown/url",
+		Method:     "POST",
+		StatusCode: 501,
+		Unmatched:  true,
+		RemoteAddr: "192.0.2.1:1234",
+		Proto:      "HTTP/1.1",
+		Header:     http.Header{},
+		Bytes:      int64(len(body)),
+
+		ResponseHeader: http.Header{"Content-Type": {"application/json"}},
+		ResponseBody:   body,
+	}}, logs)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/simple/url", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "ok", w.Body.String())
+	assert.False(t, logs[1].Unmatched)
+	assert.Equal(t, "/simple/url", logs[1].Endpoint)
+}
+
+func TestRequestLogKeepsBeginningOfResponseBody(t *testing.T) {
+	config := `
+name: server_1
+port: 4573
+endpoints:
+  - url: /large
+    GET:
+      template: "` + strings.Repeat("0123456789", 500) + `"
+`
+	var mockServer MockServer
+	err := yaml.Unmarshal([]byte(config), &mockServer)
+	assert.Nil(t, err)
+
+	var logs []RequestLog
+	router := mockServer.createRouter(func(requestLog RequestLog) {
+		logs = append(logs, requestLog)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/large", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, logs, 1)
+	assert.Equal(t, int64(w.Body.Len()), logs[0].Bytes)
+	assert.Equal(t, w.Body.Bytes()[:ResponseBodyLogLimit], logs[0].ResponseBody)
+}
+
+func TestOverriddenEndpoint(t *testing.T) {
+	config := `
+name: server_1
+port: 4573
+endpoints:
+  - url: /users/{id}
+    GET:
+      template: "ok"
+    POST:
+      template: "created"
+`
+	var mockServer MockServer
+	err := yaml.Unmarshal([]byte(config), &mockServer)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"GET", "POST"}, mockServer.Endpoints[0].Methods())
+
+	mockServer = mockServer.WithOverrides(func(req *http.Request, serverName string, endpoint string) *Override {
+		if serverName != "server_1" || endpoint != "/users/{id}" || req.Method != "GET" {
+			return nil
+		}
+		return &Override{
+			StatusCode: http.StatusServiceUnavailable,
+			Headers:    http.Header{"Content-Type": {"application/json"}},
+			Body:       []byte(`{"error": "down"}`),
+		}
+	})
+
+	var logs []RequestLog
+	router := mockServer.createRouter(func(requestLog RequestLog) {
+		logs = append(logs, requestLog)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/users/1", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, `{"error": "down"}`, w.Body.String())
+	assert.Equal(t, http.StatusServiceUnavailable, logs[0].StatusCode)
+	assert.Equal(t, "/users/{id}", logs[0].Endpoint)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/users/1", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "created", w.Body.String())
+}
@@ -0,0 +1,631 @@
+package mockServer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var openAPIMethods = [...]string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+var pathParamRegexp = regexp.MustCompile(`{([^}]+)}`)
+
+// OpenAPISpec represents an OpenAPI 3 document, which describes the contract of a mock server.
+// Incoming requests are validated against it before the mocked response is sent
+type OpenAPISpec struct {
+	document   map[string]interface{}
+	operations []*openAPIOperation
+}
+
+type openAPIOperation struct {
+	method     string
+	path       string
+	pathRegexp *regexp.Regexp
+	pathParams []string
+	parameters []openAPIParameter
+	body       *openAPIRequestBody
+	responses  map[string]*openAPIResponse
+}
+
+type openAPIParameter struct {
+	name     string
+	in       string
+	required bool
+	schema   map[string]interface{}
+	compiled *gojsonschema.Schema
+}
+
+type openAPIRequestBody struct {
+	required bool
+	content  map[string]*gojsonschema.Schema
+}
+
+type openAPIResponse struct {
+	headers map[string]openAPIParameter
+	content map[string]*gojsonschema.Schema
+}
+
+// UnmarshalJSON used by json lib. Loads the spec from the file, which is passed in the config.
+// Loaders pass the content of the file instead
+func (spec *OpenAPISpec) UnmarshalJSON(data []byte) error {
+	var filePath string
+	err := json.Unmarshal(data, &filePath)
+	if err != nil {
+		parsedSpec, err := parseOpenAPISpec(data)
+		if err != nil {
+			return fmt.Errorf("Cannot load OpenAPI spec: %s", err)
+		}
+
+		*spec = *parsedSpec
+		return nil
+	}
+
+	specData, err := readReference(filePath)
+	if err != nil {
+		return err
+	}
+
+	parsedSpec, err := parseOpenAPISpec(specData)
+	if err != nil {
+		return fmt.Errorf("Cannot load OpenAPI spec %s: %s", referencePath(filePath), err)
+	}
+
+	*spec = *parsedSpec
+	return nil
+}
+
+func parseOpenAPISpec(data []byte) (*OpenAPISpec, error) {
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var document map[string]interface{}
+	if err = json.Unmarshal(jsonData, &document); err != nil {
+		return nil, err
+	}
+
+	if _, ok := document["openapi"]; !ok {
+		return nil, errors.New("only OpenAPI 3 documents are supported")
+	}
+
+	normalizeNullable(document)
+
+	spec := &OpenAPISpec{document: document}
+
+	paths, _ := document["paths"].(map[string]interface{})
+	for path, value := range paths {
+		pathItem, ok := spec.resolve(value).(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("path %s is not an object", path)
+		}
+
+		for _, method := range openAPIMethods {
+			operation, ok := pathItem[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			parsedOperation, err := spec.parseOperation(path, strings.ToUpper(method), pathItem, operation)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %s", strings.ToUpper(method), path, err)
+			}
+
+			spec.operations = append(spec.operations, parsedOperation)
+		}
+	}
+
+	// concrete paths must be matched before templated ones
+	sort.SliceStable(spec.operations, func(i, j int) bool {
+		left, right := spec.operations[i], spec.operations[j]
+		if len(left.pathParams) != len(right.pathParams) {
+			return len(left.pathParams) < len(right.pathParams)
+		}
+		return left.path < right.path
+	})
+
+	return spec, nil
+}
+
+func (spec *OpenAPISpec) parseOperation(path, method string, pathItem, operation map[string]interface{}) (*openAPIOperation, error) {
+	parsedOperation := &openAPIOperation{method: method, path: path}
+
+	pattern := "^"
+	lastIndex := 0
+	for _, match := range pathParamRegexp.FindAllStringSubmatchIndex(path, -1) {
+		pattern += regexp.QuoteMeta(path[lastIndex:match[0]]) + "([^/]+)"
+		parsedOperation.pathParams = append(parsedOperation.pathParams, path[match[2]:match[3]])
+		lastIndex = match[1]
+	}
+	pattern += regexp.QuoteMeta(path[lastIndex:]) + "$"
+
+	var err error
+	if parsedOperation.pathRegexp, err = regexp.Compile(pattern); err != nil {
+		return nil, err
+	}
+
+	// operation level parameters override path level ones with the same name and location
+	parameters := make(map[string]openAPIParameter)
+	var order []string
+	for _, source := range []interface{}{pathItem["parameters"], operation["parameters"]} {
+		list, _ := source.([]interface{})
+		for _, item := range list {
+			parameter, err := spec.parseParameter(item)
+			if err != nil {
+				return nil, err
+			}
+
+			key := parameter.in + ":" + parameter.name
+			if _, ok := parameters[key]; !ok {
+				order = append(order, key)
+			}
+			parameters[key] = parameter
+		}
+	}
+	for _, key := range order {
+		parsedOperation.parameters = append(parsedOperation.parameters, parameters[key])
+	}
+
+	if requestBody, ok := spec.resolve(operation["requestBody"]).(map[string]interface{}); ok {
+		parsedOperation.body = &openAPIRequestBody{}
+		parsedOperation.body.required, _ = requestBody["required"].(bool)
+
+		if parsedOperation.body.content, err = spec.parseContent(requestBody["content"]); err != nil {
+			return nil, fmt.Errorf("request body %s", err)
+		}
+	}
+
+	parsedOperation.responses = make(map[string]*openAPIResponse)
+	responses, _ := operation["responses"].(map[string]interface{})
+	for statusCode, value := range responses {
+		response, ok := spec.resolve(value).(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("response %s is not an object", statusCode)
+		}
+
+		parsedResponse := &openAPIResponse{headers: make(map[string]openAPIParameter)}
+		if parsedResponse.content, err = spec.parseContent(response["content"]); err != nil {
+			return nil, fmt.Errorf("response %s %s", statusCode, err)
+		}
+
+		headers, _ := response["headers"].(map[string]interface{})
+		for name, header := range headers {
+			headerObject, ok := spec.resolve(header).(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("response %s: header %s is not an object", statusCode, name)
+			}
+
+			// header objects are parameters without a name and a location
+			definition := map[string]interface{}{"name": name, "in": "header"}
+			for key, value := range headerObject {
+				definition[key] = value
+			}
+
+			if parsedResponse.headers[name], err = spec.parseParameter(definition); err != nil {
+				return nil, fmt.Errorf("response %s: %s", statusCode, err)
+			}
+		}
+
+		parsedOperation.responses[strings.ToUpper(statusCode)] = parsedResponse
+	}
+
+	return parsedOperation, nil
+}
+
+func (spec *OpenAPISpec) parseContent(value interface{}) (map[string]*gojsonschema.Schema, error) {
+	content := make(map[string]*gojsonschema.Schema)
+
+	mediaTypes, _ := value.(map[string]interface{})
+	for mediaType, value := range mediaTypes {
+		mediaTypeObject, _ := value.(map[string]interface{})
+
+		var compiled *gojsonschema.Schema
+		if schema, ok := mediaTypeObject["schema"]; ok {
+			var err error
+			if compiled, err = spec.compileSchema(schema); err != nil {
+				return nil, fmt.Errorf("%s: %s", mediaType, err)
+			}
+		}
+		content[strings.ToLower(mediaType)] = compiled
+	}
+
+	return content, nil
+}
+
+// findSchema returns a schema of the media type, taking into account wildcards like image/*
+func findSchema(content map[string]*gojsonschema.Schema, mediaType string) (*gojsonschema.Schema, bool) {
+	if schema, ok := content[mediaType]; ok {
+		return schema, true
+	}
+	if schema, ok := content[strings.Split(mediaType, "/")[0]+"/*"]; ok {
+		return schema, true
+	}
+	schema, ok := content["*/*"]
+	return schema, ok
+}
+
+func (spec *OpenAPISpec) parseParameter(value interface{}) (openAPIParameter, error) {
+	var parameter openAPIParameter
+
+	object, ok := spec.resolve(value).(map[string]interface{})
+	if !ok {
+		return parameter, errors.New("parameter is not an object")
+	}
+
+	parameter.name, _ = object["name"].(string)
+	parameter.in, _ = object["in"].(string)
+	parameter.required, _ = object["required"].(bool)
+
+	if parameter.name == "" || parameter.in == "" {
+		return parameter, errors.New("parameter must have a name and a location")
+	}
+
+	if schema, ok := object["schema"]; ok {
+		parameter.schema, _ = spec.resolve(schema).(map[string]interface{})
+
+		var err error
+		if parameter.compiled, err = spec.compileSchema(schema); err != nil {
+			return parameter, fmt.Errorf("parameter %s: %s", parameter.name, err)
+		}
+	}
+
+	return parameter, nil
+}
+
+// resolve follows local references like #/components/schemas/Pet
+func (spec *OpenAPISpec) resolve(value interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return value
+		}
+
+		ref, ok := object["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#/") {
+			return value
+		}
+
+		var current interface{} = spec.document
+		for _, token := range strings.Split(ref[2:], "/") {
+			token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+			container, ok := current.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			current = container[token]
+		}
+		value = current
+	}
+
+	return value
+}
+
+// compileSchema builds a JSON schema, which is able to resolve references to the components of the document
+func (spec *OpenAPISpec) compileSchema(schema interface{}) (*gojsonschema.Schema, error) {
+	wrapper := map[string]interface{}{
+		"allOf": []interface{}{schema},
+	}
+	if components, ok := spec.document["components"]; ok {
+		wrapper["components"] = components
+	}
+
+	return gojsonschema.NewSchema(gojsonschema.NewGoLoader(wrapper))
+}
+
+// normalizeNullable translates OpenAPI "nullable" keyword into JSON schema types
+func normalizeNullable(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if nullable, ok := v["nullable"].(bool); ok && nullable {
+			if schemaType, ok := v["type"].(string); ok {
+				v["type"] = []interface{}{schemaType, "null"}
+			}
+		}
+		for _, item := range v {
+			normalizeNullable(item)
+		}
+	case []interface{}:
+		for _, item := range v {
+			normalizeNullable(item)
+		}
+	}
+}
+
+func (spec *OpenAPISpec) findOperation(req *http.Request) (*openAPIOperation, []string, error) {
+	pathFound := false
+
+	for _, operation := range spec.operations {
+		match := operation.pathRegexp.FindStringSubmatch(req.URL.Path)
+		if match == nil {
+			continue
+		}
+
+		pathFound = true
+		if operation.method == req.Method {
+			return operation, match[1:], nil
+		}
+	}
+
+	if pathFound {
+		return nil, nil, fmt.Errorf("method %s is not allowed for path %s", req.Method, req.URL.Path)
+	}
+	return nil, nil, fmt.Errorf("path %s is not described in the OpenAPI spec", req.URL.Path)
+}
+
+// validate checks the request against the spec and returns a list of found problems
+func (spec *OpenAPISpec) validate(req *http.Request) []string {
+	operation, pathValues, err := spec.findOperation(req)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var validationErrors []string
+
+	for _, parameter := range operation.parameters {
+		values := parameterValues(req, operation, pathValues, parameter)
+
+		if len(values) == 0 {
+			if parameter.required {
+				validationErrors = append(
+					validationErrors, fmt.Sprintf("%s parameter %q is required", parameter.in, parameter.name),
+				)
+			}
+			continue
+		}
+
+		if parameter.compiled == nil {
+			continue
+		}
+
+		value := coerceParameter(values, parameter.schema)
+		for _, description := range validateValue(parameter.compiled, value) {
+			validationErrors = append(
+				validationErrors, fmt.Sprintf("%s parameter %q: %s", parameter.in, parameter.name, description),
+			)
+		}
+	}
+
+	if operation.body != nil {
+		validationErrors = append(validationErrors, operation.body.validate(req)...)
+	}
+
+	return validationErrors
+}
+
+func (body *openAPIRequestBody) validate(req *http.Request) []string {
+	var data []byte
+	if req.Body != nil {
+		var err error
+		data, err = ioutil.ReadAll(req.Body)
+		if err != nil {
+			return []string{fmt.Sprintf("cannot read request body: %s", err)}
+		}
+		// the body can be needed by the response later
+		req.Body = ioutil.NopCloser(bytes.NewReader(data))
+	}
+
+	if len(data) == 0 {
+		if body.required {
+			return []string{"request body is required"}
+		}
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil {
+		return []string{fmt.Sprintf("invalid content type %q", req.Header.Get("Content-Type"))}
+	}
+
+	schema, ok := findSchema(body.content, mediaType)
+	if !ok {
+		return []string{fmt.Sprintf("content type %q is not supported", mediaType)}
+	}
+
+	if schema == nil || !isJSONMediaType(mediaType) {
+		return nil
+	}
+
+	return validateBody(schema, data, "request body")
+}
+
+// checkResponse validates a mocked response against the responses of the operation, described in the spec
+func (spec *OpenAPISpec) checkResponse(method, path string, statusCode int, headers http.Header, body []byte) []string {
+	req, err := http.NewRequest(method, path, nil)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	operation, _, err := spec.findOperation(req)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	code := strconv.Itoa(statusCode)
+	response, ok := operation.responses[code]
+	if !ok {
+		response, ok = operation.responses[code[:1]+"XX"]
+	}
+	if !ok {
+		response, ok = operation.responses["DEFAULT"]
+	}
+	if !ok {
+		return []string{fmt.Sprintf("status code %d is not described in the OpenAPI spec", statusCode)}
+	}
+
+	var validationErrors []string
+
+	for name, header := range response.headers {
+		value := headers.Get(name)
+		if value == "" {
+			if header.required {
+				validationErrors = append(validationErrors, fmt.Sprintf("header %q is required", name))
+			}
+			continue
+		}
+
+		if header.compiled == nil {
+			continue
+		}
+
+		for _, description := range validateValue(header.compiled, coerceParameter([]string{value}, header.schema)) {
+			validationErrors = append(validationErrors, fmt.Sprintf("header %q: %s", name, description))
+		}
+	}
+	sort.Strings(validationErrors)
+
+	if len(body) == 0 || len(response.content) == 0 {
+		return validationErrors
+	}
+
+	mediaType, _, err := mime.ParseMediaType(headers.Get("Content-Type"))
+	if err != nil {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(body))
+	}
+
+	schema, ok := findSchema(response.content, mediaType)
+	if !ok {
+		return append(validationErrors, fmt.Sprintf("content type %q is not described in the OpenAPI spec", mediaType))
+	}
+
+	if schema == nil || !isJSONMediaType(mediaType) {
+		return validationErrors
+	}
+
+	return append(validationErrors, validateBody(schema, body, "response body")...)
+}
+
+func validateBody(schema *gojsonschema.Schema, data []byte, name string) []string {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return []string{fmt.Sprintf("%s is not a valid JSON: %s", name, err)}
+	}
+
+	var validationErrors []string
+	for _, description := range validateValue(schema, value) {
+		validationErrors = append(validationErrors, fmt.Sprintf("%s: %s", name, description))
+	}
+	return validationErrors
+}
+
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func parameterValues(req *http.Request, operation *openAPIOperation, pathValues []string, parameter openAPIParameter) []string {
+	switch parameter.in {
+	case "path":
+		for i, name := range operation.pathParams {
+			if name == parameter.name {
+				value, err := url.PathUnescape(pathValues[i])
+				if err != nil {
+					value = pathValues[i]
+				}
+				return []string{value}
+			}
+		}
+	case "query":
+		return req.URL.Query()[parameter.name]
+	case "header":
+		return req.Header[http.CanonicalHeaderKey(parameter.name)]
+	case "cookie":
+		if cookie, err := req.Cookie(parameter.name); err == nil {
+			return []string{cookie.Value}
+		}
+	}
+
+	return nil
+}
+
+// coerceParameter converts string values of parameters into types, which are expected by the schema.
+// If the value cannot be converted, it's left as is to let the validator report it
+func coerceParameter(values []string, schema map[string]interface{}) interface{} {
+	schemaType, _ := schema["type"].(string)
+
+	if schemaType == "array" {
+		if len(values) == 1 {
+			values = strings.Split(values[0], ",")
+		}
+
+		items, _ := schema["items"].(map[string]interface{})
+		result := make([]interface{}, len(values))
+		for i, value := range values {
+			result[i] = coerceParameter([]string{value}, items)
+		}
+		return result
+	}
+
+	value := values[0]
+	switch schemaType {
+	case "integer":
+		if parsed, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return parsed
+		}
+	case "number":
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return parsed
+		}
+	case "boolean":
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+
+	return value
+}
+
+func validateValue(schema *gojsonschema.Schema, value interface{}) []string {
+	result, err := schema.Validate(gojsonschema.NewGoLoader(value))
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var descriptions []string
+	for _, resultError := range result.Errors() {
+		// errors of the wrapping schema duplicate the nested ones
+		if resultError.Type() == "number_all_of" {
+			continue
+		}
+
+		if resultError.Field() == "(root)" {
+			descriptions = append(descriptions, resultError.Description())
+		} else {
+			descriptions = append(descriptions, fmt.Sprintf("%s: %s", resultError.Field(), resultError.Description()))
+		}
+	}
+	return descriptions
+}
+
+func (spec *OpenAPISpec) wrapHandler(handler httpHandler, logWriter RequestLogWriter, serverName string) httpHandler {
+	return func(w http.ResponseWriter, req *http.Request) {
+		validationErrors := spec.validate(req)
+		if len(validationErrors) == 0 {
+			handler(w, req)
+			return
+		}
+
+		logRequest(req, logWriter, RequestLog{
+			ServerName:       serverName,
+			URL:              req.URL.String(),
+			Method:           req.Method,
+			StatusCode:       http.StatusBadRequest,
+			ValidationErrors: validationErrors,
+		})
+
+		payload, _ := json.Marshal(map[string][]string{"errors": validationErrors})
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(payload)
+	}
+}
@@ -0,0 +1,117 @@
+package mockServer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func loadExampleSpec(t *testing.T) *OpenAPISpec {
+
+	var spec OpenAPISpec
+	err := json.Unmarshal([]byte(`"file://../examples/openapi.yaml"`), &spec)
+	assert.Nil(t, err)
+
+	return &spec
+}
+
+func TestValidateRequestAgainstSpec(t *testing.T) {
+	spec := loadExampleSpec(t)
+
+	// descriptions of the schema errors depend on the version of the validator, so only their beginnings are checked
+	cases := []struct {
+		method, url, body string
+		headers           map[string]string
+		expectedErrors    []string
+	}{
+		{"GET", "/pets", "", nil, nil},
+		{"GET", "/pets?limit=10", "", nil, nil},
+		{"GET", "/pets?limit=0", "", nil, []string{`query parameter "limit": Must be greater than or equal to`}},
+		{"GET", "/pets?limit=many", "", nil, []string{`query parameter "limit": Invalid type. Expected: integer, given: string`}},
+		{"POST", "/pets", `{"name": "Rex", "tag": null}`, map[string]string{"Content-Type": "application/json"}, nil},
+		{"POST", "/pets", "", nil, []string{"request body is required"}},
+		{"POST", "/pets", `{"tag": 1}`, map[string]string{"Content-Type": "application/json"}, []string{
+			"request body: name is required",
+			"request body: tag: Invalid type.",
+		}},
+		{"POST", "/pets", `name=Rex`, map[string]string{"Content-Type": "text/plain"}, []string{`content type "text/plain" is not supported`}},
+		{"GET", "/pets/1", "", map[string]string{"X-Request-ID": "42"}, nil},
+		{"GET", "/pets/rex", "", nil, []string{
+			`path parameter "id": Invalid type. Expected: integer, given: string`,
+			`header parameter "X-Request-ID" is required`,
+		}},
+		{"DELETE", "/pets/1", "", nil, []string{"method DELETE is not allowed for path /pets/1"}},
+		{"GET", "/owners", "", nil, []string{"path /owners is not described in the OpenAPI spec"}},
+	}
+
+	for _, testCase := range cases {
+		req := httptest.NewRequest(testCase.method, testCase.url, strings.NewReader(testCase.body))
+		for header, value := range testCase.headers {
+			req.Header.Set(header, value)
+		}
+
+		validationErrors := spec.validate(req)
+
+		assert.Len(t, validationErrors, len(testCase.expectedErrors), testCase.method+" "+testCase.url)
+		for i, expectedError := range testCase.expectedErrors {
+			if i < len(validationErrors) {
+				assert.True(t, strings.HasPrefix(validationErrors[i], expectedError), validationErrors[i])
+			}
+		}
+	}
+}
+
+func TestValidationKeepsRequestBody(t *testing.T) {
+	spec := loadExampleSpec(t)
+
+	req := httptest.NewRequest("POST", "/pets", strings.NewReader(`{"name": "Rex"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	assert.Nil(t, spec.validate(req))
+
+	body, _ := ioutil.ReadAll(req.Body)
+	assert.Equal(t, `{"name": "Rex"}`, string(body))
+}
+
+func TestHandleInvalidRequest(t *testing.T) {
+	spec := loadExampleSpec(t)
+	logMessage := new(responseLogMessage)
+
+	handlerCalled := false
+	handler := spec.wrapHandler(func(w http.ResponseWriter, req *http.Request) {
+		handlerCalled = true
+	}, logMessage.writeResponseLog, "server_name")
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/pets?limit=many", nil))
+
+	resp := w.Result()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	assert.False(t, handlerCalled)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, `{"errors":["query parameter \"limit\": Invalid type. Expected: integer, given: string"]}`, string(body))
+
+	assert.Equal(t, "server_name", logMessage.ServerName)
+	assert.Equal(t, "/pets?limit=many", logMessage.URL)
+	assert.Equal(t, http.StatusBadRequest, logMessage.StatusCode)
+	assert.Equal(t, []string{`query parameter "limit": Invalid type. Expected: integer, given: string`}, logMessage.ValidationErrors)
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/pets?limit=1", nil))
+
+	assert.True(t, handlerCalled)
+}
+
+func TestParseSwaggerSpec(t *testing.T) {
+	_, err := parseOpenAPISpec([]byte("swagger: \"2.0\""))
+
+	assert.NotNil(t, err)
+	assert.Equal(t, "only OpenAPI 3 documents are supported", err.Error())
+}
@@ -4,16 +4,13 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
-	"os"
-	"path"
 	"regexp"
 	"strconv"
-	"strings"
 	"text/template"
 
-	"github.com/gorilla/mux"
+	"github.com/ghodss/yaml"
+	"github.com/xeipuuv/gojsonschema"
 )
 
 const filePathRegexp = `^file:\/\/[\/\w\.]*$`
@@ -22,6 +19,9 @@ const filePathRegexp = `^file:\/\/[\/\w\.]*$`
 type Response struct {
 	template   *template.Template
 	file       *template.Template
+	generator  *bodyGenerator
+	schema     *gojsonschema.Schema
+	fs         http.FileSystem
 	StatusCode int         `json:"status_code"`
 	Headers    http.Header `json:"headers"`
 }
@@ -49,7 +49,17 @@ func (response *Response) UnmarshalJSON(data []byte) error {
 		}
 	}
 
+	if val, ok := m["schema"]; ok {
+		err = response.setSchema(val, m["seed"], m["overrides"])
+		if err != nil {
+			return err
+		}
+	}
+
 	response.Headers = http.Header{}
+	if response.generator != nil {
+		response.Headers.Set("Content-Type", "application/json")
+	}
 	if m["headers"] != nil {
 		for header, value := range m["headers"].(map[string]interface{}) {
 			switch v := value.(type) {
@@ -76,7 +86,7 @@ func (response *Response) WriteResponse(w http.ResponseWriter, req *http.Request
 		w.Header().Set(header, value[0])
 	}
 
-	vars := mux.Vars(req)
+	vars := requestVars(req)
 
 	if response.template != nil {
 		w.WriteHeader(response.StatusCode)
@@ -84,40 +94,53 @@ func (response *Response) WriteResponse(w http.ResponseWriter, req *http.Request
 		if err := response.template.Execute(w, vars); err != nil {
 			fmt.Fprintf(w, err.Error())
 		}
+	} else if response.generator != nil {
+		w.WriteHeader(response.StatusCode)
+
+		body, err := response.generator.generate(vars, req.URL.Path)
+		if err != nil {
+			fmt.Fprintf(w, err.Error())
+			return
+		}
+		w.Write(body)
 	} else {
 		filePath := bytes.NewBufferString("")
 		if err := response.file.Execute(filePath, vars); err != nil {
 			fmt.Fprintf(w, err.Error())
 		}
-		http.ServeFile(w, req, filePath.String())
+		response.serveFile(w, req, filePath.String())
 	}
 }
 
-func processFilePath(filePath string, checkExistence bool) (string, error) {
-	filePath = strings.Replace(filePath, "file://", "", -1)
+// fileSystem returns the file system of the loader, which has loaded the response
+func (response *Response) fileSystem() http.FileSystem {
+	if response.fs == nil {
+		return osFileSystem{}
+	}
+	return response.fs
+}
 
-	if filePath[0] != '/' {
-		configFolder := path.Dir(ConfigPath)
-		filePath = path.Join(configFolder, filePath)
+func (response *Response) serveFile(w http.ResponseWriter, req *http.Request, name string) {
+	file, err := response.fileSystem().Open(name)
+	if err != nil {
+		http.NotFound(w, req)
+		return
 	}
+	defer file.Close()
 
-	if checkExistence {
-		if _, err := os.Stat(filePath); os.IsNotExist(err) {
-			return "", fmt.Errorf("File does not exist %s", filePath)
-		}
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, req)
+		return
 	}
 
-	return filePath, nil
+	http.ServeContent(w, req, info.Name(), info.ModTime(), file)
 }
 
+// setFile parses the path of the file as a template. Paths of loaded configs are already absolute
 func (response *Response) setFile(filePath string) error {
-	filePath, err := processFilePath(filePath, false)
-	if err != nil {
-		return err
-	}
-
 	templateInstance := template.New("template")
-	_, err = templateInstance.Parse(filePath)
+	_, err := templateInstance.Parse(referencePath(filePath))
 	if err != nil {
 		return err
 	}
@@ -134,12 +157,7 @@ func (response *Response) setTemplate(templateString string) error {
 
 	templateInstance := template.New("template")
 	if matched {
-		filePath, err := processFilePath(templateString, true)
-		if err != nil {
-			return err
-		}
-
-		data, err := ioutil.ReadFile(filePath)
+		data, err := readReference(templateString)
 		if err != nil {
 			return err
 		}
@@ -155,3 +173,54 @@ func (response *Response) setTemplate(templateString string) error {
 
 	return nil
 }
+
+// setSchema compiles the schema to validate responses.
+// If the response has neither template nor file, its body is generated from the schema
+func (response *Response) setSchema(schema, seed, overrides interface{}) error {
+	if filePath, ok := schema.(string); ok {
+		data, err := readReference(filePath)
+		if err != nil {
+			return err
+		}
+
+		jsonData, err := yaml.YAMLToJSON(data)
+		if err != nil {
+			return err
+		}
+
+		if err = json.Unmarshal(jsonData, &schema); err != nil {
+			return err
+		}
+	}
+
+	compiledSchema, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(schema))
+	if err != nil {
+		return fmt.Errorf("Invalid response schema: %s", err)
+	}
+	response.schema = compiledSchema
+
+	if response.template == nil && response.file == nil {
+		response.generator, err = newBodyGenerator(schema, seed, overrides)
+	}
+
+	return err
+}
+
+// render executes the response with passed variables and returns its body
+func (response *Response) render(vars map[string]string) ([]byte, error) {
+	body := bytes.NewBufferString("")
+
+	if response.template != nil {
+		err := response.template.Execute(body, vars)
+		return body.Bytes(), err
+	}
+
+	if response.generator != nil {
+		return response.generator.generate(vars, "")
+	}
+
+	if err := response.file.Execute(body, vars); err != nil {
+		return nil, err
+	}
+	return readFile(response.fileSystem(), body.String())
+}
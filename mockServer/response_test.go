@@ -2,6 +2,7 @@ package mockServer
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -20,7 +21,7 @@ func TestWriteTemplateResponse(t *testing.T) {
 	tmpl := template.New("template")
 	tmpl.Parse(`{"passed_value": "{{.var}}"}`)
 
-	response := Response{tmpl, nil, http.StatusCreated, http.Header{"Content-Type": []string{"application/json"}}}
+	response := Response{template: tmpl, StatusCode: http.StatusCreated, Headers: http.Header{"Content-Type": []string{"application/json"}}}
 	router := mux.NewRouter()
 	router.HandleFunc("/simple_url/{var}", response.WriteResponse)
 
@@ -41,7 +42,7 @@ func TestWriteTemplateResponseWithoutVarsInURL(t *testing.T) {
 	tmpl := template.New("template")
 	tmpl.Parse(`{"passed_value": "{{.var}}"}`)
 
-	response := Response{tmpl, nil, http.StatusCreated, http.Header{"Content-Type": []string{"application/json"}}}
+	response := Response{template: tmpl, StatusCode: http.StatusCreated, Headers: http.Header{"Content-Type": []string{"application/json"}}}
 	router := mux.NewRouter()
 	router.HandleFunc("/simple_url", response.WriteResponse)
 
@@ -62,7 +63,7 @@ func TestWriteTemplateResponseWithoutVarsInTemplate(t *testing.T) {
 	tmpl := template.New("template")
 	tmpl.Parse(`{"passed_value": "2"}`)
 
-	response := Response{tmpl, nil, http.StatusCreated, http.Header{"Content-Type": []string{"application/json"}}}
+	response := Response{template: tmpl, StatusCode: http.StatusCreated, Headers: http.Header{"Content-Type": []string{"application/json"}}}
 	router := mux.NewRouter()
 	router.HandleFunc("/simple_url/{var}", response.WriteResponse)
 
@@ -90,7 +91,7 @@ func TestWriteFileResponse(t *testing.T) {
 	tmpl := template.New("template")
 	tmpl.Parse(filepath)
 
-	var response = Response{nil, tmpl, http.StatusOK, http.Header{}}
+	var response = Response{file: tmpl, StatusCode: http.StatusOK, Headers: http.Header{}}
 
 	response.WriteResponse(w, req)
 
@@ -113,7 +114,7 @@ func TestWriteFileResponseWithVarInPath(t *testing.T) {
 	tmpl := template.New("template")
 	tmpl.Parse(filepath)
 
-	response := Response{nil, tmpl, http.StatusOK, http.Header{}}
+	response := Response{file: tmpl, StatusCode: http.StatusOK, Headers: http.Header{}}
 	router := mux.NewRouter()
 	router.HandleFunc("/{var}/in/filepath", response.WriteResponse)
 
@@ -140,7 +141,7 @@ func TestWriteFileResponseWhenFileDoesNotExist(t *testing.T) {
 	tmpl := template.New("template")
 	tmpl.Parse(filepath)
 
-	response := Response{nil, tmpl, http.StatusOK, http.Header{}}
+	response := Response{file: tmpl, StatusCode: http.StatusOK, Headers: http.Header{}}
 	router := mux.NewRouter()
 	router.HandleFunc("/{var}/in/filepath", response.WriteResponse)
 
@@ -199,9 +200,23 @@ status_code: 201
 	assert.Equal(t, http.StatusCreated, response.StatusCode)
 }
 
+// loadResponseFromConfig resolves files of the response like the loader does for a config in the directory
+func loadResponseFromConfig(t *testing.T, dir, config string) Response {
+	var fields map[string]interface{}
+	assert.Nil(t, yaml.Unmarshal([]byte(config), &fields))
+	assert.Nil(t, NewLoader(LoaderOptions{}).newLoading().resolveResponse(fields, dir))
+
+	data, err := json.Marshal(fields)
+	assert.Nil(t, err)
+
+	var response Response
+	assert.Nil(t, json.Unmarshal(data, &response))
+	return response
+}
+
 func TestUnmarshalTemplateFile(t *testing.T) {
 	_, filename, _, _ := runtime.Caller(0)
-	ConfigPath = path.Join(path.Dir(filename), "..", "examples", "config.yaml")
+	dir := path.Join(path.Dir(filename), "..", "examples")
 
 	cases := []string{
 		path.Join(path.Dir(filename), "..", "examples", "response_with_var.json"), // absolute path
@@ -214,7 +229,7 @@ func TestUnmarshalTemplateFile(t *testing.T) {
 	for _, filePath := range cases {
 		config := fmt.Sprintf(`template: file://%s`, filePath)
 
-		response := createResponseFromConfig(config)
+		response := loadResponseFromConfig(t, dir, config)
 
 		assert.Nil(t, response.file)
 		assert.NotNil(t, response.template)
@@ -230,7 +245,7 @@ func TestUnmarshalTemplateFile(t *testing.T) {
 
 func TestUnmarshalBinaryFile(t *testing.T) {
 	_, filename, _, _ := runtime.Caller(0)
-	ConfigPath = path.Join(path.Dir(filename), "..", "examples", "config.yaml")
+	dir := path.Join(path.Dir(filename), "..", "examples")
 	absoluteFilePath := path.Join(path.Dir(filename), "..", "examples", "mimicro.png")
 
 	cases := []string{
@@ -245,7 +260,7 @@ func TestUnmarshalBinaryFile(t *testing.T) {
 	for _, filePath := range cases {
 		config := fmt.Sprintf(`file: file://%s`, filePath)
 
-		response := createResponseFromConfig(config)
+		response := loadResponseFromConfig(t, dir, config)
 
 		assert.Nil(t, response.template)
 		assert.Equal(
@@ -5,8 +5,19 @@ var schema = `
     "$schema": "http://json-schema.org/draft-04/schema#",
     "type": "object",
     "additionalProperties": false,
-    "required": ["servers"],
+    "anyOf": [
+        {"required": ["servers"]},
+        {"required": ["include"]}
+    ],
     "properties": {
+        "include": {
+            "type": "array",
+            "items": {"type": "string"}
+        },
+        "params": {
+            "type": "object",
+            "additionalProperties": {"type": ["string", "number", "boolean"]}
+        },
         "servers": {
             "uniqueItems": true,
             "items": {"$ref": "#/definitions/server"}
@@ -24,6 +35,12 @@ var schema = `
             "properties": {
                 "name": {"type": "string"},
                 "port": {"type": "integer"},
+                "bind": {"type": "string"},
+                "openapi": {
+                    "type": "string",
+                    "pattern": "^file:\/\/[a-zA-Z0-9_ -\/.]*$"
+                },
+                "default_response": {"$ref": "#/definitions/response"},
                 "endpoints": {
                     "type": "array",
                     "uniqueItems": true,
@@ -34,20 +51,48 @@ var schema = `
         "endpoint": {
             "type": "object",
             "additionalProperties": false,
-            "required": ["url"],
+            "oneOf": [
+                {"required": ["url"]},
+                {"required": ["regex"]}
+            ],
             "properties": {
                 "url": {"type": "string"},
+                "regex": {"type": "string"},
+                "host": {"type": "string"},
+                "schemes": {
+                    "type": "array",
+                    "items": {"type": "string", "enum": ["http", "https"]}
+                },
+                "priority": {"type": "integer"},
                 "GET": {"$ref": "#/definitions/response"},
                 "POST": {"$ref": "#/definitions/response"},
                 "PUT": {"$ref": "#/definitions/response"},
                 "PATCH": {"$ref": "#/definitions/response"},
-                "DELETE": {"$ref": "#/definitions/response"}
+                "DELETE": {"$ref": "#/definitions/response"},
+                "graphql": {"$ref": "#/definitions/graphql"}
+            }
+        },
+        "graphql": {
+            "type": "object",
+            "additionalProperties": false,
+            "required": ["schema"],
+            "properties": {
+                "schema": {
+                    "type": "string",
+                    "pattern": "^file:\/\/[a-zA-Z0-9_ -\/.]*$"
+                },
+                "seed": {"type": "integer"},
+                "resolvers": {
+                    "type": "object",
+                    "additionalProperties": {"type": "string"}
+                }
             }
         },
         "response": {
             "oneOf": [
                 {"$ref": "#/definitions/templateResponse"},
-                {"$ref": "#/definitions/fileResponse"}
+                {"$ref": "#/definitions/fileResponse"},
+                {"$ref": "#/definitions/schemaResponse"}
             ]
         },
         "templateResponse": {
@@ -60,6 +105,7 @@ var schema = `
                     "minLength": 1
                 },
                 "headers": {"$ref": "#/definitions/headers"},
+                "schema": {"$ref": "#/definitions/responseSchema"},
                 "status_code": {
                     "type": "integer",
                     "enum": [
@@ -83,12 +129,37 @@ var schema = `
                     "pattern": "^file:\/\/[a-zA-Z0-9_ -\/.{}]*$"
                 },
                 "headers": {"$ref": "#/definitions/headers"},
+                "schema": {"$ref": "#/definitions/responseSchema"},
                 "status_code": {
                     "type": "integer",
                     "enum": [200]
                 }
             }
         },
+        "schemaResponse": {
+            "type": "object",
+            "additionalProperties": false,
+            "required": ["schema"],
+            "properties": {
+                "schema": {"$ref": "#/definitions/responseSchema"},
+                "seed": {"type": "integer"},
+                "overrides": {
+                    "type": "object",
+                    "additionalProperties": {"type": ["number", "string", "boolean"]}
+                },
+                "headers": {"$ref": "#/definitions/headers"},
+                "status_code": {"$ref": "#/definitions/templateResponse/properties/status_code"}
+            }
+        },
+        "responseSchema": {
+            "oneOf": [
+                {
+                    "type": "string",
+                    "pattern": "^file:\/\/[a-zA-Z0-9_ -\/.]*$"
+                },
+                {"type": "object"}
+            ]
+        },
         "headers": {
             "type": "object",
             "additionalProperties": false,